@@ -0,0 +1,200 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/zetsux/gin-gorm-clean-starter/common/base"
+	"github.com/zetsux/gin-gorm-clean-starter/common/util"
+	"github.com/zetsux/gin-gorm-clean-starter/core/helper/dto"
+	errs "github.com/zetsux/gin-gorm-clean-starter/core/helper/errors"
+	"github.com/zetsux/gin-gorm-clean-starter/core/helper/messages"
+	"github.com/zetsux/gin-gorm-clean-starter/core/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+type attachmentController struct {
+	attachmentService service.AttachmentService
+	ownerType         string
+	ownerParam        string
+}
+
+type AttachmentController interface {
+	GetAttachments(ctx *gin.Context)
+	GetAttachment(ctx *gin.Context)
+	CreateAttachment(ctx *gin.Context)
+	UpdateAttachment(ctx *gin.Context)
+	ReorderAttachments(ctx *gin.Context)
+	DeleteAttachment(ctx *gin.Context)
+}
+
+// NewAttachmentController serves the attachments of the owners of ownerType,
+// which are identified by the ownerParam path parameter of the routes.
+func NewAttachmentController(attachmentS service.AttachmentService,
+	ownerType string, ownerParam string) AttachmentController {
+	return &attachmentController{
+		attachmentService: attachmentS,
+		ownerType:         ownerType,
+		ownerParam:        ownerParam,
+	}
+}
+
+func (ac *attachmentController) owner(ctx *gin.Context) dto.AttachmentOwner {
+	return dto.AttachmentOwner{
+		Type: ac.ownerType,
+		ID:   ctx.Param(ac.ownerParam),
+	}
+}
+
+func attachmentActor(ctx *gin.Context) dto.AttachmentActor {
+	return dto.AttachmentActor{
+		ID:   ctx.GetString("ID"),
+		Role: ctx.GetString("ROLE"),
+	}
+}
+
+func (ac *attachmentController) GetAttachments(ctx *gin.Context) {
+	attachments, err := ac.attachmentService.GetAttachments(ctx, ac.owner(ctx), attachmentActor(ctx))
+	if err != nil {
+		abortOnAttachmentError(ctx, messages.MsgAttachmentsFetchFailed, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, base.CreateSuccessResponse(
+		messages.MsgAttachmentsFetchSuccess,
+		http.StatusOK, attachments,
+	))
+}
+
+func (ac *attachmentController) GetAttachment(ctx *gin.Context) {
+	attachment, err := ac.attachmentService.GetAttachment(ctx,
+		ac.owner(ctx), ctx.Param("attachment_id"), attachmentActor(ctx))
+	if err != nil {
+		abortOnAttachmentError(ctx, messages.MsgAttachmentFetchFailed, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, base.CreateSuccessResponse(
+		messages.MsgAttachmentFetchSuccess,
+		http.StatusOK, attachment,
+	))
+}
+
+func (ac *attachmentController) CreateAttachment(ctx *gin.Context) {
+	var attachmentDTO dto.AttachmentCreateRequest
+
+	// a file uploaded beforehand through a resumable upload is attached,
+	// otherwise the file is streamed from the form after the other fields
+	if ctx.ContentType() == gin.MIMEJSON {
+		if err := ctx.ShouldBindJSON(&attachmentDTO); err != nil {
+			abortOnAttachmentError(ctx, messages.MsgAttachmentCreateFailed, err)
+			return
+		}
+	} else {
+		file, values, err := util.NextFormFileWithValues(ctx.Request, "file")
+		if err != nil {
+			abortOnAttachmentError(ctx, messages.MsgAttachmentCreateFailed, err)
+			return
+		}
+		defer file.Close()
+
+		if err := binding.MapFormWithTag(&attachmentDTO, values, "form"); err != nil {
+			abortOnAttachmentError(ctx, messages.MsgAttachmentCreateFailed, err)
+			return
+		}
+		if err := binding.Validator.ValidateStruct(&attachmentDTO); err != nil {
+			abortOnAttachmentError(ctx, messages.MsgAttachmentCreateFailed, err)
+			return
+		}
+
+		attachmentDTO.UploadID = ""
+		attachmentDTO.Filename = file.FileName()
+		attachmentDTO.ContentType = file.Header.Get("Content-Type")
+		attachmentDTO.Content = file
+	}
+
+	attachment, err := ac.attachmentService.CreateAttachment(ctx, attachmentDTO, ac.owner(ctx), attachmentActor(ctx))
+	if err != nil {
+		abortOnAttachmentError(ctx, messages.MsgAttachmentCreateFailed, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, base.CreateSuccessResponse(
+		messages.MsgAttachmentCreateSuccess,
+		http.StatusCreated, attachment,
+	))
+}
+
+func (ac *attachmentController) UpdateAttachment(ctx *gin.Context) {
+	var attachmentDTO dto.AttachmentUpdateRequest
+	if err := ctx.ShouldBind(&attachmentDTO); err != nil {
+		abortOnAttachmentError(ctx, messages.MsgAttachmentUpdateFailed, err)
+		return
+	}
+
+	attachment, err := ac.attachmentService.UpdateAttachment(ctx, attachmentDTO,
+		ac.owner(ctx), ctx.Param("attachment_id"), attachmentActor(ctx))
+	if err != nil {
+		abortOnAttachmentError(ctx, messages.MsgAttachmentUpdateFailed, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, base.CreateSuccessResponse(
+		messages.MsgAttachmentUpdateSuccess,
+		http.StatusOK, attachment,
+	))
+}
+
+func (ac *attachmentController) ReorderAttachments(ctx *gin.Context) {
+	var attachmentDTO dto.AttachmentReorderRequest
+	if err := ctx.ShouldBind(&attachmentDTO); err != nil {
+		abortOnAttachmentError(ctx, messages.MsgAttachmentsReorderFailed, err)
+		return
+	}
+
+	attachments, err := ac.attachmentService.ReorderAttachments(ctx,
+		attachmentDTO, ac.owner(ctx), attachmentActor(ctx))
+	if err != nil {
+		abortOnAttachmentError(ctx, messages.MsgAttachmentsReorderFailed, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, base.CreateSuccessResponse(
+		messages.MsgAttachmentsReorderSuccess,
+		http.StatusOK, attachments,
+	))
+}
+
+func (ac *attachmentController) DeleteAttachment(ctx *gin.Context) {
+	err := ac.attachmentService.DeleteAttachment(ctx,
+		ac.owner(ctx), ctx.Param("attachment_id"), attachmentActor(ctx))
+	if err != nil {
+		abortOnAttachmentError(ctx, messages.MsgAttachmentDeleteFailed, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, base.CreateSuccessResponse(
+		messages.MsgAttachmentDeleteSuccess,
+		http.StatusOK, nil,
+	))
+}
+
+// abortOnAttachmentError aborts with 404 when the owner or the attachment is
+// not there, 403 when the actor is not allowed to do so and like a failed
+// upload otherwise.
+func abortOnAttachmentError(ctx *gin.Context, msg string, err error) {
+	var status int
+	switch {
+	case errors.Is(err, errs.ErrAttachmentNotFound), errors.Is(err, errs.ErrAttachmentOwnerNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, errs.ErrAttachmentForbidden):
+		status = http.StatusForbidden
+	default:
+		abortOnUploadError(ctx, msg, err)
+		return
+	}
+
+	ctx.AbortWithStatusJSON(status, base.CreateFailResponse(msg, err.Error(), uint(status)))
+}
@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/zetsux/gin-gorm-clean-starter/common/base"
+	"github.com/zetsux/gin-gorm-clean-starter/common/util"
+	"github.com/zetsux/gin-gorm-clean-starter/core/helper/dto"
+	errs "github.com/zetsux/gin-gorm-clean-starter/core/helper/errors"
+	"github.com/zetsux/gin-gorm-clean-starter/core/helper/messages"
+	"github.com/zetsux/gin-gorm-clean-starter/core/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type avatarController struct {
+	avatarService service.AvatarService
+}
+
+type AvatarController interface {
+	GetAvatar(ctx *gin.Context)
+}
+
+func NewAvatarController(avatarS service.AvatarService) AvatarController {
+	return &avatarController{avatarService: avatarS}
+}
+
+func (ac *avatarController) GetAvatar(ctx *gin.Context) {
+	var req dto.AvatarGetRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, base.CreateFailResponse(
+			messages.MsgAvatarFetchFailed,
+			err.Error(), http.StatusBadRequest,
+		))
+		return
+	}
+
+	avatar, err := ac.avatarService.GetAvatar(ctx.Param("user_id"), req)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, errs.ErrUserNotFound) {
+			status = http.StatusNotFound
+		}
+		ctx.AbortWithStatusJSON(status, base.CreateFailResponse(
+			messages.MsgAvatarFetchFailed,
+			err.Error(), uint(status),
+		))
+		return
+	}
+
+	// avatars never change, so they can be cached for as long as possible
+	ctx.Header("ETag", avatar.ETag)
+	ctx.Header("Cache-Control", "public, max-age=31536000, immutable")
+	ctx.Header("X-Content-Type-Options", "nosniff")
+	if util.ETagMatches(ctx.GetHeader("If-None-Match"), avatar.ETag) {
+		ctx.Status(http.StatusNotModified)
+		return
+	}
+
+	ctx.Data(http.StatusOK, avatar.ContentType, avatar.Content)
+}
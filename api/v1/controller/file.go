@@ -1,35 +1,54 @@
 package controller
 
 import (
+	"errors"
+	"io"
+	"mime"
 	"net/http"
-	"os"
+	"reflect"
+	"slices"
 	"strings"
 
 	"github.com/zetsux/gin-gorm-clean-starter/common/base"
-	"github.com/zetsux/gin-gorm-clean-starter/common/constant"
+	"github.com/zetsux/gin-gorm-clean-starter/common/util"
+	"github.com/zetsux/gin-gorm-clean-starter/core/helper/dto"
+	errs "github.com/zetsux/gin-gorm-clean-starter/core/helper/errors"
 	"github.com/zetsux/gin-gorm-clean-starter/core/helper/messages"
+	"github.com/zetsux/gin-gorm-clean-starter/core/service"
 
 	"github.com/gin-gonic/gin"
 )
 
-type fileController struct{}
+// inlineContentTypes lists the content types which are safe to display inline,
+// as they cannot carry scripts.
+var inlineContentTypes = []string{"image/jpeg", "image/png", "image/webp", "image/gif"}
+
+type fileController struct {
+	fileService              service.FileService
+	garbageCollectionService service.GarbageCollectionService
+}
 
 type FileController interface {
 	GetFile(ctx *gin.Context)
+	GetAllFiles(ctx *gin.Context)
+	GetFileMetadata(ctx *gin.Context)
+	DeleteFile(ctx *gin.Context)
+	GetGarbageFiles(ctx *gin.Context)
 }
 
-func NewFileController() FileController {
-	return &fileController{}
+func NewFileController(fileS service.FileService, garbageCollectionS service.GarbageCollectionService) FileController {
+	return &fileController{
+		fileService:              fileS,
+		garbageCollectionService: garbageCollectionS,
+	}
 }
 
 func (fc *fileController) GetFile(ctx *gin.Context) {
 	dir := ctx.Param("dir")
 	fileID := ctx.Param("file_id")
 
-	filePath := strings.Join([]string{constant.FileBasePath, dir, fileID}, "/")
-
-	_, err := os.Stat(filePath)
-	if os.IsNotExist(err) {
+	var req dto.FileGetRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
 		ctx.AbortWithStatusJSON(http.StatusBadRequest, base.CreateFailResponse(
 			messages.MsgFileFetchFailed,
 			err.Error(), http.StatusBadRequest,
@@ -37,5 +56,174 @@ func (fc *fileController) GetFile(ctx *gin.Context) {
 		return
 	}
 
-	ctx.File(filePath)
+	key := strings.Join([]string{dir, fileID}, "/")
+	file, info, err := fc.fileService.GetFile(ctx, key, req)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, errs.ErrFileNotFound) {
+			status = http.StatusNotFound
+		} else if errors.Is(err, errs.ErrFileSignatureInvalid) || errors.Is(err, errs.ErrFileURLExpired) ||
+			errors.Is(err, errs.ErrFileQuarantined) {
+			status = http.StatusForbidden
+		}
+		ctx.AbortWithStatusJSON(status, base.CreateFailResponse(
+			messages.MsgFileFetchFailed,
+			err.Error(), uint(status),
+		))
+		return
+	}
+	defer file.Close()
+
+	contentType := info.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	// anything but plain images is downloaded whatever the disposition asked
+	// for, so that it never runs in the context of this origin
+	disposition := req.Disposition
+	if !slices.Contains(inlineContentTypes, contentType) {
+		_, params, _ := mime.ParseMediaType(disposition)
+		if disposition = mime.FormatMediaType("attachment", params); disposition == "" {
+			disposition = "attachment"
+		}
+	}
+
+	header := ctx.Writer.Header()
+	header.Set("Content-Type", contentType)
+	header.Set("X-Content-Type-Options", "nosniff")
+	header.Set("ETag", info.ETag)
+	if disposition != "" {
+		header.Set("Content-Disposition", disposition)
+	}
+	if !fc.fileService.IsPublic(key) {
+		header.Set("Cache-Control", "private")
+	}
+
+	// seekable content gets ranges and conditional requests handled in full
+	if content, ok := file.(io.ReadSeeker); ok {
+		http.ServeContent(ctx.Writer, ctx.Request, "", info.LastModified, content)
+		return
+	}
+
+	if !info.LastModified.IsZero() {
+		header.Set("Last-Modified", info.LastModified.UTC().Format(http.TimeFormat))
+	}
+	if util.ETagMatches(ctx.GetHeader("If-None-Match"), info.ETag) {
+		ctx.Status(http.StatusNotModified)
+		return
+	}
+	ctx.DataFromReader(http.StatusOK, info.Size, contentType, file, nil)
+}
+
+func (fc *fileController) GetAllFiles(ctx *gin.Context) {
+	var req base.GetsRequest
+	if err := ctx.ShouldBind(&req); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, base.CreateFailResponse(
+			messages.MsgFilesFetchFailed,
+			err.Error(), http.StatusBadRequest,
+		))
+		return
+	}
+
+	files, pageMeta, err := fc.fileService.GetAllFiles(ctx, req)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, base.CreateFailResponse(
+			messages.MsgFilesFetchFailed,
+			err.Error(), http.StatusBadRequest,
+		))
+		return
+	}
+
+	if reflect.DeepEqual(pageMeta, base.PaginationResponse{}) {
+		ctx.JSON(http.StatusOK, base.CreateSuccessResponse(
+			messages.MsgFilesFetchSuccess,
+			http.StatusOK, files,
+		))
+	} else {
+		ctx.JSON(http.StatusOK, base.CreatePaginatedResponse(
+			messages.MsgFilesFetchSuccess,
+			http.StatusOK, files, pageMeta,
+		))
+	}
+}
+
+func (fc *fileController) GetFileMetadata(ctx *gin.Context) {
+	key := strings.Join([]string{ctx.Param("dir"), ctx.Param("file_id")}, "/")
+	file, err := fc.fileService.GetFileMetadata(ctx, key)
+	if errors.Is(err, errs.ErrFileNotFound) {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, base.CreateFailResponse(
+			messages.MsgFileMetadataFailed,
+			err.Error(), http.StatusNotFound,
+		))
+		return
+	} else if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, base.CreateFailResponse(
+			messages.MsgFileMetadataFailed,
+			err.Error(), http.StatusBadRequest,
+		))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, base.CreateSuccessResponse(
+		messages.MsgFileMetadataSuccess,
+		http.StatusOK, file,
+	))
+}
+
+func (fc *fileController) DeleteFile(ctx *gin.Context) {
+	key := strings.Join([]string{ctx.Param("dir"), ctx.Param("file_id")}, "/")
+	err := fc.fileService.Delete(ctx, key)
+	if errors.Is(err, errs.ErrFileNotFound) {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, base.CreateFailResponse(
+			messages.MsgFileDeleteFailed,
+			err.Error(), http.StatusNotFound,
+		))
+		return
+	} else if errors.Is(err, errs.ErrFileReferenced) {
+		ctx.AbortWithStatusJSON(http.StatusConflict, base.CreateFailResponse(
+			messages.MsgFileDeleteFailed,
+			err.Error(), http.StatusConflict,
+		))
+		return
+	} else if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, base.CreateFailResponse(
+			messages.MsgFileDeleteFailed,
+			err.Error(), http.StatusBadRequest,
+		))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, base.CreateSuccessResponse(
+		messages.MsgFileDeleteSuccess,
+		http.StatusOK, nil,
+	))
+}
+
+// GetGarbageFiles reports what a garbage collection would delete, without
+// deleting anything.
+func (fc *fileController) GetGarbageFiles(ctx *gin.Context) {
+	var req dto.GarbageCollectionRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, base.CreateFailResponse(
+			messages.MsgGarbageFetchFailed,
+			err.Error(), http.StatusBadRequest,
+		))
+		return
+	}
+
+	req.DryRun = true
+	garbage, err := fc.garbageCollectionService.Collect(ctx, req)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, base.CreateFailResponse(
+			messages.MsgGarbageFetchFailed,
+			err.Error(), http.StatusBadRequest,
+		))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, base.CreateSuccessResponse(
+		messages.MsgGarbageFetchSuccess,
+		http.StatusOK, garbage,
+	))
 }
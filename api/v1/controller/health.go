@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/zetsux/gin-gorm-clean-starter/common/base"
+	"github.com/zetsux/gin-gorm-clean-starter/core/helper/messages"
+	"github.com/zetsux/gin-gorm-clean-starter/core/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type healthController struct {
+	healthService service.HealthService
+}
+
+type HealthController interface {
+	GetLiveness(ctx *gin.Context)
+	GetReadiness(ctx *gin.Context)
+}
+
+func NewHealthController(healthS service.HealthService) HealthController {
+	return &healthController{healthService: healthS}
+}
+
+func (hc *healthController) GetLiveness(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, base.CreateSuccessResponse(
+		messages.MsgServiceAlive,
+		http.StatusOK, hc.healthService.CheckLiveness(),
+	))
+}
+
+// GetReadiness answers 503 along with the status of each component whenever
+// one of them is down or the server is shutting down.
+func (hc *healthController) GetReadiness(ctx *gin.Context) {
+	health, err := hc.healthService.CheckReadiness(ctx)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusServiceUnavailable, base.CreateFailResponseWithData(
+			messages.MsgServiceNotReady,
+			err.Error(), http.StatusServiceUnavailable, health,
+		))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, base.CreateSuccessResponse(
+		messages.MsgServiceReady,
+		http.StatusOK, health,
+	))
+}
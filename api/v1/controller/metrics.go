@@ -0,0 +1,25 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type metricsController struct {
+	handler http.Handler
+}
+
+type MetricsController interface {
+	GetMetrics(ctx *gin.Context)
+}
+
+// NewMetricsController serves the metrics through handler, which writes them
+// in the exposition format of the scraper.
+func NewMetricsController(handler http.Handler) MetricsController {
+	return &metricsController{handler: handler}
+}
+
+func (mc *metricsController) GetMetrics(ctx *gin.Context) {
+	mc.handler.ServeHTTP(ctx.Writer, ctx.Request)
+}
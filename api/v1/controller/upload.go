@@ -0,0 +1,154 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/zetsux/gin-gorm-clean-starter/common/base"
+	"github.com/zetsux/gin-gorm-clean-starter/common/constant"
+	"github.com/zetsux/gin-gorm-clean-starter/common/util"
+	"github.com/zetsux/gin-gorm-clean-starter/core/helper/dto"
+	errs "github.com/zetsux/gin-gorm-clean-starter/core/helper/errors"
+	"github.com/zetsux/gin-gorm-clean-starter/core/helper/messages"
+	"github.com/zetsux/gin-gorm-clean-starter/core/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type uploadController struct {
+	uploadService service.UploadService
+}
+
+type UploadController interface {
+	GetOptions(ctx *gin.Context)
+	CreateUpload(ctx *gin.Context)
+	GetUploadOffset(ctx *gin.Context)
+	WriteChunk(ctx *gin.Context)
+	DeleteUpload(ctx *gin.Context)
+}
+
+func NewUploadController(uploadS service.UploadService) UploadController {
+	return &uploadController{uploadService: uploadS}
+}
+
+func (uc *uploadController) GetOptions(ctx *gin.Context) {
+	ctx.Header("Tus-Version", constant.TusVersion)
+	ctx.Header("Tus-Extension", constant.TusExtensions)
+	ctx.Header("Tus-Max-Size", strconv.FormatInt(uc.uploadService.MaxSize(), 10))
+	ctx.Status(http.StatusNoContent)
+}
+
+func (uc *uploadController) CreateUpload(ctx *gin.Context) {
+	ownerID := ctx.MustGet("ID").(string)
+
+	var req dto.UploadCreateRequest
+	if err := ctx.ShouldBindHeader(&req); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, base.CreateFailResponse(
+			messages.MsgUploadCreateFailed,
+			err.Error(), http.StatusBadRequest,
+		))
+		return
+	}
+
+	upload, err := uc.uploadService.CreateUpload(ctx, req, ownerID)
+	if err != nil {
+		abortOnResumableUploadError(ctx, messages.MsgUploadCreateFailed, err)
+		return
+	}
+
+	ctx.Header("Location", constant.UploadRoutePrefix+"/"+upload.ID)
+	setUploadHeaders(ctx, upload)
+	ctx.JSON(http.StatusCreated, base.CreateSuccessResponse(
+		messages.MsgUploadCreateSuccess,
+		http.StatusCreated, upload,
+	))
+}
+
+func (uc *uploadController) GetUploadOffset(ctx *gin.Context) {
+	ownerID := ctx.MustGet("ID").(string)
+
+	upload, err := uc.uploadService.GetUpload(ctx, ctx.Param("upload_id"), ownerID)
+	if err != nil {
+		abortOnResumableUploadError(ctx, messages.MsgUploadFetchFailed, err)
+		return
+	}
+
+	ctx.Header("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	if upload.Metadata != "" {
+		ctx.Header("Upload-Metadata", upload.Metadata)
+	}
+	ctx.Header("Cache-Control", "no-store")
+	setUploadHeaders(ctx, upload)
+	ctx.Status(http.StatusOK)
+}
+
+func (uc *uploadController) WriteChunk(ctx *gin.Context) {
+	ownerID := ctx.MustGet("ID").(string)
+
+	if ctx.ContentType() != constant.MIMETusChunk {
+		ctx.AbortWithStatusJSON(http.StatusUnsupportedMediaType, base.CreateFailResponse(
+			messages.MsgUploadWriteFailed,
+			errs.ErrUnsupportedContentType.Error(), http.StatusUnsupportedMediaType,
+		))
+		return
+	}
+
+	var req dto.UploadChunkRequest
+	if err := ctx.ShouldBindHeader(&req); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, base.CreateFailResponse(
+			messages.MsgUploadWriteFailed,
+			err.Error(), http.StatusBadRequest,
+		))
+		return
+	}
+	req.Content, req.ContentLength = ctx.Request.Body, ctx.Request.ContentLength
+
+	upload, err := uc.uploadService.WriteChunk(ctx, req, ctx.Param("upload_id"), ownerID)
+	if err != nil {
+		if upload.ID != "" {
+			setUploadHeaders(ctx, upload)
+		}
+		abortOnResumableUploadError(ctx, messages.MsgUploadWriteFailed, err)
+		return
+	}
+
+	setUploadHeaders(ctx, upload)
+	ctx.Status(http.StatusNoContent)
+}
+
+func (uc *uploadController) DeleteUpload(ctx *gin.Context) {
+	ownerID := ctx.MustGet("ID").(string)
+
+	err := uc.uploadService.DeleteUpload(ctx, ctx.Param("upload_id"), ownerID)
+	if err != nil {
+		abortOnResumableUploadError(ctx, messages.MsgUploadDeleteFailed, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func setUploadHeaders(ctx *gin.Context, upload dto.UploadResponse) {
+	ctx.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	ctx.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+}
+
+// abortOnResumableUploadError maps the failures of resumable uploads to the
+// statuses expected by tus clients.
+func abortOnResumableUploadError(ctx *gin.Context, msg string, err error) {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, errs.ErrUploadNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, errs.ErrUploadExpired):
+		status = http.StatusGone
+	case errors.Is(err, errs.ErrUploadOffsetMismatch):
+		status = http.StatusConflict
+	case errors.Is(err, errs.ErrUploadTooLarge), errors.Is(err, errs.ErrUploadChunkTooLarge),
+		errors.Is(err, errs.ErrStorageQuotaExceeded), util.IsBodyTooLarge(err):
+		status = http.StatusRequestEntityTooLarge
+	}
+
+	ctx.AbortWithStatusJSON(status, base.CreateFailResponse(msg, err.Error(), uint(status)))
+}
@@ -1,21 +1,27 @@
 package controller
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
 	"reflect"
 
 	"github.com/zetsux/gin-gorm-clean-starter/common/base"
 	"github.com/zetsux/gin-gorm-clean-starter/common/constant"
+	"github.com/zetsux/gin-gorm-clean-starter/common/util"
 	"github.com/zetsux/gin-gorm-clean-starter/core/helper/dto"
+	errs "github.com/zetsux/gin-gorm-clean-starter/core/helper/errors"
 	"github.com/zetsux/gin-gorm-clean-starter/core/helper/messages"
 	"github.com/zetsux/gin-gorm-clean-starter/core/service"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 )
 
 type userController struct {
-	userService service.UserService
-	jwtService  service.JWTService
+	userService         service.UserService
+	jwtService          service.JWTService
+	storageQuotaService service.StorageQuotaService
 }
 
 type UserController interface {
@@ -23,18 +29,29 @@ type UserController interface {
 	Login(ctx *gin.Context)
 	GetAllUsers(ctx *gin.Context)
 	GetMe(ctx *gin.Context)
+	GetUserByID(ctx *gin.Context)
 	UpdateSelfName(ctx *gin.Context)
 	UpdateUserByID(ctx *gin.Context)
+	PatchUserByID(ctx *gin.Context)
 	DeleteSelfUser(ctx *gin.Context)
 	DeleteUserByID(ctx *gin.Context)
 	ChangePicture(ctx *gin.Context)
 	DeletePicture(ctx *gin.Context)
+	ChangeUserStatus(ctx *gin.Context)
+	GetUserStatusHistory(ctx *gin.Context)
+	GetMyLoginEvents(ctx *gin.Context)
+	GetLoginEventsByUserID(ctx *gin.Context)
+	GetMyStorage(ctx *gin.Context)
+	GetStorageByUserID(ctx *gin.Context)
+	UpdateStorageQuota(ctx *gin.Context)
 }
 
-func NewUserController(userS service.UserService, jwtS service.JWTService) UserController {
+func NewUserController(userS service.UserService,
+	jwtS service.JWTService, storageQuotaS service.StorageQuotaService) UserController {
 	return &userController{
-		userService: userS,
-		jwtService:  jwtS,
+		userService:         userS,
+		jwtService:          jwtS,
+		storageQuotaService: storageQuotaS,
 	}
 }
 
@@ -75,13 +92,28 @@ func (uc *userController) Login(ctx *gin.Context) {
 		return
 	}
 
-	res := uc.userService.VerifyLogin(ctx, userDTO.Email, userDTO.Password)
-	if !res {
+	userDTO.IP = ctx.ClientIP()
+	userDTO.UserAgent = ctx.Request.UserAgent()
+	err = uc.userService.VerifyLogin(ctx, userDTO)
+	if errors.Is(err, errs.ErrUserNotActive) {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, base.CreateFailResponse(
+			messages.MsgUserNotActive,
+			err.Error(), http.StatusForbidden,
+		))
+		return
+	} else if errors.Is(err, errs.ErrUserWrongCredential) {
 		ctx.AbortWithStatusJSON(http.StatusUnauthorized, base.CreateFailResponse(
 			messages.MsgUserWrongCredential,
 			"", http.StatusBadRequest,
 		))
 		return
+	} else if err != nil {
+		// the credentials could not be checked, which says nothing about them
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, base.CreateFailResponse(
+			messages.MsgUserLoginFailed,
+			err.Error(), http.StatusInternalServerError,
+		))
+		return
 	}
 
 	user, err := uc.userService.GetUserByPrimaryKey(ctx, constant.DBAttrEmail, userDTO.Email)
@@ -120,22 +152,41 @@ func (uc *userController) GetAllUsers(ctx *gin.Context) {
 		return
 	}
 
+	data, err := util.SelectFields(users, util.ParseQueryList(req.Fields),
+		append([]string{constant.DBAttrID}, util.ParseQueryList(req.Include)...)...)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, base.CreateFailResponse(
+			messages.MsgUsersFetchFailed,
+			err.Error(), http.StatusBadRequest,
+		))
+		return
+	}
+
 	if reflect.DeepEqual(pageMeta, base.PaginationResponse{}) {
 		ctx.JSON(http.StatusOK, base.CreateSuccessResponse(
 			messages.MsgUsersFetchSuccess,
-			http.StatusOK, users,
+			http.StatusOK, data,
 		))
 	} else {
 		ctx.JSON(http.StatusOK, base.CreatePaginatedResponse(
 			messages.MsgUsersFetchSuccess,
-			http.StatusOK, users, pageMeta,
+			http.StatusOK, data, pageMeta,
 		))
 	}
 }
 
 func (uc *userController) GetMe(ctx *gin.Context) {
+	var req base.GetRequest
+	if err := ctx.ShouldBind(&req); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, base.CreateFailResponse(
+			messages.MsgUserFetchFailed,
+			err.Error(), http.StatusBadRequest,
+		))
+		return
+	}
+
 	id := ctx.MustGet("ID").(string)
-	user, err := uc.userService.GetUserByPrimaryKey(ctx, constant.DBAttrID, id)
+	user, err := uc.userService.GetUserByID(ctx, id, req)
 	if err != nil {
 		ctx.AbortWithStatusJSON(http.StatusBadRequest, base.CreateFailResponse(
 			messages.MsgUserFetchFailed,
@@ -144,9 +195,57 @@ func (uc *userController) GetMe(ctx *gin.Context) {
 		return
 	}
 
+	data, err := util.SelectFields(user, util.ParseQueryList(req.Fields),
+		append([]string{constant.DBAttrID}, util.ParseQueryList(req.Include)...)...)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, base.CreateFailResponse(
+			messages.MsgUserFetchFailed,
+			err.Error(), http.StatusBadRequest,
+		))
+		return
+	}
+
+	ctx.Header("ETag", util.FormatETag(user.Version))
 	ctx.JSON(http.StatusOK, base.CreateSuccessResponse(
 		messages.MsgUserFetchSuccess,
-		http.StatusOK, user,
+		http.StatusOK, data,
+	))
+}
+
+func (uc *userController) GetUserByID(ctx *gin.Context) {
+	var req base.GetRequest
+	if err := ctx.ShouldBind(&req); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, base.CreateFailResponse(
+			messages.MsgUserFetchFailed,
+			err.Error(), http.StatusBadRequest,
+		))
+		return
+	}
+
+	id := ctx.Param("user_id")
+	user, err := uc.userService.GetUserByID(ctx, id, req)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, base.CreateFailResponse(
+			messages.MsgUserFetchFailed,
+			err.Error(), http.StatusBadRequest,
+		))
+		return
+	}
+
+	data, err := util.SelectFields(user, util.ParseQueryList(req.Fields),
+		append([]string{constant.DBAttrID}, util.ParseQueryList(req.Include)...)...)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, base.CreateFailResponse(
+			messages.MsgUserFetchFailed,
+			err.Error(), http.StatusBadRequest,
+		))
+		return
+	}
+
+	ctx.Header("ETag", util.FormatETag(user.Version))
+	ctx.JSON(http.StatusOK, base.CreateSuccessResponse(
+		messages.MsgUserFetchSuccess,
+		http.StatusOK, data,
 	))
 }
 
@@ -161,9 +260,17 @@ func (uc *userController) UpdateSelfName(ctx *gin.Context) {
 		return
 	}
 
+	version, ok := bindIfMatch(ctx, messages.MsgUserUpdateFailed)
+	if !ok {
+		return
+	}
+
 	id := ctx.MustGet("ID").(string)
-	user, err := uc.userService.UpdateSelfName(ctx, userDTO, id)
+	user, err := uc.userService.UpdateSelfName(ctx, userDTO, id, version)
 	if err != nil {
+		if abortOnVersionError(ctx, messages.MsgUserUpdateFailed, user, err) {
+			return
+		}
 		ctx.AbortWithStatusJSON(http.StatusBadRequest, base.CreateFailResponse(
 			messages.MsgUserUpdateFailed,
 			err.Error(), http.StatusBadRequest,
@@ -171,6 +278,7 @@ func (uc *userController) UpdateSelfName(ctx *gin.Context) {
 		return
 	}
 
+	ctx.Header("ETag", util.FormatETag(user.Version))
 	ctx.JSON(http.StatusOK, base.CreateSuccessResponse(
 		messages.MsgUserUpdateSuccess,
 		http.StatusOK, user,
@@ -181,7 +289,11 @@ func (uc *userController) UpdateUserByID(ctx *gin.Context) {
 	id := ctx.Param("user_id")
 
 	var userDTO dto.UserUpdateRequest
-	err := ctx.ShouldBind(&userDTO)
+	var fields map[string]json.RawMessage
+	err := ctx.ShouldBindBodyWith(&userDTO, binding.JSON)
+	if err == nil {
+		err = ctx.ShouldBindBodyWith(&fields, binding.JSON)
+	}
 	if err != nil {
 		ctx.AbortWithStatusJSON(http.StatusBadRequest, base.CreateFailResponse(
 			messages.MsgUserUpdateFailed,
@@ -189,9 +301,18 @@ func (uc *userController) UpdateUserByID(ctx *gin.Context) {
 		))
 		return
 	}
+	_, userDTO.PictureSet = fields["picture"]
+
+	version, ok := bindIfMatch(ctx, messages.MsgUserUpdateFailed)
+	if !ok {
+		return
+	}
 
-	user, err := uc.userService.UpdateUserByID(ctx, userDTO, id)
+	user, err := uc.userService.UpdateUserByID(ctx, userDTO, id, version)
 	if err != nil {
+		if abortOnVersionError(ctx, messages.MsgUserUpdateFailed, user, err) {
+			return
+		}
 		ctx.AbortWithStatusJSON(http.StatusBadRequest, base.CreateFailResponse(
 			messages.MsgUserUpdateFailed,
 			err.Error(), http.StatusBadRequest,
@@ -199,6 +320,52 @@ func (uc *userController) UpdateUserByID(ctx *gin.Context) {
 		return
 	}
 
+	ctx.Header("ETag", util.FormatETag(user.Version))
+	ctx.JSON(http.StatusOK, base.CreateSuccessResponse(
+		messages.MsgUserUpdateSuccess,
+		http.StatusOK, user,
+	))
+}
+
+func (uc *userController) PatchUserByID(ctx *gin.Context) {
+	id := ctx.Param("user_id")
+
+	if contentType := ctx.ContentType(); contentType != constant.MIMEMergePatchJSON &&
+		contentType != gin.MIMEJSON {
+		ctx.AbortWithStatusJSON(http.StatusUnsupportedMediaType, base.CreateFailResponse(
+			messages.MsgUserUpdateFailed,
+			errs.ErrUnsupportedContentType.Error(), http.StatusUnsupportedMediaType,
+		))
+		return
+	}
+
+	patch, err := ctx.GetRawData()
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, base.CreateFailResponse(
+			messages.MsgUserUpdateFailed,
+			err.Error(), http.StatusBadRequest,
+		))
+		return
+	}
+
+	version, ok := bindIfMatch(ctx, messages.MsgUserUpdateFailed)
+	if !ok {
+		return
+	}
+
+	user, err := uc.userService.PatchUserByID(ctx, patch, id, version)
+	if err != nil {
+		if abortOnVersionError(ctx, messages.MsgUserUpdateFailed, user, err) {
+			return
+		}
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, base.CreateFailResponse(
+			messages.MsgUserUpdateFailed,
+			err.Error(), http.StatusBadRequest,
+		))
+		return
+	}
+
+	ctx.Header("ETag", util.FormatETag(user.Version))
 	ctx.JSON(http.StatusOK, base.CreateSuccessResponse(
 		messages.MsgUserUpdateSuccess,
 		http.StatusOK, user,
@@ -206,9 +373,17 @@ func (uc *userController) UpdateUserByID(ctx *gin.Context) {
 }
 
 func (uc *userController) DeleteSelfUser(ctx *gin.Context) {
+	version, ok := bindIfMatch(ctx, messages.MsgUserDeleteFailed)
+	if !ok {
+		return
+	}
+
 	id := ctx.MustGet("ID").(string)
-	err := uc.userService.DeleteUserByID(ctx, id)
+	user, err := uc.userService.DeleteUserByID(ctx, id, version)
 	if err != nil {
+		if abortOnVersionError(ctx, messages.MsgUserDeleteFailed, user, err) {
+			return
+		}
 		ctx.AbortWithStatusJSON(http.StatusBadRequest, base.CreateFailResponse(
 			messages.MsgUserDeleteFailed,
 			err.Error(), http.StatusBadRequest,
@@ -223,9 +398,17 @@ func (uc *userController) DeleteSelfUser(ctx *gin.Context) {
 }
 
 func (uc *userController) DeleteUserByID(ctx *gin.Context) {
+	version, ok := bindIfMatch(ctx, messages.MsgUserDeleteFailed)
+	if !ok {
+		return
+	}
+
 	id := ctx.Param("user_id")
-	err := uc.userService.DeleteUserByID(ctx, id)
+	user, err := uc.userService.DeleteUserByID(ctx, id, version)
 	if err != nil {
+		if abortOnVersionError(ctx, messages.MsgUserDeleteFailed, user, err) {
+			return
+		}
 		ctx.AbortWithStatusJSON(http.StatusBadRequest, base.CreateFailResponse(
 			messages.MsgUserDeleteFailed,
 			err.Error(), http.StatusBadRequest,
@@ -242,44 +425,268 @@ func (uc *userController) DeleteUserByID(ctx *gin.Context) {
 func (uc *userController) ChangePicture(ctx *gin.Context) {
 	id := ctx.MustGet("ID").(string)
 
-	var userDTO dto.UserChangePictureRequest
+	version, ok := bindIfMatch(ctx, messages.MsgUserPictureUpdateFailed)
+	if !ok {
+		return
+	}
+
+	// a picture uploaded beforehand through a resumable upload is attached
+	if ctx.ContentType() == gin.MIMEJSON {
+		var attachDTO dto.UserAttachPictureRequest
+		if err := ctx.ShouldBindJSON(&attachDTO); err != nil {
+			abortOnUploadError(ctx, messages.MsgUserPictureUpdateFailed, err)
+			return
+		}
+
+		res, err := uc.userService.AttachPicture(ctx, attachDTO, id, version)
+		respondPictureChange(ctx, res, err)
+		return
+	}
+
+	picture, err := util.NextFormFile(ctx.Request, "picture")
+	if err != nil {
+		abortOnUploadError(ctx, messages.MsgUserPictureUpdateFailed, err)
+		return
+	}
+	defer picture.Close()
+
+	userDTO := dto.UserChangePictureRequest{
+		Filename:    picture.FileName(),
+		ContentType: picture.Header.Get("Content-Type"),
+		Picture:     picture,
+	}
+
+	res, err := uc.userService.ChangePicture(ctx, userDTO, id, version)
+	respondPictureChange(ctx, res, err)
+}
+
+func respondPictureChange(ctx *gin.Context, res dto.UserResponse, err error) {
+	if err != nil {
+		if abortOnVersionError(ctx, messages.MsgUserPictureUpdateFailed, res, err) {
+			return
+		}
+		abortOnUploadError(ctx, messages.MsgUserPictureUpdateFailed, err)
+		return
+	}
+
+	ctx.Header("ETag", util.FormatETag(res.Version))
+	ctx.JSON(http.StatusOK, base.CreateSuccessResponse(
+		messages.MsgUserPictureUpdateSuccess,
+		http.StatusOK, res,
+	))
+}
+
+func (uc *userController) DeletePicture(ctx *gin.Context) {
+	version, ok := bindIfMatch(ctx, messages.MsgUserPictureDeleteFailed)
+	if !ok {
+		return
+	}
+
+	id := ctx.Param("user_id")
+	user, err := uc.userService.DeletePicture(ctx, id, version)
+	if err != nil {
+		if abortOnVersionError(ctx, messages.MsgUserPictureDeleteFailed, user, err) {
+			return
+		}
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, base.CreateFailResponse(
+			messages.MsgUserPictureDeleteFailed,
+			err.Error(), http.StatusBadRequest,
+		))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, base.CreateSuccessResponse(
+		messages.MsgUserPictureDeleteSuccess,
+		http.StatusOK, nil,
+	))
+}
+
+func (uc *userController) ChangeUserStatus(ctx *gin.Context) {
+	id := ctx.Param("user_id")
+
+	var userDTO dto.UserStatusUpdateRequest
 	err := ctx.ShouldBind(&userDTO)
 	if err != nil {
 		ctx.AbortWithStatusJSON(http.StatusBadRequest, base.CreateFailResponse(
-			messages.MsgUserPictureUpdateFailed,
+			messages.MsgUserStatusUpdateFailed,
 			err.Error(), http.StatusBadRequest,
 		))
 		return
 	}
 
-	res, err := uc.userService.ChangePicture(ctx, userDTO, id)
+	version, ok := bindIfMatch(ctx, messages.MsgUserStatusUpdateFailed)
+	if !ok {
+		return
+	}
+
+	actorID := ctx.MustGet("ID").(string)
+	user, err := uc.userService.ChangeUserStatus(ctx, userDTO, id, actorID, version)
 	if err != nil {
+		if abortOnVersionError(ctx, messages.MsgUserStatusUpdateFailed, user, err) {
+			return
+		}
 		ctx.AbortWithStatusJSON(http.StatusBadRequest, base.CreateFailResponse(
-			messages.MsgUserPictureUpdateFailed,
+			messages.MsgUserStatusUpdateFailed,
 			err.Error(), http.StatusBadRequest,
 		))
 		return
 	}
 
+	ctx.Header("ETag", util.FormatETag(user.Version))
 	ctx.JSON(http.StatusOK, base.CreateSuccessResponse(
-		messages.MsgUserPictureUpdateSuccess,
-		http.StatusOK, res,
+		messages.MsgUserStatusUpdateSuccess,
+		http.StatusOK, user,
 	))
 }
 
-func (uc *userController) DeletePicture(ctx *gin.Context) {
+func (uc *userController) GetUserStatusHistory(ctx *gin.Context) {
 	id := ctx.Param("user_id")
-	err := uc.userService.DeletePicture(ctx, id)
+	logs, err := uc.userService.GetUserStatusHistory(ctx, id)
 	if err != nil {
 		ctx.AbortWithStatusJSON(http.StatusBadRequest, base.CreateFailResponse(
-			messages.MsgUserPictureDeleteFailed,
+			messages.MsgUserStatusHistoryFetchFailed,
 			err.Error(), http.StatusBadRequest,
 		))
 		return
 	}
 
 	ctx.JSON(http.StatusOK, base.CreateSuccessResponse(
-		messages.MsgUserPictureDeleteSuccess,
-		http.StatusOK, nil,
+		messages.MsgUserStatusHistoryFetchSuccess,
+		http.StatusOK, logs,
+	))
+}
+
+func (uc *userController) GetMyLoginEvents(ctx *gin.Context) {
+	uc.getLoginEvents(ctx, ctx.MustGet("ID").(string))
+}
+
+func (uc *userController) GetLoginEventsByUserID(ctx *gin.Context) {
+	uc.getLoginEvents(ctx, ctx.Param("user_id"))
+}
+
+func (uc *userController) getLoginEvents(ctx *gin.Context, userID string) {
+	var req base.GetsRequest
+	if err := ctx.ShouldBind(&req); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, base.CreateFailResponse(
+			messages.MsgUserLoginsFetchFailed,
+			err.Error(), http.StatusBadRequest,
+		))
+		return
+	}
+
+	events, pageMeta, err := uc.userService.GetLoginEvents(ctx, userID, req)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, base.CreateFailResponse(
+			messages.MsgUserLoginsFetchFailed,
+			err.Error(), http.StatusBadRequest,
+		))
+		return
+	}
+
+	if reflect.DeepEqual(pageMeta, base.PaginationResponse{}) {
+		ctx.JSON(http.StatusOK, base.CreateSuccessResponse(
+			messages.MsgUserLoginsFetchSuccess,
+			http.StatusOK, events,
+		))
+	} else {
+		ctx.JSON(http.StatusOK, base.CreatePaginatedResponse(
+			messages.MsgUserLoginsFetchSuccess,
+			http.StatusOK, events, pageMeta,
+		))
+	}
+}
+
+func (uc *userController) GetMyStorage(ctx *gin.Context) {
+	uc.getStorage(ctx, ctx.MustGet("ID").(string))
+}
+
+func (uc *userController) GetStorageByUserID(ctx *gin.Context) {
+	uc.getStorage(ctx, ctx.Param("user_id"))
+}
+
+func (uc *userController) getStorage(ctx *gin.Context, userID string) {
+	usage, err := uc.storageQuotaService.GetStorageUsage(ctx, userID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, base.CreateFailResponse(
+			messages.MsgUserStorageFetchFailed,
+			err.Error(), http.StatusBadRequest,
+		))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, base.CreateSuccessResponse(
+		messages.MsgUserStorageFetchSuccess,
+		http.StatusOK, usage,
 	))
 }
+
+func (uc *userController) UpdateStorageQuota(ctx *gin.Context) {
+	var quotaDTO dto.StorageQuotaUpdateRequest
+	if err := ctx.ShouldBindJSON(&quotaDTO); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, base.CreateFailResponse(
+			messages.MsgUserStorageQuotaFailed,
+			err.Error(), http.StatusBadRequest,
+		))
+		return
+	}
+
+	usage, err := uc.storageQuotaService.SetStorageQuota(ctx, quotaDTO, ctx.Param("user_id"))
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, base.CreateFailResponse(
+			messages.MsgUserStorageQuotaFailed,
+			err.Error(), http.StatusBadRequest,
+		))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, base.CreateSuccessResponse(
+		messages.MsgUserStorageQuotaSuccess,
+		http.StatusOK, usage,
+	))
+}
+
+// bindIfMatch reads the expected version from the If-Match header, aborting
+// the request when the header is malformed.
+func bindIfMatch(ctx *gin.Context, msg string) (uint, bool) {
+	version, err := util.ParseIfMatch(ctx.GetHeader("If-Match"))
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, base.CreateFailResponse(
+			msg, err.Error(), http.StatusBadRequest,
+		))
+		return 0, false
+	}
+	return version, true
+}
+
+// abortOnVersionError responds with the current representation of the user
+// when a mutation was rejected because of its version, 412 when the If-Match
+// precondition failed and 409 when a concurrent write won the race.
+func abortOnVersionError(ctx *gin.Context, msg string, user dto.UserResponse, err error) bool {
+	var status int
+	switch {
+	case errors.Is(err, errs.ErrPreconditionFailed):
+		status = http.StatusPreconditionFailed
+	case errors.Is(err, errs.ErrVersionConflict):
+		status = http.StatusConflict
+	default:
+		return false
+	}
+
+	ctx.Header("ETag", util.FormatETag(user.Version))
+	ctx.AbortWithStatusJSON(status, base.CreateFailResponseWithData(
+		msg, err.Error(), uint(status), user,
+	))
+	return true
+}
+
+// abortOnUploadError aborts a failed upload with 413 when the request body
+// went past its size limit or the storage quota, and with 400 otherwise.
+func abortOnUploadError(ctx *gin.Context, msg string, err error) {
+	status := http.StatusBadRequest
+	if util.IsBodyTooLarge(err) || errors.Is(err, errs.ErrStorageQuotaExceeded) ||
+		errors.Is(err, errs.ErrUserPictureTooLarge) {
+		status = http.StatusRequestEntityTooLarge
+	}
+
+	ctx.AbortWithStatusJSON(status, base.CreateFailResponse(msg, err.Error(), uint(status)))
+}
@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zetsux/gin-gorm-clean-starter/core/helper/dto"
+	errs "github.com/zetsux/gin-gorm-clean-starter/core/helper/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func TestAbortOnVersionError(t *testing.T) {
+	current := dto.UserResponse{ID: "a5e0f0c8-8e59-4a8b-9d8c-46b1a0e6d9a1", Name: "current", Version: 7}
+
+	for _, tt := range []struct {
+		name    string
+		err     error
+		aborted bool
+		status  int
+	}{
+		{"stale If-Match", errs.ErrPreconditionFailed, true, http.StatusPreconditionFailed},
+		{"lost version race", errs.ErrVersionConflict, true, http.StatusConflict},
+		{"wrapped version race", errors.Join(errors.New("commit"), errs.ErrVersionConflict), true, http.StatusConflict},
+		{"other error", errs.ErrUserNotFound, false, 0},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(w)
+
+			if aborted := abortOnVersionError(ctx, "failed", current, tt.err); aborted != tt.aborted {
+				t.Fatalf("abortOnVersionError returned %v, want %v", aborted, tt.aborted)
+			}
+			if !tt.aborted {
+				if ctx.IsAborted() {
+					t.Errorf("the request was aborted for an error unrelated to versions")
+				}
+				return
+			}
+
+			if w.Code != tt.status {
+				t.Errorf("responded with %d, want %d", w.Code, tt.status)
+			}
+			if etag := w.Header().Get("ETag"); etag != `"7"` {
+				t.Errorf("responded with the ETag %s, want the current version", etag)
+			}
+
+			var resp struct {
+				Data dto.UserResponse `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decoding the response: %v", err)
+			}
+			if resp.Data.ID != current.ID || resp.Data.Name != current.Name || resp.Data.Version != current.Version {
+				t.Errorf("responded with %+v, want the current representation", resp.Data)
+			}
+		})
+	}
+}
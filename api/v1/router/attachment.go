@@ -0,0 +1,34 @@
+package router
+
+import (
+	"github.com/zetsux/gin-gorm-clean-starter/api/v1/controller"
+	"github.com/zetsux/gin-gorm-clean-starter/common/constant"
+	"github.com/zetsux/gin-gorm-clean-starter/common/middleware"
+	"github.com/zetsux/gin-gorm-clean-starter/core/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AttachmentRouter mounts the attachment routes of a resource under
+// /:<ownerParam>/attachments of its routes, ownerParam being the path
+// parameter the resource routes identify the owner with, attachments being
+// uploaded in requests of up to maxUploadSize bytes.
+func AttachmentRouter(routes *gin.RouterGroup, ownerParam string, attachmentC controller.AttachmentController,
+	jwtS service.JWTService, userS service.UserService, maxUploadSize int64) {
+	attachmentRoutes := routes.Group("/:" + ownerParam + "/attachments")
+	{
+		// user routes, the policy of the resource deciding what each user can do
+		attachmentRoutes.GET("", middleware.Authenticate(jwtS, userS, constant.EnumRoleUser), attachmentC.GetAttachments)
+		attachmentRoutes.POST("",
+			middleware.LimitBodySize(maxUploadSize),
+			middleware.Authenticate(jwtS, userS, constant.EnumRoleUser), attachmentC.CreateAttachment)
+		attachmentRoutes.PUT("/order",
+			middleware.Authenticate(jwtS, userS, constant.EnumRoleUser), attachmentC.ReorderAttachments)
+		attachmentRoutes.GET("/:attachment_id",
+			middleware.Authenticate(jwtS, userS, constant.EnumRoleUser), attachmentC.GetAttachment)
+		attachmentRoutes.PATCH("/:attachment_id",
+			middleware.Authenticate(jwtS, userS, constant.EnumRoleUser), attachmentC.UpdateAttachment)
+		attachmentRoutes.DELETE("/:attachment_id",
+			middleware.Authenticate(jwtS, userS, constant.EnumRoleUser), attachmentC.DeleteAttachment)
+	}
+}
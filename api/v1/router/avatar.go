@@ -0,0 +1,16 @@
+package router
+
+import (
+	"github.com/zetsux/gin-gorm-clean-starter/api/v1/controller"
+	"github.com/zetsux/gin-gorm-clean-starter/common/constant"
+
+	"github.com/gin-gonic/gin"
+)
+
+func AvatarRouter(router *gin.Engine, avatarC controller.AvatarController) {
+	avatarRoutes := router.Group(constant.AvatarRoutePrefix)
+	{
+		// public routes
+		avatarRoutes.GET("/:user_id", avatarC.GetAvatar)
+	}
+}
@@ -3,11 +3,25 @@ package router
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/zetsux/gin-gorm-clean-starter/api/v1/controller"
+	"github.com/zetsux/gin-gorm-clean-starter/common/constant"
+	"github.com/zetsux/gin-gorm-clean-starter/common/middleware"
+	"github.com/zetsux/gin-gorm-clean-starter/core/service"
 )
 
-func FileRouter(route *gin.Engine, fileController controller.FileController) {
-	routes := route.Group("/api/v1/files")
+func FileRouter(route *gin.Engine, fileController controller.FileController,
+	jwtS service.JWTService, userS service.UserService) {
+	routes := route.Group(constant.FileRoutePrefix)
 	{
+		// admin routes
+		routes.GET("", middleware.Authenticate(jwtS, userS, constant.EnumRoleAdmin), fileController.GetAllFiles)
+		routes.GET("/garbage", middleware.Authenticate(jwtS, userS, constant.EnumRoleAdmin), fileController.GetGarbageFiles)
+		routes.GET("/:dir/:file_id/metadata",
+			middleware.Authenticate(jwtS, userS, constant.EnumRoleAdmin), fileController.GetFileMetadata)
+		routes.DELETE("/:dir/:file_id",
+			middleware.Authenticate(jwtS, userS, constant.EnumRoleAdmin), fileController.DeleteFile)
+
+		// public routes
 		routes.GET("/:dir/:file_id", fileController.GetFile)
+		routes.HEAD("/:dir/:file_id", fileController.GetFile)
 	}
 }
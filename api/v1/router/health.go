@@ -0,0 +1,13 @@
+package router
+
+import (
+	"github.com/zetsux/gin-gorm-clean-starter/api/v1/controller"
+
+	"github.com/gin-gonic/gin"
+)
+
+func HealthRouter(router *gin.Engine, healthC controller.HealthController) {
+	// public routes, probed by the orchestrator
+	router.GET("/healthz", healthC.GetLiveness)
+	router.GET("/readyz", healthC.GetReadiness)
+}
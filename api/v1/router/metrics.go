@@ -0,0 +1,13 @@
+package router
+
+import (
+	"github.com/zetsux/gin-gorm-clean-starter/api/v1/controller"
+	"github.com/zetsux/gin-gorm-clean-starter/common/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func MetricsRouter(router *gin.Engine, metricsC controller.MetricsController, token string) {
+	// scraped by prometheus, guarded by the token when one is set
+	router.GET("/metrics", middleware.AuthenticateToken(token), metricsC.GetMetrics)
+}
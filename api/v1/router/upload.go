@@ -0,0 +1,28 @@
+package router
+
+import (
+	"github.com/zetsux/gin-gorm-clean-starter/api/v1/controller"
+	"github.com/zetsux/gin-gorm-clean-starter/common/constant"
+	"github.com/zetsux/gin-gorm-clean-starter/common/middleware"
+	"github.com/zetsux/gin-gorm-clean-starter/core/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func UploadRouter(router *gin.Engine, uploadC controller.UploadController,
+	jwtS service.JWTService, userS service.UserService) {
+	uploadRoutes := router.Group(constant.UploadRoutePrefix, middleware.TusResumable())
+	{
+		// public routes
+		uploadRoutes.OPTIONS("", uploadC.GetOptions)
+
+		// user routes
+		uploadRoutes.POST("", middleware.Authenticate(jwtS, userS, constant.EnumRoleUser), uploadC.CreateUpload)
+		uploadRoutes.HEAD("/:upload_id",
+			middleware.Authenticate(jwtS, userS, constant.EnumRoleUser), uploadC.GetUploadOffset)
+		uploadRoutes.PATCH("/:upload_id",
+			middleware.Authenticate(jwtS, userS, constant.EnumRoleUser), uploadC.WriteChunk)
+		uploadRoutes.DELETE("/:upload_id",
+			middleware.Authenticate(jwtS, userS, constant.EnumRoleUser), uploadC.DeleteUpload)
+	}
+}
@@ -4,26 +4,47 @@ import (
 	"github.com/zetsux/gin-gorm-clean-starter/api/v1/controller"
 	"github.com/zetsux/gin-gorm-clean-starter/common/constant"
 	"github.com/zetsux/gin-gorm-clean-starter/common/middleware"
+	"github.com/zetsux/gin-gorm-clean-starter/config"
 	"github.com/zetsux/gin-gorm-clean-starter/core/service"
 
 	"github.com/gin-gonic/gin"
 )
 
-func UserRouter(router *gin.Engine, userC controller.UserController, jwtS service.JWTService) {
+func UserRouter(router *gin.Engine, userC controller.UserController, userAttachmentC controller.AttachmentController,
+	jwtS service.JWTService, userS service.UserService, uploadCfg config.UploadConfig) {
 	userRoutes := router.Group("/api/v1/users")
 	{
 		// admin routes
-		userRoutes.GET("", middleware.Authenticate(jwtS, constant.EnumRoleAdmin), userC.GetAllUsers)
-		userRoutes.PATCH("/:user_id", middleware.Authenticate(jwtS, constant.EnumRoleAdmin), userC.UpdateUserByID)
-		userRoutes.DELETE("/:user_id", middleware.Authenticate(jwtS, constant.EnumRoleAdmin), userC.DeleteUserByID)
+		userRoutes.GET("", middleware.Authenticate(jwtS, userS, constant.EnumRoleAdmin), userC.GetAllUsers)
+		userRoutes.GET("/:user_id", middleware.Authenticate(jwtS, userS, constant.EnumRoleAdmin), userC.GetUserByID)
+		userRoutes.PUT("/:user_id", middleware.Authenticate(jwtS, userS, constant.EnumRoleAdmin), userC.UpdateUserByID)
+		userRoutes.PATCH("/:user_id", middleware.Authenticate(jwtS, userS, constant.EnumRoleAdmin), userC.PatchUserByID)
+		userRoutes.DELETE("/:user_id", middleware.Authenticate(jwtS, userS, constant.EnumRoleAdmin), userC.DeleteUserByID)
+		userRoutes.PATCH("/:user_id/status",
+			middleware.Authenticate(jwtS, userS, constant.EnumRoleAdmin), userC.ChangeUserStatus)
+		userRoutes.GET("/:user_id/status/history",
+			middleware.Authenticate(jwtS, userS, constant.EnumRoleAdmin), userC.GetUserStatusHistory)
+		userRoutes.GET("/:user_id/logins",
+			middleware.Authenticate(jwtS, userS, constant.EnumRoleAdmin), userC.GetLoginEventsByUserID)
+		userRoutes.GET("/:user_id/storage",
+			middleware.Authenticate(jwtS, userS, constant.EnumRoleAdmin), userC.GetStorageByUserID)
+		userRoutes.PUT("/:user_id/storage",
+			middleware.Authenticate(jwtS, userS, constant.EnumRoleAdmin), userC.UpdateStorageQuota)
 
 		// user routes
-		userRoutes.GET("/me", middleware.Authenticate(jwtS, constant.EnumRoleUser), userC.GetMe)
-		userRoutes.PATCH("/me/name", middleware.Authenticate(jwtS, constant.EnumRoleUser), userC.UpdateSelfName)
-		userRoutes.DELETE("/me", middleware.Authenticate(jwtS, constant.EnumRoleUser), userC.DeleteSelfUser)
+		userRoutes.GET("/me", middleware.Authenticate(jwtS, userS, constant.EnumRoleUser), userC.GetMe)
+		userRoutes.GET("/me/logins", middleware.Authenticate(jwtS, userS, constant.EnumRoleUser), userC.GetMyLoginEvents)
+		userRoutes.GET("/me/storage", middleware.Authenticate(jwtS, userS, constant.EnumRoleUser), userC.GetMyStorage)
+		userRoutes.PATCH("/me/name", middleware.Authenticate(jwtS, userS, constant.EnumRoleUser), userC.UpdateSelfName)
+		userRoutes.DELETE("/me", middleware.Authenticate(jwtS, userS, constant.EnumRoleUser), userC.DeleteSelfUser)
 		userRoutes.POST("", userC.Register)
 		userRoutes.POST("/login", userC.Login)
-		userRoutes.PATCH("/picture", middleware.Authenticate(jwtS, constant.EnumRoleUser), userC.ChangePicture)
-		userRoutes.DELETE("/picture/:user_id", middleware.Authenticate(jwtS, constant.EnumRoleUser), userC.DeletePicture)
+		userRoutes.PATCH("/picture",
+			middleware.LimitBodySize(uploadCfg.MaxPictureSize),
+			middleware.Authenticate(jwtS, userS, constant.EnumRoleUser), userC.ChangePicture)
+		userRoutes.DELETE("/picture/:user_id",
+			middleware.Authenticate(jwtS, userS, constant.EnumRoleUser), userC.DeletePicture)
 	}
+
+	AttachmentRouter(userRoutes, "user_id", userAttachmentC, jwtS, userS, uploadCfg.MaxAttachmentSize)
 }
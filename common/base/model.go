@@ -7,6 +7,7 @@ import (
 )
 
 type Model struct {
+	Version   uint           `gorm:"not null;default:1" json:"version"`
 	CreatedAt time.Time      `json:"createdAt"`
 	UpdatedAt time.Time      `json:"updatedAt"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"deletedAt"`
@@ -5,4 +5,11 @@ type GetsRequest struct {
 	Sort    string `json:"sort" form:"sort"`
 	Page    int    `json:"page" form:"page"`
 	PerPage int    `json:"per_page" form:"per_page"`
+	Fields  string `json:"fields" form:"fields"`
+	Include string `json:"include" form:"include"`
+}
+
+type GetRequest struct {
+	Fields  string `json:"fields" form:"fields"`
+	Include string `json:"include" form:"include"`
 }
@@ -27,6 +27,12 @@ func CreateFailResponse(msg string, err string, statusCode uint) Response {
 	}
 }
 
+func CreateFailResponseWithData(msg string, err string, statusCode uint, d any) Response {
+	return Response{
+		IsSuccess: false, Message: msg, Error: err, Status: statusCode, Data: d,
+	}
+}
+
 func CreateSuccessResponse(msg string, statusCode uint, d any) Response {
 	return Response{
 		IsSuccess: true, Message: msg, Status: statusCode, Data: d,
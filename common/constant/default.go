@@ -1,7 +1,97 @@
 package constant
 
+import "time"
+
 const (
-	FileBasePath = "files"
+	FileBasePath    = "files"
+	FileRoutePrefix = "/api/v1/files"
+	// FileVariantDir is the storage directory holding the variants of files
+	FileVariantDir = "variants"
+	// FileQuarantineDir is the storage directory holding the content which did
+	// not pass the upload scan
+	FileQuarantineDir = "quarantine"
+	// FileStagingDir is the storage directory holding uploaded content until it
+	// is scanned, which is never served
+	FileStagingDir = "staging"
+	// FileCacheDir is the storage directory holding the cached transformations of files
+	FileCacheDir = "cache"
+	// FileTransformMaxSize is the maximum size in bytes of a file read to be transformed
+	FileTransformMaxSize = 32 << 20
+
+	// FileURLExpiry is how long the signed file URLs put in responses stay valid
+	FileURLExpiry = time.Hour
+
+	// PictureDir is the directory user pictures are stored in
+	PictureDir = "user_picture"
+	// PictureMaxSide is the maximum width and height in pixels of a picture
+	PictureMaxSide = 8192
+	// PictureMaxPixels is the maximum width times height of a picture, bounding
+	// the memory a decoded picture takes (4 bytes per pixel)
+	PictureMaxPixels = 16 << 20
+	// DefaultPictureMaxSize is the default maximum size in bytes of a picture upload request
+	DefaultPictureMaxSize = 5 << 20
+
+	// AttachmentDir is the directory attachments are stored in
+	AttachmentDir = "attachment"
+	// DefaultAttachmentMaxSize is the default maximum size in bytes of an attachment upload request
+	DefaultAttachmentMaxSize = 25 << 20
+
+	AvatarRoutePrefix = "/api/v1/avatars"
+	// DefaultAvatarSize is the side in pixels avatars are rendered at by default
+	DefaultAvatarSize = 256
+	AvatarFormatSVG   = "svg"
+	MIMEImageSVG      = "image/svg+xml"
+
+	UploadRoutePrefix = "/api/v1/uploads"
+	// UploadChunkDir is the storage directory holding the chunks of resumable uploads
+	UploadChunkDir = "uploads"
+	// UploadExpiry is how long a resumable upload can be resumed after its creation
+	UploadExpiry = 24 * time.Hour
+	// UploadClaimLease is how long an upload being attached or swept is left
+	// to whoever claimed it
+	UploadClaimLease = 15 * time.Minute
+	// ExpiredUploadBatchSize is how many expired uploads are swept at once
+	ExpiredUploadBatchSize = 100
+	// DefaultUploadMaxSize is the default maximum size in bytes of a resumable upload
+	DefaultUploadMaxSize = 100 << 20
+
+	TusVersion    = "1.0.0"
+	TusExtensions = "creation,expiration,termination"
+	MIMETusChunk  = "application/offset+octet-stream"
+
+	// FileDeletionLease is how long a queued file deletion is left to whoever took it
+	FileDeletionLease = 5 * time.Minute
+	// FileDeletionInterval is how often queued file deletions are processed
+	FileDeletionInterval = time.Minute
+	// FileDeletionBatchSize is how many queued file deletions are processed at once
+	FileDeletionBatchSize = 100
+	// FileDeletionRetryDelay doubles on every failed attempt up to FileDeletionMaxRetryDelay
+	FileDeletionRetryDelay    = time.Minute
+	FileDeletionMaxRetryDelay = 6 * time.Hour
+
+	// DefaultUserStorageQuota is the default storage quota in bytes of users
+	DefaultUserStorageQuota = 100 << 20
+	// UnlimitedStorageQuota is the storage quota of whoever has no limit
+	UnlimitedStorageQuota = -1
+	// StorageReservationExpiry is how long storage stays reserved for an upload
+	// which neither completes nor fails, such as one cut off by a crash
+	StorageReservationExpiry = time.Hour
+
+	// GarbageCollectionMinAge is the default age under which unreferenced files
+	// are left alone, as they may still be about to be referenced
+	GarbageCollectionMinAge = 24 * time.Hour
+
+	// HealthCheckTimeout is how long a readiness check of a dependency may take
+	HealthCheckTimeout = 2 * time.Second
+	// HealthCheckKey is the storage key the storage readiness check writes to,
+	// the same key every time so that a check cut off between its writes leaves
+	// at most one object behind
+	HealthCheckKey = "health/probe"
 
 	DefaultPaginationPerPage = 10
+
+	// LastSeenThrottle is the minimum interval between two writes of a user's last seen time
+	LastSeenThrottle = 5 * time.Minute
+
+	MIMEMergePatchJSON = "application/merge-patch+json"
 )
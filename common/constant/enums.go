@@ -4,6 +4,29 @@ const (
 	EnumRoleAdmin = "admin"
 	EnumRoleUser  = "user"
 
-	DBAttrID    = "id"
-	DBAttrEmail = "email"
+	EnumStatusActive    = "active"
+	EnumStatusPending   = "pending"
+	EnumStatusSuspended = "suspended"
+	EnumStatusBanned    = "banned"
+
+	EnumLoginFailureWrongCredential = "wrong_credential"
+	EnumLoginFailureNotActive       = "not_active"
+	EnumLoginFailureError           = "error"
+
+	EnumScanStatusClean    = "clean"
+	EnumScanStatusInfected = "infected"
+	EnumScanStatusFailed   = "failed"
+	EnumScanStatusSkipped  = "skipped"
+
+	EnumHealthStatusUp   = "up"
+	EnumHealthStatusDown = "down"
+
+	EnumAttachmentOwnerUser = "user"
+
+	EnumAttachmentVisibilityPublic  = "public"
+	EnumAttachmentVisibilityPrivate = "private"
+
+	DBAttrID      = "id"
+	DBAttrEmail   = "email"
+	DBAttrVersion = "version"
 )
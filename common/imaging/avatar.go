@@ -0,0 +1,116 @@
+package imaging
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strings"
+)
+
+// identiconGrid is the number of cells on each side of an identicon, whose
+// left half is mirrored onto its right half.
+const identiconGrid = 5
+
+var identiconBackground = color.RGBA{R: 0xF0, G: 0xF0, B: 0xF0, A: 0xFF}
+
+// Identicon is a symmetric pattern of cells derived from a seed, so that the
+// same seed always gets the same avatar.
+type Identicon struct {
+	cells [identiconGrid][identiconGrid]bool
+	color color.RGBA
+}
+
+func NewIdenticon(seed string) Identicon {
+	sum := sha256.Sum256([]byte(seed))
+
+	var identicon Identicon
+	hue := float64(int(sum[0])<<8|int(sum[1])) / 65536 * 360
+	identicon.color = hslToRGB(hue, 0.55, 0.55)
+
+	bit := 16
+	for y := 0; y < identiconGrid; y++ {
+		for x := 0; x < (identiconGrid+1)/2; x++ {
+			on := sum[bit/8]&(1<<(bit%8)) != 0
+			identicon.cells[y][x], identicon.cells[y][identiconGrid-1-x] = on, on
+			bit++
+		}
+	}
+	return identicon
+}
+
+// Image renders the identicon as a square of size pixels, with a margin of
+// half a cell around the grid.
+func (i Identicon) Image(size int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: identiconBackground}, image.Point{}, draw.Src)
+
+	// cell edges are rounded separately so that the grid spans the whole image
+	edge := func(n int) int {
+		return (2*n + 1) * size / (2*identiconGrid + 2)
+	}
+
+	fill := &image.Uniform{C: i.color}
+	for y, row := range i.cells {
+		for x, on := range row {
+			if on {
+				draw.Draw(img, image.Rect(edge(x), edge(y), edge(x+1), edge(y+1)), fill, image.Point{}, draw.Src)
+			}
+		}
+	}
+	return img
+}
+
+// SVG renders the identicon as an SVG document of size pixels.
+func (i Identicon) SVG(size int) []byte {
+	var path strings.Builder
+	for y, row := range i.cells {
+		for x, on := range row {
+			if on {
+				fmt.Fprintf(&path, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+
+	return []byte(fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%[1]d" height="%[1]d" `+
+		`viewBox="-0.5 -0.5 %[2]d %[2]d" shape-rendering="crispEdges">`+
+		`<rect x="-0.5" y="-0.5" width="%[2]d" height="%[2]d" fill="%[3]s"/>`+
+		`<path fill="%[4]s" d="%[5]s"/></svg>`,
+		size, identiconGrid+1, hexColor(identiconBackground), hexColor(i.color), path.String()))
+}
+
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// hslToRGB converts a color from its hue in degrees, saturation and lightness.
+func hslToRGB(h, s, l float64) color.RGBA {
+	c := (1 - math.Abs(2*l-1)) * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := l - c/2
+
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g, b = c, x, 0
+	case h < 120:
+		r, g, b = x, c, 0
+	case h < 180:
+		r, g, b = 0, c, x
+	case h < 240:
+		r, g, b = 0, x, c
+	case h < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+
+	return color.RGBA{
+		R: uint8((r + m) * 255),
+		G: uint8((g + m) * 255),
+		B: uint8((b + m) * 255),
+		A: 0xFF,
+	}
+}
@@ -0,0 +1,213 @@
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	errs "github.com/zetsux/gin-gorm-clean-starter/core/helper/errors"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+	FormatWebP = "webp"
+
+	// FitCover fills the box, cropping what overflows it
+	FitCover = "cover"
+	// FitContain fits within the box, keeping the whole image
+	FitContain = "contain"
+	// FitFill stretches to the box, ignoring the aspect ratio
+	FitFill = "fill"
+
+	// jpegQuality is the quality re-encoded JPEG images are written with
+	jpegQuality = 85
+)
+
+// Image is a decoded image along with the format it was decoded from.
+type Image struct {
+	image.Image
+	Format string
+}
+
+// IsSupported tells whether images of the given content type can be decoded.
+func IsSupported(contentType string) bool {
+	switch contentType {
+	case "image/jpeg", "image/png", "image/webp":
+		return true
+	}
+	return false
+}
+
+// Sniff detects the format of an image from its magic bytes, only JPEG, PNG
+// and WebP images are recognized.
+func Sniff(head []byte) (string, bool) {
+	switch {
+	case bytes.HasPrefix(head, []byte{0xFF, 0xD8, 0xFF}):
+		return FormatJPEG, true
+	case bytes.HasPrefix(head, []byte("\x89PNG\r\n\x1a\n")):
+		return FormatPNG, true
+	case len(head) >= 12 && bytes.Equal(head[:4], []byte("RIFF")) && bytes.Equal(head[8:12], []byte("WEBP")):
+		return FormatWebP, true
+	}
+	return "", false
+}
+
+// Decode decodes an image recognized by Sniff, images with a side longer than
+// maxSide or more than maxPixels pixels are rejected before being decoded.
+// JPEG images are turned upright following their EXIF orientation, as their
+// metadata is not kept.
+func Decode(data []byte, maxSide int, maxPixels int) (Image, error) {
+	format, ok := Sniff(data)
+	if !ok {
+		return Image{}, errs.ErrImageUnsupported
+	}
+
+	decodeConfig, decode := image.DecodeConfig, image.Decode
+	if format == FormatWebP {
+		decodeConfig = func(r io.Reader) (image.Config, string, error) {
+			config, err := webp.DecodeConfig(r)
+			return config, FormatWebP, err
+		}
+		decode = func(r io.Reader) (image.Image, string, error) {
+			img, err := webp.Decode(r)
+			return img, FormatWebP, err
+		}
+	}
+
+	config, _, err := decodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, errs.ErrImageInvalid
+	}
+
+	if config.Width <= 0 || config.Height <= 0 {
+		return Image{}, errs.ErrImageInvalid
+	}
+
+	if config.Width > maxSide || config.Height > maxSide {
+		return Image{}, fmt.Errorf("%w: %dx%d exceeds %dx%d",
+			errs.ErrImageTooLarge, config.Width, config.Height, maxSide, maxSide)
+	}
+
+	if int64(config.Width)*int64(config.Height) > int64(maxPixels) {
+		return Image{}, fmt.Errorf("%w: %dx%d exceeds %d pixels",
+			errs.ErrImageTooLarge, config.Width, config.Height, maxPixels)
+	}
+
+	img, _, err := decode(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, errs.ErrImageInvalid
+	}
+
+	oriented := toNRGBA(img)
+	if format == FormatJPEG {
+		oriented = orient(oriented, jpegOrientation(data))
+	}
+	return Image{Image: oriented, Format: format}, nil
+}
+
+// Thumbnail crops the center square of img and scales it down to size, images
+// smaller than size are only cropped.
+func Thumbnail(img image.Image, size int) image.Image {
+	return Resize(img, size, size, FitCover)
+}
+
+// Resize scales img into a width by height box following fit, a zero width or
+// height is derived from the other one keeping the aspect ratio. Images are
+// never scaled up, so the result can be smaller than the box.
+func Resize(img image.Image, width int, height int, fit string) image.Image {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	switch {
+	case width <= 0 && height <= 0:
+		width, height = srcW, srcH
+	case width <= 0:
+		width = atLeastOne(srcW * height / srcH)
+	case height <= 0:
+		height = atLeastOne(srcH * width / srcW)
+	}
+
+	crop := bounds
+	switch fit {
+	case FitCover:
+		// the center of the image with the aspect ratio of the box is kept
+		cropW, cropH := srcW, srcW*height/width
+		if cropH > srcH {
+			cropW, cropH = srcH*width/height, srcH
+		}
+		crop = image.Rect(0, 0, atLeastOne(cropW), atLeastOne(cropH)).Add(image.Pt(
+			bounds.Min.X+(srcW-cropW)/2,
+			bounds.Min.Y+(srcH-cropH)/2,
+		))
+		if width > crop.Dx() {
+			width, height = crop.Dx(), crop.Dy()
+		}
+	case FitContain:
+		if srcW*height > srcH*width {
+			height = atLeastOne(srcH * width / srcW)
+		} else {
+			width = atLeastOne(srcW * height / srcH)
+		}
+		if width > srcW {
+			width, height = srcW, srcH
+		}
+	default:
+		if width > srcW {
+			width = srcW
+		}
+		if height > srcH {
+			height = srcH
+		}
+	}
+
+	scaled := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(scaled, scaled.Bounds(), img, crop, draw.Src, nil)
+	return scaled
+}
+
+// Encode writes img as a PNG when it comes from one or is not opaque, and as a
+// JPEG otherwise, returning the content type it was written with.
+func Encode(w io.Writer, img image.Image, format string) (string, error) {
+	opaque, ok := img.(interface{ Opaque() bool })
+	if format == FormatPNG || (ok && !opaque.Opaque()) {
+		return "image/png", png.Encode(w, img)
+	}
+	return "image/jpeg", jpeg.Encode(w, img, &jpeg.Options{Quality: jpegQuality})
+}
+
+// EncodeAs writes img in the given format, returning its content type.
+func EncodeAs(w io.Writer, img image.Image, format string) (string, error) {
+	switch format {
+	case FormatJPEG:
+		return "image/jpeg", jpeg.Encode(w, img, &jpeg.Options{Quality: jpegQuality})
+	case FormatPNG:
+		return "image/png", png.Encode(w, img)
+	case FormatWebP:
+		return "image/webp", EncodeWebP(w, img)
+	}
+	return "", errs.ErrImageUnsupported
+}
+
+func atLeastOne(v int) int {
+	if v < 1 {
+		return 1
+	}
+	return v
+}
+
+func toNRGBA(img image.Image) *image.NRGBA {
+	if nrgba, ok := img.(*image.NRGBA); ok && nrgba.Rect.Min == (image.Point{}) {
+		return nrgba
+	}
+
+	bounds := img.Bounds()
+	nrgba := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(nrgba, nrgba.Bounds(), img, bounds.Min, draw.Src)
+	return nrgba
+}
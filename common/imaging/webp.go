@@ -0,0 +1,222 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"io"
+)
+
+// The WebP encoder below writes lossless (VP8L) images in the simplest valid
+// form, every pixel is a literal coded with fixed length prefix codes. It
+// trades size for simplicity, as no WebP encoder comes with the standard or
+// the extended image libraries.
+
+const (
+	vp8lSignature = 0x2f
+
+	// vp8lGreenAlphabet holds the green literals, the backward reference
+	// lengths and no color cache entries
+	vp8lGreenAlphabet = 256 + 24
+	vp8lByteAlphabet  = 256
+)
+
+// vp8lCodeLengthOrder is the order code length code lengths are written in.
+var vp8lCodeLengthOrder = [...]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+type bitWriter struct {
+	buf   bytes.Buffer
+	acc   uint64
+	nbits uint
+}
+
+// writeBits writes the n lowest bits of v, least significant bit first.
+func (bw *bitWriter) writeBits(v uint32, n uint) {
+	bw.acc |= uint64(v) << bw.nbits
+	bw.nbits += n
+	for bw.nbits >= 8 {
+		bw.buf.WriteByte(byte(bw.acc))
+		bw.acc >>= 8
+		bw.nbits -= 8
+	}
+}
+
+// writeCode writes a prefix code, which is read starting from its most
+// significant bit.
+func (bw *bitWriter) writeCode(code uint32, length uint) {
+	for i := int(length) - 1; i >= 0; i-- {
+		bw.writeBits((code>>uint(i))&1, 1)
+	}
+}
+
+func (bw *bitWriter) bytes() []byte {
+	if bw.nbits > 0 {
+		bw.buf.WriteByte(byte(bw.acc))
+		bw.acc, bw.nbits = 0, 0
+	}
+	return bw.buf.Bytes()
+}
+
+// prefixCode is a canonical prefix code built from the code length of each
+// symbol, a code with a single symbol takes no bits at all.
+type prefixCode struct {
+	lengths []uint
+	codes   []uint32
+	single  bool
+}
+
+func newPrefixCode(lengths []uint) prefixCode {
+	pc := prefixCode{lengths: lengths, codes: make([]uint32, len(lengths))}
+
+	used := 0
+	var maxLength uint
+	for _, length := range lengths {
+		if length > 0 {
+			used++
+		}
+		if length > maxLength {
+			maxLength = length
+		}
+	}
+	pc.single = used == 1
+
+	var code uint32
+	for length := uint(1); length <= maxLength; length++ {
+		for symbol, symbolLength := range lengths {
+			if symbolLength == length {
+				pc.codes[symbol] = code
+				code++
+			}
+		}
+		code <<= 1
+	}
+	return pc
+}
+
+func (pc prefixCode) write(bw *bitWriter, symbol int) {
+	if pc.single {
+		return
+	}
+	bw.writeCode(pc.codes[symbol], pc.lengths[symbol])
+}
+
+// writeSimpleCode writes a prefix code made of a single 8 bit symbol.
+func writeSimpleCode(bw *bitWriter, symbol uint32) {
+	bw.writeBits(1, 1) // simple code
+	bw.writeBits(0, 1) // one symbol
+	bw.writeBits(1, 1) // eight bits symbol
+	bw.writeBits(symbol, 8)
+}
+
+// writeNormalCode writes the code lengths of a prefix code, themselves coded
+// with a code length code.
+func writeNormalCode(bw *bitWriter, lengths []uint) {
+	lengthCounts := make([]uint, 19)
+	for _, length := range lengths {
+		lengthCounts[length] = 1
+	}
+	lengthCode := newPrefixCode(lengthCounts)
+
+	last := 0
+	for i, symbol := range vp8lCodeLengthOrder {
+		if lengthCounts[symbol] > 0 {
+			last = i
+		}
+	}
+	if last < 3 {
+		last = 3
+	}
+
+	bw.writeBits(0, 1) // normal code
+	bw.writeBits(uint32(last+1-4), 4)
+	for _, symbol := range vp8lCodeLengthOrder[:last+1] {
+		bw.writeBits(uint32(lengthCounts[symbol]), 3)
+	}
+
+	bw.writeBits(0, 1) // code lengths are given for the whole alphabet
+	for _, length := range lengths {
+		lengthCode.write(bw, int(length))
+	}
+}
+
+// fixedLengths spreads an alphabet over 8 and 9 bit codes, so that the code
+// is complete.
+func fixedLengths(alphabet int) []uint {
+	lengths := make([]uint, alphabet)
+	short := 512 - alphabet
+	for symbol := range lengths {
+		lengths[symbol] = 9
+		if symbol < short {
+			lengths[symbol] = 8
+		}
+	}
+	return lengths
+}
+
+// EncodeWebP writes img as a lossless WebP image.
+func EncodeWebP(w io.Writer, img image.Image) error {
+	nrgba := toNRGBA(img)
+	width, height := nrgba.Rect.Dx(), nrgba.Rect.Dy()
+
+	opaque := nrgba.Opaque()
+	bw := &bitWriter{}
+	bw.writeBits(vp8lSignature, 8)
+	bw.writeBits(uint32(width-1), 14)
+	bw.writeBits(uint32(height-1), 14)
+	if opaque {
+		bw.writeBits(0, 1)
+	} else {
+		bw.writeBits(1, 1)
+	}
+	bw.writeBits(0, 3) // version
+	bw.writeBits(0, 1) // no transform
+	bw.writeBits(0, 1) // no color cache
+	bw.writeBits(0, 1) // no meta prefix codes
+
+	greenLengths, byteLengths := fixedLengths(vp8lGreenAlphabet), fixedLengths(vp8lByteAlphabet)
+	green, byteCode := newPrefixCode(greenLengths), newPrefixCode(byteLengths)
+
+	writeNormalCode(bw, greenLengths)
+	writeNormalCode(bw, byteLengths) // red
+	writeNormalCode(bw, byteLengths) // blue
+	if opaque {
+		writeSimpleCode(bw, 0xff)
+	} else {
+		writeNormalCode(bw, byteLengths)
+	}
+	writeSimpleCode(bw, 0) // distance, unused
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			offset := nrgba.PixOffset(x, y)
+			pixel := nrgba.Pix[offset : offset+4]
+			green.write(bw, int(pixel[1]))
+			byteCode.write(bw, int(pixel[0]))
+			byteCode.write(bw, int(pixel[2]))
+			if !opaque {
+				byteCode.write(bw, int(pixel[3]))
+			}
+		}
+	}
+
+	payload := bw.bytes()
+	padding := len(payload) % 2
+
+	header := make([]byte, 20)
+	copy(header[0:], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(4+8+len(payload)+padding))
+	copy(header[8:], "WEBPVP8L")
+	binary.LittleEndian.PutUint32(header[16:], uint32(len(payload)))
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if _, err := w.Write(payload); err != nil {
+		return err
+	}
+	if padding > 0 {
+		_, err := w.Write([]byte{0})
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+
+	"golang.org/x/image/webp"
+)
+
+func TestEncodeWebPRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	noise := image.NewNRGBA(image.Rect(0, 0, 67, 31))
+	rng.Read(noise.Pix)
+
+	uniform := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+	for i := 0; i < len(uniform.Pix); i += 4 {
+		copy(uniform.Pix[i:], []byte{0x12, 0x34, 0x56, 0xFF})
+	}
+
+	gradient := image.NewNRGBA(image.Rect(0, 0, 256, 3))
+	for x := 0; x < 256; x++ {
+		for y := 0; y < 3; y++ {
+			gradient.SetNRGBA(x, y, color.NRGBA{R: uint8(x), G: uint8(255 - x), B: uint8(x * y), A: uint8(x | 1)})
+		}
+	}
+
+	offset := image.NewNRGBA(image.Rect(5, 7, 14, 12))
+	rng.Read(offset.Pix)
+
+	for name, img := range map[string]*image.NRGBA{
+		"noise":    noise,
+		"uniform":  uniform,
+		"gradient": gradient,
+		"offset":   offset,
+		"pixel":    image.NewNRGBA(image.Rect(0, 0, 1, 1)),
+	} {
+		var buf bytes.Buffer
+		if err := EncodeWebP(&buf, img); err != nil {
+			t.Fatalf("%s: EncodeWebP: %v", name, err)
+		}
+
+		if format, ok := Sniff(buf.Bytes()); !ok || format != FormatWebP {
+			t.Errorf("%s: encoded image sniffed as %q, want %q", name, format, FormatWebP)
+		}
+
+		decoded, err := webp.Decode(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatalf("%s: decoding: %v", name, err)
+		}
+
+		bounds := img.Bounds()
+		if decoded.Bounds().Dx() != bounds.Dx() || decoded.Bounds().Dy() != bounds.Dy() {
+			t.Fatalf("%s: decoded %v, want a %dx%d image", name, decoded.Bounds(), bounds.Dx(), bounds.Dy())
+		}
+
+		origin := decoded.Bounds().Min
+		for y := 0; y < bounds.Dy(); y++ {
+			for x := 0; x < bounds.Dx(); x++ {
+				want := img.NRGBAAt(bounds.Min.X+x, bounds.Min.Y+y)
+				got := color.NRGBAModel.Convert(decoded.At(origin.X+x, origin.Y+y)).(color.NRGBA)
+				if got != want {
+					t.Fatalf("%s: pixel (%d, %d) decoded as %v, want %v", name, x, y, got, want)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// gormLevels maps the SQL log levels to the ones of GORM.
+var gormLevels = map[string]gormlogger.LogLevel{
+	"silent": gormlogger.Silent,
+	"error":  gormlogger.Error,
+	"warn":   gormlogger.Warn,
+	"info":   gormlogger.Info,
+}
+
+// explainedPlaceholder matches the placeholders which GORM leaves in logged
+// queries when they come without parameters, e.g. $1$.
+var explainedPlaceholder = regexp.MustCompile(`\$(\d+)\$`)
+
+// ParseGormLevel parses one of silent, error, warn or info.
+func ParseGormLevel(s string) (gormlogger.LogLevel, error) {
+	level, ok := gormLevels[s]
+	if !ok {
+		return 0, fmt.Errorf("unknown SQL log level %q", s)
+	}
+	return level, nil
+}
+
+type gormLogger struct {
+	level         gormlogger.LogLevel
+	slowThreshold time.Duration
+	params        bool
+}
+
+// NewGormLogger logs the queries through slog: failed ones as errors, the
+// ones slower than slowThreshold (never when zero) as warnings and every one
+// at the info level. The parameters of the queries are only logged along with
+// them when params is set, password hashes being redacted anyway.
+func NewGormLogger(level gormlogger.LogLevel, slowThreshold time.Duration, params bool) gormlogger.Interface {
+	return &gormLogger{
+		level:         level,
+		slowThreshold: slowThreshold,
+		params:        params,
+	}
+}
+
+func (l *gormLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	logger := *l
+	logger.level = level
+	return &logger
+}
+
+func (l *gormLogger) Info(ctx context.Context, msg string, data ...any) {
+	if l.level >= gormlogger.Info {
+		slog.InfoContext(ctx, fmt.Sprintf(msg, data...))
+	}
+}
+
+func (l *gormLogger) Warn(ctx context.Context, msg string, data ...any) {
+	if l.level >= gormlogger.Warn {
+		slog.WarnContext(ctx, fmt.Sprintf(msg, data...))
+	}
+}
+
+func (l *gormLogger) Error(ctx context.Context, msg string, data ...any) {
+	if l.level >= gormlogger.Error {
+		slog.ErrorContext(ctx, fmt.Sprintf(msg, data...))
+	}
+}
+
+func (l *gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.level <= gormlogger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	attrs := func() []any {
+		sql, rows := fc()
+		if !l.params {
+			sql = explainedPlaceholder.ReplaceAllString(sql, "$$$1")
+		}
+		return []any{
+			"sql", sql,
+			"rows", rows,
+			"duration_ms", float64(elapsed.Microseconds()) / 1000,
+		}
+	}
+
+	switch {
+	case err != nil && l.level >= gormlogger.Error && !errors.Is(err, gormlogger.ErrRecordNotFound):
+		slog.ErrorContext(ctx, "SQL query failed", append(attrs(), "error", err)...)
+	case l.slowThreshold > 0 && elapsed > l.slowThreshold && l.level >= gormlogger.Warn:
+		slog.WarnContext(ctx, "Slow SQL query", attrs()...)
+	case l.level >= gormlogger.Info:
+		slog.InfoContext(ctx, "SQL query", attrs()...)
+	}
+}
+
+// ParamsFilter drops the parameters of the logged queries unless they are
+// wanted, in which case only the password hashes are redacted.
+func (l *gormLogger) ParamsFilter(_ context.Context, sql string, params ...any) (string, []any) {
+	if !l.params {
+		return sql, nil
+	}
+
+	filtered := make([]any, len(params))
+	for i, param := range params {
+		filtered[i] = param
+		if s, ok := param.(string); ok {
+			if _, err := bcrypt.Cost([]byte(s)); err == nil {
+				filtered[i] = Redacted
+			}
+		}
+	}
+	return sql, filtered
+}
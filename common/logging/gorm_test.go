@@ -0,0 +1,102 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func passwordHash(t *testing.T) string {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte("hunter2"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("GenerateFromPassword: %v", err)
+	}
+	return string(hash)
+}
+
+func TestGormLoggerParamsFilter(t *testing.T) {
+	hash := passwordHash(t)
+	params := []any{"user@mail.test", hash, 42, "$2a$not-a-hash"}
+
+	for _, tt := range []struct {
+		name   string
+		params bool
+		want   []any
+	}{
+		{"with params", true, []any{"user@mail.test", Redacted, 42, "$2a$not-a-hash"}},
+		{"without params", false, nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := NewGormLogger(gormlogger.Info, 0, tt.params).(gorm.ParamsFilter)
+
+			sql, got := logger.ParamsFilter(context.Background(), "INSERT INTO users VALUES ($1,$2,$3,$4)", params...)
+			if sql != "INSERT INTO users VALUES ($1,$2,$3,$4)" {
+				t.Errorf("ParamsFilter changed the query to %s", sql)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParamsFilter returned %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// loggedUser is a model holding a password hash to be logged.
+type loggedUser struct {
+	Email    string
+	Password string
+}
+
+func TestGormLoggerLogsQueries(t *testing.T) {
+	hash := passwordHash(t)
+
+	for _, tt := range []struct {
+		name     string
+		params   bool
+		logged   []string
+		unlogged []string
+	}{
+		{"with params", true, []string{"user@mail.test", Redacted}, []string{hash}},
+		{"without params", false, []string{"$1", "$2"}, []string{hash, "user@mail.test", Redacted}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			defer slog.SetDefault(slog.Default())
+			slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+
+			db, err := gorm.Open(postgres.Open("host=localhost"), &gorm.Config{
+				DryRun:                 true,
+				DisableAutomaticPing:   true,
+				SkipDefaultTransaction: true,
+				Logger:                 NewGormLogger(gormlogger.Info, 0, tt.params),
+			})
+			if err != nil {
+				t.Fatalf("opening the database: %v", err)
+			}
+			db.Create(&loggedUser{Email: "user@mail.test", Password: hash})
+
+			logged := buf.String()
+			if !strings.Contains(logged, "INSERT INTO") {
+				t.Fatalf("the query was not logged: %s", logged)
+			}
+			for _, s := range tt.logged {
+				if !strings.Contains(logged, s) {
+					t.Errorf("%q was not logged: %s", s, logged)
+				}
+			}
+			for _, s := range tt.unlogged {
+				if strings.Contains(logged, s) {
+					t.Errorf("%q was logged: %s", s, logged)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestLoggerRedactsSensitiveAttributes(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, slog.LevelInfo, FormatJSON)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	logger.InfoContext(WithRequestID(context.Background(), "request-1"), "Signing in",
+		"password", "hunter2",
+		"new_Password", "hunter3",
+		"token", "eyJhbGciOi",
+		"refresh_token", "r3fr3sh",
+		"Authorization", "Bearer eyJhbGciOi",
+		"client_secret", "s3cr3t",
+		slog.Group("request", "authorization", "Bearer nested"),
+		"email", "user@mail.test",
+	)
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("decoding %s: %v", buf.String(), err)
+	}
+
+	for _, key := range []string{"password", "new_Password", "token", "refresh_token", "Authorization", "client_secret"} {
+		if record[key] != Redacted {
+			t.Errorf("%s was logged as %v, want %s", key, record[key], Redacted)
+		}
+	}
+	if request, _ := record["request"].(map[string]any); request["authorization"] != Redacted {
+		t.Errorf("the grouped authorization was logged as %v, want %s", request["authorization"], Redacted)
+	}
+
+	if record["email"] != "user@mail.test" || record["request_id"] != "request-1" {
+		t.Errorf("the other attributes were not logged as is: %v", record)
+	}
+	for _, secret := range []string{"hunter", "eyJhbGciOi", "r3fr3sh", "s3cr3t", "nested"} {
+		if bytes.Contains(buf.Bytes(), []byte(secret)) {
+			t.Errorf("%q was logged: %s", secret, buf.String())
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry holds every metric of the app along with the Go runtime and
+// process ones. The labels of the metrics only ever take a bounded set of
+// values (route templates, enums, tables...), never IDs or raw paths.
+var Registry = prometheus.NewRegistry()
+
+var factory = promauto.With(Registry)
+
+var (
+	HTTPRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests handled, by method, route template and status code.",
+	}, []string{"method", "route", "status"})
+	HTTPRequestDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Time taken to handle HTTP requests, by method and route template.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	DBQueryDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "db_query_duration_seconds",
+		Help:    "Time taken by database queries, by operation and table.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"operation", "table"})
+	DBQueryErrors = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "db_query_errors_total",
+		Help: "Failed database queries, by operation and table.",
+	}, []string{"operation", "table"})
+
+	Logins = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_logins_total",
+		Help: "Login attempts, by result (success or the failure reason).",
+	}, []string{"result"})
+	Registrations = factory.NewCounter(prometheus.CounterOpts{
+		Name: "auth_registrations_total",
+		Help: "Users who registered.",
+	})
+
+	FileUploads = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "file_uploads_total",
+		Help: "Files stored, by directory.",
+	}, []string{"dir"})
+	FileUploadedBytes = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "file_uploaded_bytes_total",
+		Help: "Bytes of the files stored, by directory.",
+	}, []string{"dir"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler serves the metrics of Registry in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
@@ -1,18 +1,20 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"slices"
 	"strings"
 
 	"github.com/zetsux/gin-gorm-clean-starter/common/base"
 	"github.com/zetsux/gin-gorm-clean-starter/common/constant"
+	errs "github.com/zetsux/gin-gorm-clean-starter/core/helper/errors"
 	"github.com/zetsux/gin-gorm-clean-starter/core/service"
 
 	"github.com/gin-gonic/gin"
 )
 
-func Authenticate(jwtService service.JWTService, roles ...string) gin.HandlerFunc {
+func Authenticate(jwtService service.JWTService, userService service.UserService, roles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -50,7 +52,20 @@ func Authenticate(jwtService service.JWTService, roles ...string) gin.HandlerFun
 			c.AbortWithStatusJSON(http.StatusForbidden, response)
 			return
 		}
+
+		// status is checked on every request so that suspensions apply to issued tokens
+		err = userService.AuthenticateUser(c, idRes)
+		if errors.Is(err, errs.ErrUserNotActive) {
+			response := base.CreateFailResponse("Account is not active", "", http.StatusForbidden)
+			c.AbortWithStatusJSON(http.StatusForbidden, response)
+			return
+		} else if err != nil {
+			response := base.CreateFailResponse("Invalid token", "", http.StatusUnauthorized)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, response)
+			return
+		}
 		c.Set("ID", idRes)
+		c.Set("ROLE", roleRes)
 		c.Next()
 	}
 }
@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/zetsux/gin-gorm-clean-starter/common/base"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LimitBodySize rejects requests declaring a body larger than limit bytes
+// right away, and stops reading the body of the others once past the limit.
+func LimitBodySize(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > limit {
+			response := base.CreateFailResponse("Request body too large", "", http.StatusRequestEntityTooLarge)
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, response)
+			return
+		}
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
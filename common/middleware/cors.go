@@ -12,10 +12,16 @@ func CORSMiddleware() gin.HandlerFunc {
 		c.Header("Access-Control-Allow-Credentials", "true")
 		c.Header("Access-Control-Allow-Headers",
 			"Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token,"+
-				"Authorization, accept, origin, Cache-Control, X-Requested-With")
+				"Authorization, accept, origin, Cache-Control, X-Requested-With, If-Match,"+
+				"Tus-Resumable, Upload-Length, Upload-Defer-Length, Upload-Metadata, Upload-Offset, X-Request-ID")
+		c.Header("Access-Control-Expose-Headers",
+			"ETag, Location, Tus-Resumable, Tus-Version, Tus-Extension, Tus-Max-Size,"+
+				"Upload-Length, Upload-Metadata, Upload-Offset, Upload-Expires, X-Request-ID")
 		c.Header("Access-Control-Allow-Methods", "POST, HEAD, PATCH, OPTIONS, GET, PUT, DELETE")
 
-		if c.Request.Method == http.MethodOptions {
+		// only preflight requests are answered here, as plain OPTIONS requests
+		// are used for discovery by some protocols (e.g. tus)
+		if c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != "" {
 			c.AbortWithStatus(204)
 			return
 		}
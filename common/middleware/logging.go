@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zetsux/gin-gorm-clean-starter/common/base"
+	"github.com/zetsux/gin-gorm-clean-starter/common/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// validRequestID matches the request IDs given by clients which are kept,
+// so that they cannot forge log lines
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,64}$`)
+
+// RequestID identifies each request with the ID given by the client, or a new
+// one, which is sent back and carried by the request context to be logged.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = uuid.NewString()
+		}
+
+		c.Header(requestIDHeader, id)
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+// Logger logs each request once it is handled, leaving its query out as it
+// may hold signatures. The paths in quietPaths (e.g. probes) are only logged
+// at the debug level.
+func Logger(quietPaths ...string) gin.HandlerFunc {
+	quiet := map[string]bool{}
+	for _, path := range quietPaths {
+		quiet[path] = true
+	}
+
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		case quiet[c.Request.URL.Path]:
+			level = slog.LevelDebug
+		}
+
+		attrs := []any{
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"route", c.FullPath(),
+			"status", status,
+			"duration_ms", float64(time.Since(start).Microseconds()) / 1000,
+			"bytes", c.Writer.Size(),
+			"client_ip", c.ClientIP(),
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, "error", c.Errors.String())
+		}
+		slog.Log(c.Request.Context(), level, "Request handled", attrs...)
+	}
+}
+
+// Recovery answers 500 to the requests which handling panicked, logging the
+// panic along with its stack.
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, err any) {
+		slog.ErrorContext(c.Request.Context(), "Request handling panicked",
+			"error", fmt.Sprint(err), "stack", string(debug.Stack()))
+		response := base.CreateFailResponse("Internal server error", "", http.StatusInternalServerError)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, response)
+	})
+}
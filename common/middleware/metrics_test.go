@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zetsux/gin-gorm-clean-starter/common/metrics"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func serveRequest(engine *gin.Engine, method, path string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+func TestMetrics(t *testing.T) {
+	engine := gin.New()
+	engine.Use(Metrics(), Recovery())
+	engine.GET("/metrics-test/users/:id", func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	engine.Handle("PROPFIND", "/metrics-test/users/:id", func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	engine.GET("/metrics-test/panic", func(c *gin.Context) {
+		panic("handler failure")
+	})
+
+	for _, tt := range []struct {
+		name   string
+		method string
+		path   string
+		labels []string
+	}{
+		{"route template", http.MethodGet, "/metrics-test/users/42",
+			[]string{"GET", "/metrics-test/users/:id", "204"}},
+		{"unknown method", "PROPFIND", "/metrics-test/users/42",
+			[]string{"OTHER", "/metrics-test/users/:id", "204"}},
+		{"unmatched route", http.MethodGet, "/metrics-test/missing/42",
+			[]string{"GET", "unmatched", "404"}},
+		{"panic", http.MethodGet, "/metrics-test/panic",
+			[]string{"GET", "/metrics-test/panic", "500"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			counter := metrics.HTTPRequests.WithLabelValues(tt.labels...)
+			before := testutil.ToFloat64(counter)
+
+			serveRequest(engine, tt.method, tt.path, nil)
+			if got := testutil.ToFloat64(counter) - before; got != 1 {
+				t.Errorf("the request was counted %v times under %v, want once", got, tt.labels)
+			}
+		})
+	}
+
+	// a raw path must never become a label
+	if got := testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues(
+		"GET", "/metrics-test/users/42", "204")); got != 0 {
+		t.Errorf("the raw path was used as a route label")
+	}
+}
+
+func TestAuthenticateToken(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		token  string
+		header string
+		status int
+	}{
+		{"right token", "secret", "Bearer secret", http.StatusOK},
+		{"wrong token", "secret", "Bearer other", http.StatusUnauthorized},
+		{"missing token", "secret", "", http.StatusUnauthorized},
+		{"not a bearer token", "secret", "secret", http.StatusUnauthorized},
+		{"empty bearer token", "secret", "Bearer ", http.StatusUnauthorized},
+		{"no token configured", "", "", http.StatusOK},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := gin.New()
+			engine.GET("/metrics", AuthenticateToken(tt.token), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			header := http.Header{}
+			if tt.header != "" {
+				header.Set("Authorization", tt.header)
+			}
+			if w := serveRequest(engine, http.MethodGet, "/metrics", header); w.Code != tt.status {
+				t.Errorf("the request got %d, want %d", w.Code, tt.status)
+			}
+		})
+	}
+}
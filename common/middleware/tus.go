@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/zetsux/gin-gorm-clean-starter/common/base"
+	"github.com/zetsux/gin-gorm-clean-starter/common/constant"
+	errs "github.com/zetsux/gin-gorm-clean-starter/core/helper/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TusResumable marks every response as a tus one and rejects requests made
+// for another tus version, except for OPTIONS which is used for discovery.
+func TusResumable() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Tus-Resumable", constant.TusVersion)
+
+		if c.Request.Method != http.MethodOptions && c.GetHeader("Tus-Resumable") != constant.TusVersion {
+			c.Header("Tus-Version", constant.TusVersion)
+			response := base.CreateFailResponse(errs.ErrTusVersionUnsupported.Error(), "", http.StatusPreconditionFailed)
+			c.AbortWithStatusJSON(http.StatusPreconditionFailed, response)
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,161 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/zetsux/gin-gorm-clean-starter/common/constant"
+)
+
+// clamdChunkSize is the size of the chunks content is streamed to clamd in.
+const clamdChunkSize = 64 << 10
+
+type ClamdConfig struct {
+	// Network is either "tcp" or "unix"
+	Network string
+	Address string
+	// Timeout bounds a whole scan, including connecting to clamd
+	Timeout time.Duration
+}
+
+type clamdScanner struct {
+	config ClamdConfig
+}
+
+// NewClamdScanner scans content through the INSTREAM command of a ClamAV
+// daemon, opening a connection for every scan.
+func NewClamdScanner(config ClamdConfig) Scanner {
+	if config.Network == "" {
+		config.Network = "tcp"
+	}
+	return &clamdScanner{config: config}
+}
+
+func (cs *clamdScanner) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	conn, cancel, err := cs.dial(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+	defer cancel()
+
+	// clamd replies before closing the connection when it stops reading the
+	// stream, such as once the content goes past its size limit, and the
+	// reply tells more than the failed write
+	streamErr := stream(conn, r)
+	var opErr *net.OpError
+	if streamErr != nil && !errors.As(streamErr, &opErr) {
+		return Result{}, streamErr
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil {
+		if streamErr != nil {
+			return Result{}, streamErr
+		}
+		return Result{}, err
+	}
+	return parseClamdReply(strings.TrimSuffix(reply, "\x00"))
+}
+
+// Ping sends the PING command, which clamd answers with PONG.
+func (cs *clamdScanner) Ping(ctx context.Context) error {
+	conn, cancel, err := cs.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	if _, err := io.WriteString(conn, "zPING\x00"); err != nil {
+		return err
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil {
+		return err
+	}
+	if reply = strings.TrimSuffix(reply, "\x00"); reply != "PONG" {
+		return fmt.Errorf("clamd: %s", reply)
+	}
+	return nil
+}
+
+// dial connects to clamd, the connection being bound by the timeout and by
+// the deadline of ctx until cancel is called.
+func (cs *clamdScanner) dial(ctx context.Context) (net.Conn, func(), error) {
+	cancel := func() {}
+	if cs.config.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, cs.config.Timeout)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, cs.config.Network, cs.config.Address)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			conn.Close()
+			cancel()
+			return nil, nil, err
+		}
+	}
+
+	return conn, func() {
+		conn.Close()
+		cancel()
+	}, nil
+}
+
+// stream sends the content of r as an INSTREAM command, which is a series of
+// length prefixed chunks ended by an empty one.
+func stream(w io.Writer, r io.Reader) error {
+	if _, err := io.WriteString(w, "zINSTREAM\x00"); err != nil {
+		return err
+	}
+
+	chunk := make([]byte, 4+clamdChunkSize)
+	for {
+		n, err := io.ReadFull(r, chunk[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(chunk[:4], uint32(n))
+			if _, err := w.Write(chunk[:4+n]); err != nil {
+				return err
+			}
+		}
+
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		} else if err != nil {
+			return err
+		}
+	}
+
+	_, err := w.Write([]byte{0, 0, 0, 0})
+	return err
+}
+
+// parseClamdReply reads replies such as "stream: OK" and
+// "stream: Eicar-Signature FOUND", anything else being an error of clamd.
+func parseClamdReply(reply string) (Result, error) {
+	_, verdict, _ := strings.Cut(reply, ": ")
+	switch {
+	case verdict == "OK":
+		return Result{Status: constant.EnumScanStatusClean}, nil
+	case strings.HasSuffix(verdict, " FOUND"):
+		return Result{
+			Status:    constant.EnumScanStatusInfected,
+			Signature: strings.TrimSuffix(verdict, " FOUND"),
+		}, nil
+	default:
+		return Result{}, fmt.Errorf("clamd: %s", reply)
+	}
+}
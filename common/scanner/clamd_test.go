@@ -0,0 +1,179 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zetsux/gin-gorm-clean-starter/common/constant"
+)
+
+// fakeClamd answers PING with PONG and every INSTREAM command with the reply made by answer from
+// the streamed content. Once more than sizeLimit bytes are streamed, it replies
+// with a size limit error and stops reading as clamd does.
+func fakeClamd(t *testing.T, sizeLimit int, answer func(content []byte) string) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveClamd(conn, sizeLimit, answer)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func serveClamd(conn net.Conn, sizeLimit int, answer func(content []byte) string) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	command, err := r.ReadString(0)
+	if err == nil && command == "zPING\x00" {
+		io.WriteString(conn, "PONG\x00")
+		return
+	}
+	if err != nil || command != "zINSTREAM\x00" {
+		io.WriteString(conn, "UNKNOWN COMMAND\x00")
+		return
+	}
+
+	var content []byte
+	for {
+		var size uint32
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+			return
+		}
+		if size == 0 {
+			break
+		}
+
+		chunk := make([]byte, size)
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			return
+		}
+		content = append(content, chunk...)
+
+		if len(content) > sizeLimit {
+			io.WriteString(conn, "INSTREAM size limit exceeded. ERROR\x00")
+			return
+		}
+	}
+	io.WriteString(conn, answer(content)+"\x00")
+}
+
+func TestClamdScanner(t *testing.T) {
+	eicar := `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+	var mu sync.Mutex
+	var received []byte
+	lastReceived := func() []byte {
+		mu.Lock()
+		defer mu.Unlock()
+		return received
+	}
+	addr := fakeClamd(t, 1<<20, func(content []byte) string {
+		mu.Lock()
+		received = content
+		mu.Unlock()
+		switch {
+		case bytes.Contains(content, []byte("EICAR")):
+			return "stream: Eicar-Signature FOUND"
+		case bytes.Contains(content, []byte("broken")):
+			return "stream: Can't allocate memory ERROR"
+		}
+		return "stream: OK"
+	})
+	scan := NewClamdScanner(ClamdConfig{Address: addr, Timeout: 5 * time.Second})
+
+	t.Run("Clean", func(t *testing.T) {
+		// spans a few chunks, so that they have to be put back together
+		content := strings.Repeat("clean content ", 3*clamdChunkSize/10)
+		result, err := scan.Scan(context.Background(), strings.NewReader(content))
+		if err != nil {
+			t.Fatalf("Scan: %v", err)
+		}
+		if result.Status != constant.EnumScanStatusClean {
+			t.Errorf("Scan returned %+v, want a clean result", result)
+		}
+		if got := lastReceived(); string(got) != content {
+			t.Errorf("clamd received %d bytes which differ from the %d scanned", len(got), len(content))
+		}
+	})
+
+	t.Run("Infected", func(t *testing.T) {
+		result, err := scan.Scan(context.Background(), strings.NewReader(eicar))
+		if err != nil {
+			t.Fatalf("Scan: %v", err)
+		}
+		if result.Status != constant.EnumScanStatusInfected || result.Signature != "Eicar-Signature" {
+			t.Errorf("Scan returned %+v, want an infected result with the Eicar-Signature", result)
+		}
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		result, err := scan.Scan(context.Background(), strings.NewReader("broken"))
+		if err == nil || !strings.Contains(err.Error(), "Can't allocate memory") {
+			t.Errorf("Scan returned %+v, %v, want the error of clamd", result, err)
+		}
+	})
+
+	t.Run("Ping", func(t *testing.T) {
+		if err := scan.Ping(context.Background()); err != nil {
+			t.Errorf("Ping: %v", err)
+		}
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		if _, err := scan.Scan(context.Background(), strings.NewReader("")); err != nil {
+			t.Errorf("Scan: %v", err)
+		}
+		if got := lastReceived(); len(got) != 0 {
+			t.Errorf("clamd received %d bytes, want none", len(got))
+		}
+	})
+}
+
+func TestClamdScannerSizeLimit(t *testing.T) {
+	addr := fakeClamd(t, clamdChunkSize, func([]byte) string { return "stream: OK" })
+	scan := NewClamdScanner(ClamdConfig{Address: addr, Timeout: 5 * time.Second})
+
+	content := bytes.Repeat([]byte{'x'}, 64*clamdChunkSize)
+	result, err := scan.Scan(context.Background(), bytes.NewReader(content))
+	if err == nil {
+		t.Fatalf("Scan returned %+v going past the size limit, want an error", result)
+	}
+	if result.Status == constant.EnumScanStatusClean {
+		t.Errorf("Scan reported content past the size limit as clean")
+	}
+}
+
+func TestClamdScannerUnreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	scan := NewClamdScanner(ClamdConfig{Address: addr, Timeout: time.Second})
+	if _, err := scan.Scan(context.Background(), strings.NewReader("content")); err == nil {
+		t.Errorf("Scan succeeded without clamd")
+	}
+	if err := scan.Ping(context.Background()); err == nil {
+		t.Errorf("Ping succeeded without clamd")
+	}
+}
@@ -0,0 +1,24 @@
+package scanner
+
+import (
+	"context"
+	"io"
+
+	"github.com/zetsux/gin-gorm-clean-starter/common/constant"
+)
+
+type noopScanner struct{}
+
+// NewNoopScanner accepts every content without looking at it, for setups
+// where no scanner is available.
+func NewNoopScanner() Scanner {
+	return noopScanner{}
+}
+
+func (noopScanner) Scan(_ context.Context, _ io.Reader) (Result, error) {
+	return Result{Status: constant.EnumScanStatusSkipped}, nil
+}
+
+func (noopScanner) Ping(_ context.Context) error {
+	return nil
+}
@@ -0,0 +1,27 @@
+package scanner
+
+import (
+	"context"
+	"io"
+)
+
+const (
+	DriverNone  = "none"
+	DriverClamd = "clamd"
+)
+
+// Result is the outcome of a scan, Status being one of the scan status enums
+// and Signature the name of what was found in infected content.
+type Result struct {
+	Status    string
+	Signature string
+}
+
+// Scanner checks uploaded content for malware before it is made available.
+type Scanner interface {
+	// Scan reads the whole content of r, an error means the content could not
+	// be scanned, which is not a verdict on the content itself
+	Scan(ctx context.Context, r io.Reader) (Result, error)
+	// Ping checks that the scanner is available without scanning anything
+	Ping(ctx context.Context) error
+}
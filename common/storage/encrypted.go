@@ -0,0 +1,665 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	errs "github.com/zetsux/gin-gorm-clean-starter/core/helper/errors"
+)
+
+const (
+	// encryptedMagic starts every encrypted object, objects without it are
+	// plaintext ones stored before encryption was enabled
+	encryptedMagic = "GGE1"
+	// encryptedSegmentSize is the size of the plaintext segments which are
+	// sealed on their own, so that ranges can be decrypted without the rest
+	encryptedSegmentSize = 64 << 10
+	encryptedPrefixSize  = 7
+	encryptedKeySize     = 32
+	// encryptedTagSize is the overhead AES-GCM adds to every sealed segment
+	encryptedTagSize = 16
+	// encryptedRotationDir holds the objects being rotated until they replace
+	// the original ones
+	encryptedRotationDir = "rotating"
+)
+
+var errEncryptedObjectInvalid = errors.New("encrypted object is invalid")
+
+// EncryptionConfig holds the master keys wrapping the data keys of objects,
+// new objects are encrypted with the key of CurrentKeyID while the other keys
+// are only kept to decrypt objects which were not rotated yet.
+type EncryptionConfig struct {
+	Keys         map[string][]byte
+	CurrentKeyID string
+}
+
+// KeyRotator is implemented by storages which can re-encrypt their objects
+// with the current master key.
+type KeyRotator interface {
+	// RotateKeys rewraps the data keys of objects under an older master key
+	// and encrypts plaintext objects, returning how many objects changed
+	RotateKeys(ctx context.Context) (int, error)
+}
+
+type encryptedStorage struct {
+	inner        Storage
+	masters      map[string]cipher.AEAD
+	currentKeyID string
+}
+
+// encryptedHeader starts every encrypted object. The data key is sealed with
+// the master key over every other field and the object key, so that none of
+// them can be tampered with.
+type encryptedHeader struct {
+	keyID       string
+	contentType string
+	prefix      []byte
+	wrappedKey  []byte
+}
+
+// NewEncryptedStorage encrypts the objects of inner at rest with AES-GCM, each
+// object having its own data key which is wrapped by a master key.
+func NewEncryptedStorage(inner Storage, config EncryptionConfig) (Storage, error) {
+	if _, ok := config.Keys[config.CurrentKeyID]; !ok {
+		return nil, fmt.Errorf("encryption key %q is missing", config.CurrentKeyID)
+	}
+
+	masters := map[string]cipher.AEAD{}
+	for id, key := range config.Keys {
+		if len(id) == 0 || len(id) > 255 {
+			return nil, fmt.Errorf("encryption key id %q must be 1 to 255 bytes long", id)
+		}
+
+		if len(key) != encryptedKeySize {
+			return nil, fmt.Errorf("encryption key %q must be %d bytes long", id, encryptedKeySize)
+		}
+
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		masters[id] = aead
+	}
+
+	return &encryptedStorage{
+		inner:        inner,
+		masters:      masters,
+		currentKeyID: config.CurrentKeyID,
+	}, nil
+}
+
+func (es *encryptedStorage) Put(ctx context.Context, key string,
+	r io.Reader, size int64, contentType string) (ObjectInfo, error) {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	return es.put(ctx, cleaned, cleaned, r, size, contentType)
+}
+
+// put encrypts r under storeKey, its header being bound to boundKey which is
+// the key the object is read from.
+func (es *encryptedStorage) put(ctx context.Context, storeKey string, boundKey string,
+	r io.Reader, size int64, contentType string) (ObjectInfo, error) {
+	content := bufio.NewReaderSize(r, encryptedSegmentSize)
+	if contentType == "" {
+		sniffed, _ := content.Peek(512)
+		contentType = http.DetectContentType(sniffed)
+	}
+
+	dataKey := make([]byte, encryptedKeySize)
+	prefix := make([]byte, encryptedPrefixSize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return ObjectInfo{}, err
+	}
+	if _, err := rand.Read(prefix); err != nil {
+		return ObjectInfo{}, err
+	}
+
+	header := encryptedHeader{keyID: es.currentKeyID, contentType: contentType, prefix: prefix}
+	if err := es.wrap(boundKey, &header, dataKey); err != nil {
+		return ObjectInfo{}, err
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	encrypted := &encryptReader{r: content, aead: aead, prefix: prefix}
+	headerBytes := header.marshal()
+
+	sealedSize := int64(-1)
+	if size >= 0 {
+		sealedSize = int64(len(headerBytes)) + size + segmentCount(size)*int64(aead.Overhead())
+	}
+
+	info, err := es.inner.Put(ctx, storeKey, io.MultiReader(bytes.NewReader(headerBytes), encrypted),
+		sealedSize, "application/octet-stream")
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	info.Size, info.ContentType = encrypted.read, contentType
+	return info, nil
+}
+
+func (es *encryptedStorage) Get(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error) {
+	content, info, err := es.inner.Get(ctx, key)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+
+	header, headerSize, err := readEncryptedHeader(content)
+	if errors.Is(err, errEncryptedObjectInvalid) && headerSize == 0 {
+		plain, err := rewind(content)
+		if err != nil {
+			content.Close()
+			return nil, ObjectInfo{}, err
+		}
+		return plain, info, nil
+	} else if err != nil {
+		content.Close()
+		return nil, ObjectInfo{}, err
+	}
+
+	dataKey, err := es.unwrap(info.Key, header)
+	if err != nil {
+		content.Close()
+		return nil, ObjectInfo{}, err
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		content.Close()
+		return nil, ObjectInfo{}, err
+	}
+
+	size, err := plaintextSize(info.Size - headerSize)
+	if err != nil {
+		content.Close()
+		return nil, ObjectInfo{}, err
+	}
+
+	decrypted := &decryptReader{
+		r:       content,
+		aead:    aead,
+		prefix:  header.prefix,
+		header:  headerSize,
+		sealed:  info.Size - headerSize,
+		size:    size,
+		segment: -1,
+	}
+
+	info.Size, info.ContentType = size, header.contentType
+	if _, ok := content.(io.Seeker); ok {
+		return &seekableDecryptReader{decrypted}, info, nil
+	}
+	return decrypted, info, nil
+}
+
+func (es *encryptedStorage) Delete(ctx context.Context, key string) error {
+	return es.inner.Delete(ctx, key)
+}
+
+// Stat only reads the header of the object, which tells the size of its
+// plaintext without anything being decrypted.
+func (es *encryptedStorage) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	content, info, err := es.inner.Get(ctx, key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	defer content.Close()
+
+	header, headerSize, err := readEncryptedHeader(content)
+	if errors.Is(err, errEncryptedObjectInvalid) && headerSize == 0 {
+		return info, nil
+	} else if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	size, err := plaintextSize(info.Size - headerSize)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	info.Size, info.ContentType = size, header.contentType
+	return info, nil
+}
+
+// List stats every object, so that the sizes are the ones of the plaintexts.
+// Objects deleted in the meantime are left out.
+func (es *encryptedStorage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	objects, err := es.inner.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]ObjectInfo, 0, len(objects))
+	for _, object := range objects {
+		info, err := es.Stat(ctx, object.Key)
+		if errors.Is(err, errs.ErrFileNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func (es *encryptedStorage) RotateKeys(ctx context.Context) (int, error) {
+	objects, err := es.inner.List(ctx, "")
+	if err != nil {
+		return 0, err
+	}
+
+	rotated := 0
+	for _, object := range objects {
+		// leftovers of an interrupted rotation are bound to the key they were
+		// meant to replace, so they are left for the garbage collection
+		if strings.HasPrefix(object.Key, encryptedRotationDir+"/") {
+			continue
+		}
+
+		changed, err := es.rotate(ctx, object.Key)
+		if err != nil {
+			return rotated, fmt.Errorf("rotating %s: %w", object.Key, err)
+		}
+		if changed {
+			rotated++
+		}
+	}
+	return rotated, nil
+}
+
+// rotate rewrites the object under key with its data key wrapped by the
+// current master key, leaving the sealed segments untouched. The rotated
+// object is written aside first, so that the object is never written while
+// it is being read, then it replaces the object unless the object was deleted
+// or rewritten in the meantime. A deletion or a write landing between that
+// last check and the replacement is still overwritten by it.
+func (es *encryptedStorage) rotate(ctx context.Context, key string) (bool, error) {
+	tempKey := encryptedRotationDir + "/" + uuid.NewString()
+	source, changed, err := es.rotateInto(ctx, key, tempKey)
+	if errors.Is(err, errs.ErrFileNotFound) {
+		return false, nil
+	} else if err != nil || !changed {
+		return false, err
+	}
+
+	defer es.inner.Delete(ctx, tempKey)
+
+	current, err := es.inner.Stat(ctx, key)
+	if errors.Is(err, errs.ErrFileNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	// a rewritten object is already encrypted with the current master key
+	if current.Size != source.Size || !current.LastModified.Equal(source.LastModified) {
+		return false, nil
+	}
+
+	content, info, err := es.inner.Get(ctx, tempKey)
+	if err != nil {
+		return false, err
+	}
+	defer content.Close()
+
+	if _, err := es.inner.Put(ctx, key, content, info.Size, "application/octet-stream"); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// rotateInto writes the object under key rotated to tempKey, unless it is
+// already encrypted with the current master key, returning what the object
+// was when it was read.
+func (es *encryptedStorage) rotateInto(ctx context.Context,
+	key string, tempKey string) (ObjectInfo, bool, error) {
+	content, info, err := es.inner.Get(ctx, key)
+	if err != nil {
+		return ObjectInfo{}, false, err
+	}
+	defer content.Close()
+
+	header, headerSize, err := readEncryptedHeader(content)
+	if errors.Is(err, errEncryptedObjectInvalid) && headerSize == 0 {
+		plain, err := rewind(content)
+		if err != nil {
+			return ObjectInfo{}, false, err
+		}
+		_, err = es.put(ctx, tempKey, info.Key, plain, info.Size, info.ContentType)
+		return info, err == nil, err
+	} else if err != nil {
+		return ObjectInfo{}, false, err
+	}
+
+	if header.keyID == es.currentKeyID {
+		return ObjectInfo{}, false, nil
+	}
+
+	dataKey, err := es.unwrap(info.Key, header)
+	if err != nil {
+		return ObjectInfo{}, false, err
+	}
+
+	header.keyID = es.currentKeyID
+	if err := es.wrap(info.Key, &header, dataKey); err != nil {
+		return ObjectInfo{}, false, err
+	}
+
+	headerBytes := header.marshal()
+	_, err = es.inner.Put(ctx, tempKey, io.MultiReader(bytes.NewReader(headerBytes), content),
+		info.Size-headerSize+int64(len(headerBytes)), "application/octet-stream")
+	return info, err == nil, err
+}
+
+func (es *encryptedStorage) wrap(key string, header *encryptedHeader, dataKey []byte) error {
+	master := es.masters[header.keyID]
+	nonce := make([]byte, master.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	header.wrappedKey = master.Seal(nonce, nonce, dataKey, header.associatedData(key))
+	return nil
+}
+
+func (es *encryptedStorage) unwrap(key string, header encryptedHeader) ([]byte, error) {
+	master, ok := es.masters[header.keyID]
+	if !ok {
+		return nil, fmt.Errorf("encryption key %q is missing", header.keyID)
+	}
+
+	if len(header.wrappedKey) < master.NonceSize() {
+		return nil, errEncryptedObjectInvalid
+	}
+
+	nonce, sealed := header.wrappedKey[:master.NonceSize()], header.wrappedKey[master.NonceSize():]
+	dataKey, err := master.Open(nil, nonce, sealed, header.associatedData(key))
+	if err != nil {
+		return nil, errEncryptedObjectInvalid
+	}
+	return dataKey, nil
+}
+
+func (h encryptedHeader) associatedData(key string) []byte {
+	data := []byte(key + "\x00" + h.keyID + "\x00" + h.contentType + "\x00")
+	return append(data, h.prefix...)
+}
+
+func (h encryptedHeader) marshal() []byte {
+	var buf bytes.Buffer
+	buf.WriteString(encryptedMagic)
+	buf.WriteByte(byte(len(h.keyID)))
+	buf.WriteString(h.keyID)
+	_ = binary.Write(&buf, binary.BigEndian, uint16(len(h.contentType)))
+	buf.WriteString(h.contentType)
+	buf.Write(h.prefix)
+	buf.WriteByte(byte(len(h.wrappedKey)))
+	buf.Write(h.wrappedKey)
+	return buf.Bytes()
+}
+
+// readEncryptedHeader reads the header off r, returning how many bytes it
+// took. Objects not starting with the magic are reported invalid after
+// reading nothing but the magic, which leaves 0 as the header size.
+func readEncryptedHeader(r io.Reader) (encryptedHeader, int64, error) {
+	var header encryptedHeader
+
+	magic := make([]byte, len(encryptedMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != encryptedMagic {
+		return header, 0, errEncryptedObjectInvalid
+	}
+
+	field := func(size int) ([]byte, error) {
+		buf := make([]byte, size)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, errEncryptedObjectInvalid
+		}
+		return buf, nil
+	}
+
+	size, err := field(1)
+	if err != nil {
+		return header, -1, err
+	}
+	keyID, err := field(int(size[0]))
+	if err != nil {
+		return header, -1, err
+	}
+
+	size, err = field(2)
+	if err != nil {
+		return header, -1, err
+	}
+	contentType, err := field(int(binary.BigEndian.Uint16(size)))
+	if err != nil {
+		return header, -1, err
+	}
+
+	if header.prefix, err = field(encryptedPrefixSize); err != nil {
+		return header, -1, err
+	}
+
+	size, err = field(1)
+	if err != nil {
+		return header, -1, err
+	}
+	if header.wrappedKey, err = field(int(size[0])); err != nil {
+		return header, -1, err
+	}
+
+	header.keyID, header.contentType = string(keyID), string(contentType)
+	return header, int64(len(header.marshal())), nil
+}
+
+// rewind returns the whole content of r, of which at most the magic was read.
+// Every backend returns seekable content, others cannot hold plaintext objects.
+func rewind(r io.ReadCloser) (io.ReadCloser, error) {
+	seeker, ok := r.(io.ReadSeekCloser)
+	if !ok {
+		return nil, errEncryptedObjectInvalid
+	}
+
+	if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return seeker, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// segmentNonce derives the nonce of a segment from the object's random prefix,
+// the index of the segment and whether it is the last one, which keeps
+// segments from being reordered or the object from being truncated.
+func segmentNonce(prefix []byte, index int64, last bool) []byte {
+	nonce := make([]byte, 0, encryptedPrefixSize+5)
+	nonce = append(nonce, prefix...)
+	nonce = binary.BigEndian.AppendUint32(nonce, uint32(index))
+	if last {
+		return append(nonce, 1)
+	}
+	return append(nonce, 0)
+}
+
+// segmentCount is how many segments size bytes of plaintext are sealed in, an
+// empty plaintext still taking a single empty segment.
+func segmentCount(size int64) int64 {
+	if size == 0 {
+		return 1
+	}
+	return (size + encryptedSegmentSize - 1) / encryptedSegmentSize
+}
+
+// segmentCountSealed is segmentCount for sealed bytes.
+func segmentCountSealed(sealed int64, overhead int) int64 {
+	sealedSegment := int64(encryptedSegmentSize + overhead)
+	return (sealed + sealedSegment - 1) / sealedSegment
+}
+
+// plaintextSize is the size of the plaintext sealed in the given bytes, which
+// hold a single tag at least.
+func plaintextSize(sealed int64) (int64, error) {
+	if sealed < encryptedTagSize {
+		return 0, errEncryptedObjectInvalid
+	}
+	return sealed - segmentCountSealed(sealed, encryptedTagSize)*encryptedTagSize, nil
+}
+
+// encryptReader seals the content of r segment by segment.
+type encryptReader struct {
+	r      *bufio.Reader
+	aead   cipher.AEAD
+	prefix []byte
+	index  int64
+	read   int64
+	sealed []byte
+	done   bool
+}
+
+func (er *encryptReader) Read(p []byte) (int, error) {
+	for len(er.sealed) == 0 {
+		if er.done {
+			return 0, io.EOF
+		}
+
+		plain := make([]byte, encryptedSegmentSize)
+		n, err := io.ReadFull(er.r, plain)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return 0, err
+		}
+
+		// a full segment is only the last one when nothing follows it
+		if err == nil {
+			_, err = er.r.Peek(1)
+			if err != nil && err != io.EOF {
+				return 0, err
+			}
+		}
+		er.done = err != nil
+
+		er.sealed = er.aead.Seal(nil, segmentNonce(er.prefix, er.index, er.done), plain[:n], nil)
+		er.index++
+		er.read += int64(n)
+	}
+
+	n := copy(p, er.sealed)
+	er.sealed = er.sealed[n:]
+	return n, nil
+}
+
+// decryptReader opens the sealed segments following the header of r as they
+// are read, only ever holding a single segment in memory.
+type decryptReader struct {
+	r      io.ReadCloser
+	aead   cipher.AEAD
+	prefix []byte
+	// header is the size of the header, sealed the size of what follows it
+	// and size the size of the plaintext
+	header int64
+	sealed int64
+	size   int64
+	pos    int64
+	// segment is the index of the opened segment in plain, and next the index
+	// of the segment r is positioned at
+	segment int64
+	next    int64
+	plain   []byte
+}
+
+func (dr *decryptReader) Read(p []byte) (int, error) {
+	index := dr.pos / encryptedSegmentSize
+	if index >= segmentCountSealed(dr.sealed, dr.aead.Overhead()) {
+		return 0, io.EOF
+	}
+
+	if index != dr.segment {
+		if err := dr.open(index); err != nil {
+			return 0, err
+		}
+	}
+
+	if dr.pos >= dr.size {
+		return 0, io.EOF
+	}
+
+	n := copy(p, dr.plain[dr.pos-index*encryptedSegmentSize:])
+	dr.pos += int64(n)
+	return n, nil
+}
+
+func (dr *decryptReader) open(index int64) error {
+	sealedSegment := int64(encryptedSegmentSize + dr.aead.Overhead())
+	if index != dr.next {
+		seeker, ok := dr.r.(io.Seeker)
+		if !ok {
+			return errors.New("encrypted object is not seekable")
+		}
+		if _, err := seeker.Seek(dr.header+index*sealedSegment, io.SeekStart); err != nil {
+			return err
+		}
+	}
+
+	size := sealedSegment
+	if remaining := dr.sealed - index*sealedSegment; remaining < size {
+		size = remaining
+	}
+
+	sealed := make([]byte, size)
+	if _, err := io.ReadFull(dr.r, sealed); err != nil {
+		return err
+	}
+
+	last := index == segmentCountSealed(dr.sealed, dr.aead.Overhead())-1
+	plain, err := dr.aead.Open(sealed[:0], segmentNonce(dr.prefix, index, last), sealed, nil)
+	if err != nil {
+		return errEncryptedObjectInvalid
+	}
+
+	dr.plain, dr.segment, dr.next = plain, index, index+1
+	return nil
+}
+
+func (dr *decryptReader) Close() error {
+	return dr.r.Close()
+}
+
+type seekableDecryptReader struct {
+	*decryptReader
+}
+
+func (sr *seekableDecryptReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += sr.pos
+	case io.SeekEnd:
+		offset += sr.size
+	}
+
+	if offset < 0 {
+		return 0, errors.New("seeking before the start of the object")
+	}
+	sr.pos = offset
+	return offset, nil
+}
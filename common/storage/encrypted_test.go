@@ -0,0 +1,346 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+
+	errs "github.com/zetsux/gin-gorm-clean-starter/core/helper/errors"
+)
+
+func newTestEncryptedStorage(t *testing.T, inner Storage, current string, keyIDs ...string) Storage {
+	t.Helper()
+	keys := map[string][]byte{}
+	for _, id := range append(keyIDs, current) {
+		keys[id] = bytes.Repeat([]byte(id[:1]), encryptedKeySize)
+	}
+
+	store, err := NewEncryptedStorage(inner, EncryptionConfig{Keys: keys, CurrentKeyID: current})
+	if err != nil {
+		t.Fatalf("NewEncryptedStorage: %v", err)
+	}
+	return store
+}
+
+// randomContent returns size random bytes, which are the same on every run.
+func randomContent(size int) []byte {
+	content := make([]byte, size)
+	rand.New(rand.NewSource(int64(size))).Read(content)
+	return content
+}
+
+func TestEncryptedStorage(t *testing.T) {
+	testStorage(t, newTestEncryptedStorage(t, NewMemoryStorage(), "a"))
+}
+
+func TestEncryptedStorageRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	inner := NewMemoryStorage()
+	store := newTestEncryptedStorage(t, inner, "a")
+
+	for _, size := range []int{0, 1, encryptedSegmentSize - 1, encryptedSegmentSize,
+		encryptedSegmentSize + 1, 3*encryptedSegmentSize + 5} {
+		for _, known := range []bool{true, false} {
+			content := randomContent(size)
+			putSize := int64(-1)
+			if known {
+				putSize = int64(size)
+			}
+
+			info, err := store.Put(ctx, "round-trip", bytes.NewReader(content), putSize, "application/x-test")
+			if err != nil {
+				t.Fatalf("Put of %d bytes: %v", size, err)
+			}
+			if info.Size != int64(size) {
+				t.Errorf("Put of %d bytes returned a size of %d", size, info.Size)
+			}
+
+			if got := readAll(t, store, "round-trip"); !bytes.Equal(got, content) {
+				t.Errorf("Get of %d bytes returned %d bytes which differ", size, len(got))
+			}
+
+			// nothing of the plaintext is left in what is stored
+			stored := readAll(t, inner, "round-trip")
+			if size >= 16 && bytes.Contains(stored, content[:16]) {
+				t.Errorf("the %d bytes are stored in plaintext", size)
+			}
+
+			info, err = store.Stat(ctx, "round-trip")
+			if err != nil {
+				t.Fatalf("Stat of %d bytes: %v", size, err)
+			}
+			if info.Size != int64(size) || info.ContentType != "application/x-test" {
+				t.Errorf("Stat of %d bytes returned %+v", size, info)
+			}
+
+			infos, err := store.List(ctx, "")
+			if err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			if len(infos) != 1 || infos[0].Size != int64(size) {
+				t.Errorf("List of %d bytes returned %+v, want the plaintext size", size, infos)
+			}
+		}
+	}
+}
+
+func TestEncryptedStorageSeek(t *testing.T) {
+	ctx := context.Background()
+	store := newTestEncryptedStorage(t, NewMemoryStorage(), "a")
+
+	content := randomContent(3*encryptedSegmentSize + 100)
+	if _, err := store.Put(ctx, "seek", bytes.NewReader(content), int64(len(content)), ""); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	r, _, err := store.Get(ctx, "seek")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer r.Close()
+	seeker := r.(io.Seeker)
+
+	// reads across the boundaries of segments, backwards and forwards
+	for _, offset := range []int64{
+		2*encryptedSegmentSize - 10, encryptedSegmentSize - 1, 0, 3*encryptedSegmentSize - 50,
+		encryptedSegmentSize, int64(len(content)) - 30,
+	} {
+		if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+			t.Fatalf("Seek(%d): %v", offset, err)
+		}
+
+		got := make([]byte, 30)
+		if _, err := io.ReadFull(r, got); err != nil {
+			t.Fatalf("reading at %d: %v", offset, err)
+		}
+		if !bytes.Equal(got, content[offset:offset+30]) {
+			t.Errorf("read at %d differs from the content", offset)
+		}
+	}
+
+	if pos, err := seeker.Seek(-20, io.SeekCurrent); err != nil || pos != int64(len(content))-20 {
+		t.Errorf("Seek(-20, SeekCurrent) returned %d, %v", pos, err)
+	}
+	rest, err := io.ReadAll(r)
+	if err != nil || !bytes.Equal(rest, content[len(content)-20:]) {
+		t.Errorf("reading the end returned %d bytes, %v", len(rest), err)
+	}
+
+	if _, err := seeker.Seek(encryptedSegmentSize+7, io.SeekStart); err != nil {
+		t.Fatalf("Seek: %v", err)
+	}
+	rest, err = io.ReadAll(r)
+	if err != nil || !bytes.Equal(rest, content[encryptedSegmentSize+7:]) {
+		t.Errorf("reading from the middle returned %d bytes, %v", len(rest), err)
+	}
+}
+
+func TestEncryptedStorageRotateKeys(t *testing.T) {
+	ctx := context.Background()
+	inner := NewMemoryStorage()
+	old := newTestEncryptedStorage(t, inner, "old")
+
+	contents := map[string][]byte{
+		"rotate/encrypted": randomContent(2*encryptedSegmentSize + 3),
+		"rotate/empty":     {},
+	}
+	for key, content := range contents {
+		if _, err := old.Put(ctx, key, bytes.NewReader(content), int64(len(content)), ""); err != nil {
+			t.Fatalf("Put(%q): %v", key, err)
+		}
+	}
+
+	// stored before encryption was enabled
+	contents["rotate/plaintext"] = []byte("plaintext content")
+	putString(t, inner, "rotate/plaintext", "plaintext content")
+
+	rotating := newTestEncryptedStorage(t, inner, "new", "old")
+	rotated, err := rotating.(KeyRotator).RotateKeys(ctx)
+	if err != nil {
+		t.Fatalf("RotateKeys: %v", err)
+	}
+	if rotated != 3 {
+		t.Errorf("RotateKeys rotated %d objects, want 3", rotated)
+	}
+
+	// only the new key is needed from now on
+	current := newTestEncryptedStorage(t, inner, "new")
+	for key, content := range contents {
+		if got := readAll(t, current, key); !bytes.Equal(got, content) {
+			t.Errorf("%s differs after the rotation", key)
+		}
+	}
+
+	if got := readAll(t, inner, "rotate/plaintext"); bytes.Equal(got, contents["rotate/plaintext"]) {
+		t.Errorf("the plaintext object was not encrypted")
+	}
+
+	infos, err := inner.List(ctx, "")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(infos) != len(contents) {
+		t.Errorf("%d objects are stored after the rotation, want %d: %+v", len(infos), len(contents), infos)
+	}
+
+	if rotated, err := rotating.(KeyRotator).RotateKeys(ctx); err != nil || rotated != 0 {
+		t.Errorf("rotating again returned %d, %v, want nothing to rotate", rotated, err)
+	}
+}
+
+func TestEncryptedStorageTampering(t *testing.T) {
+	ctx := context.Background()
+	inner := NewMemoryStorage()
+	store := newTestEncryptedStorage(t, inner, "a")
+
+	content := randomContent(3*encryptedSegmentSize + 100)
+	if _, err := store.Put(ctx, "original", bytes.NewReader(content), int64(len(content)), "text/plain"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	stored := readAll(t, inner, "original")
+	r, _, err := inner.Get(ctx, "original")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	_, headerSize, err := readEncryptedHeader(r)
+	r.Close()
+	if err != nil {
+		t.Fatalf("reading the header: %v", err)
+	}
+
+	header, segments := stored[:headerSize], stored[headerSize:]
+	sealedSegment := encryptedSegmentSize + encryptedTagSize
+	segment := func(i int) []byte {
+		return segments[i*sealedSegment : (i+1)*sealedSegment]
+	}
+	join := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+
+	flipped := bytes.Clone(stored)
+	flipped[int(headerSize)+sealedSegment+10] ^= 1
+
+	contentType := bytes.Clone(stored)
+	typeAt := bytes.Index(contentType, []byte("text/plain"))
+	contentType[typeAt] = 'n'
+
+	// segments are bound to their object through its data key
+	if _, err := store.Put(ctx, "other", bytes.NewReader(content), -1, "text/plain"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	other := readAll(t, inner, "other")
+
+	for name, tampered := range map[string][]byte{
+		"flipped byte":            flipped,
+		"tampered content type":   contentType,
+		"truncated segment":       stored[:len(stored)-10],
+		"dropped last segment":    join(header, segment(0), segment(1), segment(2)),
+		"reordered segments":      join(header, segment(1), segment(0), segments[2*sealedSegment:]),
+		"duplicated segment":      join(header, segment(0), segment(0), segments[2*sealedSegment:]),
+		"header only":             header,
+		"truncated header":        header[:len(header)-5],
+		"other object's segments": join(header, other[len(other)-len(segments):]),
+	} {
+		key := "tampered/" + strings.ReplaceAll(name, " ", "-")
+		if _, err := inner.Put(ctx, key, bytes.NewReader(tampered), int64(len(tampered)), ""); err != nil {
+			t.Fatalf("Put(%q): %v", key, err)
+		}
+
+		r, _, err := store.Get(ctx, key)
+		if err == nil {
+			_, err = io.ReadAll(r)
+			r.Close()
+		}
+		if !errors.Is(err, errEncryptedObjectInvalid) {
+			t.Errorf("%s: reading returned %v, want %v", name, err, errEncryptedObjectInvalid)
+		}
+	}
+
+	// the header is bound to the key the object is stored under
+	if _, err := inner.Put(ctx, "moved", bytes.NewReader(stored), int64(len(stored)), ""); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if _, _, err := store.Get(ctx, "moved"); !errors.Is(err, errEncryptedObjectInvalid) {
+		t.Errorf("reading a moved object returned %v, want %v", err, errEncryptedObjectInvalid)
+	}
+
+	if _, _, err := store.Get(ctx, "missing"); !errors.Is(err, errs.ErrFileNotFound) {
+		t.Errorf("reading a missing object returned %v, want %v", err, errs.ErrFileNotFound)
+	}
+}
+
+// hookedStorage calls afterPut once each object is put.
+type hookedStorage struct {
+	Storage
+	afterPut func(key string)
+}
+
+func (hs *hookedStorage) Put(ctx context.Context, key string,
+	r io.Reader, size int64, contentType string) (ObjectInfo, error) {
+	info, err := hs.Storage.Put(ctx, key, r, size, contentType)
+	if err == nil && hs.afterPut != nil {
+		hs.afterPut(key)
+	}
+	return info, err
+}
+
+func TestEncryptedStorageRotateKeysKeepsConcurrentChanges(t *testing.T) {
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		name   string
+		change func(t *testing.T, store Storage)
+		want   []byte
+	}{
+		{"deleted", func(t *testing.T, store Storage) {
+			if err := store.Delete(ctx, "concurrent"); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+		}, nil},
+		{"rewritten", func(t *testing.T, store Storage) {
+			if _, err := store.Put(ctx, "concurrent", strings.NewReader("rewritten"), -1, ""); err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+		}, []byte("rewritten")},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &hookedStorage{Storage: NewMemoryStorage()}
+			old := newTestEncryptedStorage(t, inner, "old")
+			if _, err := old.Put(ctx, "concurrent", strings.NewReader("original"), -1, ""); err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+
+			// the object changes once its rotated copy is written aside
+			current := newTestEncryptedStorage(t, inner, "new")
+			inner.afterPut = func(key string) {
+				if strings.HasPrefix(key, encryptedRotationDir+"/") {
+					inner.afterPut = nil
+					tt.change(t, current)
+				}
+			}
+
+			rotating := newTestEncryptedStorage(t, inner, "new", "old")
+			rotated, err := rotating.(KeyRotator).RotateKeys(ctx)
+			if err != nil || rotated != 0 {
+				t.Errorf("RotateKeys returned %d, %v, want the changed object left alone", rotated, err)
+			}
+
+			if tt.want == nil {
+				if _, err := inner.Stat(ctx, "concurrent"); !errors.Is(err, errs.ErrFileNotFound) {
+					t.Errorf("the deleted object was brought back: %v", err)
+				}
+			} else if got := readAll(t, current, "concurrent"); !bytes.Equal(got, tt.want) {
+				t.Errorf("the object reads %q after the rotation, want %q", got, tt.want)
+			}
+
+			if infos, err := inner.List(ctx, encryptedRotationDir+"/"); err != nil || len(infos) != 0 {
+				t.Errorf("the rotation left %+v, %v behind", infos, err)
+			}
+		})
+	}
+}
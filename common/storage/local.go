@@ -0,0 +1,200 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	errs "github.com/zetsux/gin-gorm-clean-starter/core/helper/errors"
+)
+
+// localTempPattern names the files being written, which are left out of listings
+const localTempPattern = ".upload-*"
+
+type localStorage struct {
+	basePath string
+}
+
+// NewLocalStorage stores files on the local filesystem under basePath.
+func NewLocalStorage(basePath string) Storage {
+	return &localStorage{basePath: basePath}
+}
+
+func (ls *localStorage) path(key string) (string, error) {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(ls.basePath, filepath.FromSlash(cleaned)), nil
+}
+
+func (ls *localStorage) Put(ctx context.Context, key string,
+	r io.Reader, size int64, contentType string) (ObjectInfo, error) {
+	filePath, err := ls.path(key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0777); err != nil {
+		return ObjectInfo{}, err
+	}
+
+	// the content is written aside then renamed over the key, so that readers
+	// never see a partially written file
+	file, err := os.CreateTemp(filepath.Dir(filePath), localTempPattern)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		return ObjectInfo{}, err
+	}
+
+	if err := file.Close(); err != nil {
+		return ObjectInfo{}, err
+	}
+
+	if err := os.Rename(file.Name(), filePath); err != nil {
+		return ObjectInfo{}, err
+	}
+	return ls.Stat(ctx, key)
+}
+
+func (ls *localStorage) Get(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error) {
+	info, err := ls.Stat(ctx, key)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+
+	filePath, err := ls.path(key)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, ObjectInfo{}, mapLocalError(err)
+	}
+	return file, info, nil
+}
+
+func (ls *localStorage) Delete(_ context.Context, key string) error {
+	filePath, err := ls.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(filePath); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return errs.ErrFileNotFound
+		}
+		return errs.ErrFileDeleteFailed
+	}
+	return nil
+}
+
+func (ls *localStorage) Stat(_ context.Context, key string) (ObjectInfo, error) {
+	filePath, err := ls.path(key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	stat, err := os.Stat(filePath)
+	if err != nil {
+		return ObjectInfo{}, mapLocalError(err)
+	}
+
+	if stat.IsDir() {
+		return ObjectInfo{}, errs.ErrFileNotFound
+	}
+
+	cleaned, _ := CleanKey(key)
+	return ObjectInfo{
+		Key:          cleaned,
+		Size:         stat.Size(),
+		ContentType:  detectContentType(filePath),
+		LastModified: stat.ModTime(),
+	}, nil
+}
+
+func (ls *localStorage) List(_ context.Context, prefix string) ([]ObjectInfo, error) {
+	var infos []ObjectInfo
+
+	err := filepath.WalkDir(ls.basePath, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+
+		if entry.IsDir() {
+			return nil
+		}
+
+		if matched, _ := path.Match(localTempPattern, entry.Name()); matched {
+			return nil
+		}
+
+		rel, err := filepath.Rel(ls.basePath, filePath)
+		if err != nil {
+			return err
+		}
+
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		stat, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		infos = append(infos, ObjectInfo{
+			Key:          key,
+			Size:         stat.Size(),
+			ContentType:  detectContentType(filePath),
+			LastModified: stat.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return infos, nil
+}
+
+// detectContentType guesses the content type of a file from its extension,
+// falling back to sniffing its first bytes as keys usually have none.
+func detectContentType(filePath string) string {
+	if contentType := mime.TypeByExtension(path.Ext(filePath)); contentType != "" {
+		return contentType
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "application/octet-stream"
+	}
+	defer file.Close()
+
+	buf := make([]byte, 512)
+	n, _ := io.ReadFull(file, buf)
+	return http.DetectContentType(buf[:n])
+}
+
+func mapLocalError(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return errs.ErrFileNotFound
+	}
+	return err
+}
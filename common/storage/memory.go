@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	errs "github.com/zetsux/gin-gorm-clean-starter/core/helper/errors"
+)
+
+type memoryObject struct {
+	data []byte
+	info ObjectInfo
+}
+
+type memoryStorage struct {
+	mu      sync.RWMutex
+	objects map[string]memoryObject
+}
+
+// NewMemoryStorage keeps files in memory, which is mainly useful for tests and
+// for running the app without any persistent storage.
+func NewMemoryStorage() Storage {
+	return &memoryStorage{objects: map[string]memoryObject{}}
+}
+
+func (ms *memoryStorage) Put(_ context.Context, key string,
+	r io.Reader, _ int64, contentType string) (ObjectInfo, error) {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+
+	info := ObjectInfo{
+		Key:          cleaned,
+		Size:         int64(len(data)),
+		ContentType:  contentType,
+		LastModified: time.Now(),
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.objects[cleaned] = memoryObject{data: data, info: info}
+	return info, nil
+}
+
+func (ms *memoryStorage) Get(_ context.Context, key string) (io.ReadCloser, ObjectInfo, error) {
+	obj, err := ms.object(key)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	return NopCloser(bytes.NewReader(obj.data)), obj.info, nil
+}
+
+func (ms *memoryStorage) Delete(_ context.Context, key string) error {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if _, ok := ms.objects[cleaned]; !ok {
+		return errs.ErrFileNotFound
+	}
+	delete(ms.objects, cleaned)
+	return nil
+}
+
+func (ms *memoryStorage) Stat(_ context.Context, key string) (ObjectInfo, error) {
+	obj, err := ms.object(key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	return obj.info, nil
+}
+
+func (ms *memoryStorage) List(_ context.Context, prefix string) ([]ObjectInfo, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	var infos []ObjectInfo
+	for key, obj := range ms.objects {
+		if strings.HasPrefix(key, prefix) {
+			infos = append(infos, obj.info)
+		}
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Key < infos[j].Key })
+	return infos, nil
+}
+
+func (ms *memoryStorage) object(key string) (memoryObject, error) {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return memoryObject{}, err
+	}
+
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	obj, ok := ms.objects[cleaned]
+	if !ok {
+		return memoryObject{}, errs.ErrFileNotFound
+	}
+	return obj, nil
+}
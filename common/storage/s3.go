@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"strings"
+
+	errs "github.com/zetsux/gin-gorm-clean-starter/core/helper/errors"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+type S3Config struct {
+	Endpoint  string
+	AccessKey string
+	SecretKey string
+	Bucket    string
+	Region    string
+	UseSSL    bool
+}
+
+type s3Storage struct {
+	client *minio.Client
+	bucket string
+}
+
+// NewS3Storage stores files in a bucket of any S3 compatible service (AWS S3,
+// MinIO, etc), creating the bucket when it doesn't exist yet.
+func NewS3Storage(ctx context.Context, cfg S3Config) (Storage, error) {
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: cfg.UseSSL,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := client.BucketExists(ctx, cfg.Bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		err = client.MakeBucket(ctx, cfg.Bucket, minio.MakeBucketOptions{Region: cfg.Region})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &s3Storage{client: client, bucket: cfg.Bucket}, nil
+}
+
+func (ss *s3Storage) Put(ctx context.Context, key string,
+	r io.Reader, size int64, contentType string) (ObjectInfo, error) {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	_, err = ss.client.PutObject(ctx, ss.bucket, cleaned, r, size, minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	return ss.Stat(ctx, cleaned)
+}
+
+func (ss *s3Storage) Get(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error) {
+	info, err := ss.Stat(ctx, key)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+
+	obj, err := ss.client.GetObject(ctx, ss.bucket, info.Key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, ObjectInfo{}, mapS3Error(err)
+	}
+	return obj, info, nil
+}
+
+func (ss *s3Storage) Delete(ctx context.Context, key string) error {
+	// removing a missing object succeeds on S3, so existence is checked first
+	info, err := ss.Stat(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	if err := ss.client.RemoveObject(ctx, ss.bucket, info.Key, minio.RemoveObjectOptions{}); err != nil {
+		return errs.ErrFileDeleteFailed
+	}
+	return nil
+}
+
+func (ss *s3Storage) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	stat, err := ss.client.StatObject(ctx, ss.bucket, cleaned, minio.StatObjectOptions{})
+	if err != nil {
+		return ObjectInfo{}, mapS3Error(err)
+	}
+	return toObjectInfo(stat), nil
+}
+
+func (ss *s3Storage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var infos []ObjectInfo
+	for obj := range ss.client.ListObjects(ctx, ss.bucket, minio.ListObjectsOptions{
+		Prefix:    prefix,
+		Recursive: true,
+	}) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		infos = append(infos, toObjectInfo(obj))
+	}
+	return infos, nil
+}
+
+func toObjectInfo(obj minio.ObjectInfo) ObjectInfo {
+	info := ObjectInfo{
+		Key:          obj.Key,
+		Size:         obj.Size,
+		ContentType:  obj.ContentType,
+		LastModified: obj.LastModified,
+	}
+	if obj.ETag != "" {
+		info.ETag = strconv.Quote(strings.Trim(obj.ETag, `"`))
+	}
+	return info
+}
+
+func mapS3Error(err error) error {
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return errs.ErrFileNotFound
+	}
+	return err
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	errs "github.com/zetsux/gin-gorm-clean-starter/core/helper/errors"
+)
+
+const (
+	DriverLocal  = "local"
+	DriverMemory = "memory"
+	DriverS3     = "s3"
+)
+
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	ContentType  string
+	LastModified time.Time
+	// ETag is the quoted entity tag of the content, left empty by backends
+	// which do not compute one
+	ETag string
+}
+
+// Storage is a flat key-value store of files, where keys are slash separated
+// paths such as "user_picture/<uuid>".
+type Storage interface {
+	// Put stores the content of r under key, size may be -1 when unknown
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) (ObjectInfo, error)
+	// Get opens the content under key, which is seekable whenever the backend
+	// allows it so that ranges can be served
+	Get(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error)
+	Delete(ctx context.Context, key string) error
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+	// List returns every object which key starts with prefix
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+}
+
+// CleanKey normalizes a key and rejects the ones escaping the storage root.
+func CleanKey(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return "", errs.ErrInvalidFileKey
+	}
+
+	cleaned := path.Clean(key)
+	if cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", errs.ErrInvalidFileKey
+	}
+	return cleaned, nil
+}
+
+// NopCloser is io.NopCloser for readers which can seek.
+func NopCloser(r io.ReadSeeker) io.ReadSeekCloser {
+	return nopCloser{r}
+}
+
+type nopCloser struct {
+	io.ReadSeeker
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
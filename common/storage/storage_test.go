@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	errs "github.com/zetsux/gin-gorm-clean-starter/core/helper/errors"
+)
+
+// testStorage checks that store behaves like every Storage should, writing
+// only under a prefix of its own so that a shared bucket can be used.
+func testStorage(t *testing.T, store Storage) {
+	ctx := context.Background()
+	prefix := "conformance-" + uuid.NewString() + "/"
+	t.Cleanup(func() {
+		infos, _ := store.List(ctx, prefix)
+		for _, info := range infos {
+			_ = store.Delete(ctx, info.Key)
+		}
+	})
+
+	content := []byte(strings.Repeat("0123456789", 100))
+
+	t.Run("PutGet", func(t *testing.T) {
+		for name, size := range map[string]int64{"known size": int64(len(content)), "unknown size": -1} {
+			key := prefix + "put/" + strings.ReplaceAll(name, " ", "-")
+			info, err := store.Put(ctx, key, bytes.NewReader(content), size, "text/plain")
+			if err != nil {
+				t.Fatalf("%s: Put: %v", name, err)
+			}
+			if info.Key != key || info.Size != int64(len(content)) {
+				t.Errorf("%s: Put returned %q of %d bytes, want %q of %d", name, info.Key, info.Size, key, len(content))
+			}
+
+			if got := readAll(t, store, key); !bytes.Equal(got, content) {
+				t.Errorf("%s: Get returned %d bytes which differ from the %d put", name, len(got), len(content))
+			}
+		}
+	})
+
+	t.Run("Overwrite", func(t *testing.T) {
+		key := prefix + "overwrite"
+		putString(t, store, key, "first")
+		putString(t, store, key, "second")
+		if got := string(readAll(t, store, key)); got != "second" {
+			t.Errorf("Get returned %q after an overwrite, want %q", got, "second")
+		}
+	})
+
+	t.Run("Stat", func(t *testing.T) {
+		key := prefix + "stat"
+		putString(t, store, key, "hello")
+		info, err := store.Stat(ctx, key)
+		if err != nil {
+			t.Fatalf("Stat: %v", err)
+		}
+		if info.Key != key || info.Size != 5 || info.LastModified.IsZero() {
+			t.Errorf("Stat returned %+v, want %q of 5 bytes with a modification time", info, key)
+		}
+	})
+
+	t.Run("Range", func(t *testing.T) {
+		key := prefix + "range"
+		if _, err := store.Put(ctx, key, bytes.NewReader(content), int64(len(content)), ""); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+
+		r, _, err := store.Get(ctx, key)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		defer r.Close()
+
+		seeker, ok := r.(io.Seeker)
+		if !ok {
+			t.Fatalf("Get returned a %T, which cannot seek", r)
+		}
+		for _, offset := range []int64{995, 123, 0} {
+			if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+				t.Fatalf("Seek(%d): %v", offset, err)
+			}
+			got := make([]byte, 5)
+			if _, err := io.ReadFull(r, got); err != nil {
+				t.Fatalf("reading at %d: %v", offset, err)
+			}
+			if want := content[offset : offset+5]; !bytes.Equal(got, want) {
+				t.Errorf("read %q at %d, want %q", got, offset, want)
+			}
+		}
+
+		end, err := seeker.Seek(0, io.SeekEnd)
+		if err != nil || end != int64(len(content)) {
+			t.Errorf("Seek to the end returned %d, %v, want %d", end, err, len(content))
+		}
+	})
+
+	t.Run("List", func(t *testing.T) {
+		listed := prefix + "list/"
+		putString(t, store, listed+"a", "a")
+		putString(t, store, listed+"nested/b", "bb")
+		putString(t, store, prefix+"list-other", "c")
+
+		infos, err := store.List(ctx, listed)
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		sort.Slice(infos, func(i, j int) bool { return infos[i].Key < infos[j].Key })
+
+		if len(infos) != 2 || infos[0].Key != listed+"a" || infos[1].Key != listed+"nested/b" {
+			t.Fatalf("List returned %+v, want %sa and %snested/b", infos, listed, listed)
+		}
+		if infos[0].Size != 1 || infos[1].Size != 2 {
+			t.Errorf("List reported sizes %d and %d, want 1 and 2", infos[0].Size, infos[1].Size)
+		}
+
+		if infos, err := store.List(ctx, prefix+"missing/"); err != nil || len(infos) != 0 {
+			t.Errorf("List of a missing prefix returned %+v, %v, want nothing", infos, err)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		key := prefix + "delete"
+		putString(t, store, key, "gone")
+		if err := store.Delete(ctx, key); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		if _, err := store.Stat(ctx, key); !errors.Is(err, errs.ErrFileNotFound) {
+			t.Errorf("Stat after Delete returned %v, want %v", err, errs.ErrFileNotFound)
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		key := prefix + "missing"
+		if _, err := store.Stat(ctx, key); !errors.Is(err, errs.ErrFileNotFound) {
+			t.Errorf("Stat returned %v, want %v", err, errs.ErrFileNotFound)
+		}
+		if _, _, err := store.Get(ctx, key); !errors.Is(err, errs.ErrFileNotFound) {
+			t.Errorf("Get returned %v, want %v", err, errs.ErrFileNotFound)
+		}
+		if err := store.Delete(ctx, key); !errors.Is(err, errs.ErrFileNotFound) {
+			t.Errorf("Delete returned %v, want %v", err, errs.ErrFileNotFound)
+		}
+	})
+
+	t.Run("InvalidKey", func(t *testing.T) {
+		for _, key := range []string{"", "/absolute", "../escape", "a/../../escape"} {
+			if _, err := store.Put(ctx, key, strings.NewReader("x"), 1, ""); !errors.Is(err, errs.ErrInvalidFileKey) {
+				t.Errorf("Put(%q) returned %v, want %v", key, err, errs.ErrInvalidFileKey)
+			}
+		}
+	})
+}
+
+func putString(t *testing.T, store Storage, key string, s string) {
+	t.Helper()
+	if _, err := store.Put(context.Background(), key, strings.NewReader(s), int64(len(s)), "text/plain"); err != nil {
+		t.Fatalf("Put(%q): %v", key, err)
+	}
+}
+
+func readAll(t *testing.T, store Storage, key string) []byte {
+	t.Helper()
+	r, _, err := store.Get(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Get(%q): %v", key, err)
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("reading %q: %v", key, err)
+	}
+	return data
+}
+
+func TestLocalStorage(t *testing.T) {
+	testStorage(t, NewLocalStorage(t.TempDir()))
+}
+
+func TestMemoryStorage(t *testing.T) {
+	testStorage(t, NewMemoryStorage())
+}
+
+// TestS3Storage runs against the S3 compatible service (e.g. a local MinIO)
+// given by the S3_TEST_* variables, the bucket being created when missing.
+func TestS3Storage(t *testing.T) {
+	endpoint := os.Getenv("S3_TEST_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("S3_TEST_ENDPOINT is not set")
+	}
+
+	bucket := os.Getenv("S3_TEST_BUCKET")
+	if bucket == "" {
+		bucket = "storage-test"
+	}
+
+	store, err := NewS3Storage(context.Background(), S3Config{
+		Endpoint:  endpoint,
+		AccessKey: os.Getenv("S3_TEST_ACCESS_KEY"),
+		SecretKey: os.Getenv("S3_TEST_SECRET_KEY"),
+		Bucket:    bucket,
+		Region:    os.Getenv("S3_TEST_REGION"),
+		UseSSL:    os.Getenv("S3_TEST_USE_SSL") == "true",
+	})
+	if err != nil {
+		t.Fatalf("NewS3Storage: %v", err)
+	}
+	testStorage(t, store)
+}
@@ -0,0 +1,49 @@
+package util
+
+import (
+	"strconv"
+	"strings"
+
+	errs "github.com/zetsux/gin-gorm-clean-starter/core/helper/errors"
+)
+
+// FormatETag builds the strong entity tag of a versioned resource.
+func FormatETag(version uint) string {
+	return strconv.Quote(strconv.FormatUint(uint64(version), 10))
+}
+
+// ParseIfMatch extracts the expected resource version from an If-Match header,
+// an empty or wildcard header results in 0 which means no version is expected.
+func ParseIfMatch(header string) (uint, error) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+
+	tag, err := strconv.Unquote(header)
+	if err != nil {
+		return 0, errs.ErrInvalidIfMatch
+	}
+
+	version, err := strconv.ParseUint(tag, 10, 0)
+	if err != nil || version == 0 {
+		return 0, errs.ErrInvalidIfMatch
+	}
+	return uint(version), nil
+}
+
+// ETagMatches reports whether an If-None-Match header matches the entity tag,
+// comparing the tags weakly as the header requires.
+func ETagMatches(header string, etag string) bool {
+	if etag == "" {
+		return false
+	}
+
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package util
+
+import (
+	"encoding/json"
+
+	errs "github.com/zetsux/gin-gorm-clean-starter/core/helper/errors"
+)
+
+// MergePatch applies a JSON merge patch document (RFC 7396) to the target
+// document, where a null member in the patch removes the member from target.
+func MergePatch(target []byte, patch []byte) ([]byte, error) {
+	var targetVal, patchVal any
+	if err := json.Unmarshal(target, &targetVal); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(patch, &patchVal); err != nil {
+		return nil, errs.ErrInvalidMergePatch
+	}
+
+	return json.Marshal(mergeValue(targetVal, patchVal))
+}
+
+func mergeValue(target any, patch any) any {
+	patchObj, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetObj, ok := target.(map[string]any)
+	if !ok {
+		targetObj = map[string]any{}
+	}
+
+	for key, val := range patchObj {
+		if val == nil {
+			delete(targetObj, key)
+			continue
+		}
+		targetObj[key] = mergeValue(targetObj[key], val)
+	}
+	return targetObj
+}
@@ -0,0 +1,63 @@
+package util
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+)
+
+// ParseQueryList splits a comma separated query value (e.g. "id,name,email")
+// into its trimmed, non-empty and unique items.
+func ParseQueryList(val string) []string {
+	var items []string
+	for _, item := range strings.Split(val, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" && !slices.Contains(items, item) {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// SelectFields keeps only the given JSON keys (plus the keep keys) of a struct
+// or a slice of structs. Data is returned untouched when no fields are given.
+func SelectFields(data any, fields []string, keep ...string) (any, error) {
+	if len(fields) == 0 {
+		return data, nil
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := append(slices.Clone(fields), keep...)
+	pick := func(obj map[string]any) map[string]any {
+		res := make(map[string]any, len(allowed))
+		for _, key := range allowed {
+			if val, ok := obj[key]; ok {
+				res[key] = val
+			}
+		}
+		return res
+	}
+
+	if len(raw) > 0 && raw[0] == '[' {
+		var objs []map[string]any
+		if err := json.Unmarshal(raw, &objs); err != nil {
+			return nil, err
+		}
+
+		res := make([]map[string]any, 0, len(objs))
+		for _, obj := range objs {
+			res = append(res, pick(obj))
+		}
+		return res, nil
+	}
+
+	var obj map[string]any
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil, err
+	}
+	return pick(obj), nil
+}
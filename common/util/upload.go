@@ -0,0 +1,63 @@
+package util
+
+import (
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+
+	errs "github.com/zetsux/gin-gorm-clean-starter/core/helper/errors"
+)
+
+// maxFormValueSize bounds each of the form values read before a file part.
+const maxFormValueSize = 64 << 10
+
+// NextFormFile streams the multipart body of r up to the file part named
+// field, so that the file can be read without buffering it in memory or disk.
+func NextFormFile(r *http.Request, field string) (*multipart.Part, error) {
+	part, _, err := NextFormFileWithValues(r, field)
+	return part, err
+}
+
+// NextFormFileWithValues is NextFormFile which also returns the form values
+// sent before the file part, clients thus having to send them first.
+func NextFormFileWithValues(r *http.Request, field string) (*multipart.Part, url.Values, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	values := url.Values{}
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil, nil, errs.ErrFormFileMissing
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if part.FormName() == field && part.FileName() != "" {
+			return part, values, nil
+		}
+
+		if part.FormName() != "" && part.FileName() == "" {
+			value, err := io.ReadAll(io.LimitReader(part, maxFormValueSize+1))
+			if err != nil {
+				return nil, nil, err
+			}
+			if len(value) > maxFormValueSize {
+				return nil, nil, errs.ErrFormValueTooLarge
+			}
+			values.Add(part.FormName(), string(value))
+		}
+		part.Close()
+	}
+}
+
+// IsBodyTooLarge tells whether err comes from reading past the body size limit.
+func IsBodyTooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr)
+}
@@ -0,0 +1,289 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/zetsux/gin-gorm-clean-starter/common/constant"
+	"github.com/zetsux/gin-gorm-clean-starter/common/logging"
+	"github.com/zetsux/gin-gorm-clean-starter/common/scanner"
+	"github.com/zetsux/gin-gorm-clean-starter/common/storage"
+)
+
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+
+	// fallback secrets, which are only good enough for development
+	defaultJWTSecret         = "jwt_secret_key"
+	defaultFileSigningSecret = "file_signing_key"
+
+	// minSecretLength is the minimum length of the secrets used in production
+	minSecretLength = 32
+)
+
+// Config is the whole configuration of the app, every field being set in turn
+// by its default, the config file (json key), the environment (env key) and the
+// command line flags (env key in lower kebab case, e.g. -db-host). Secrets are
+// tagged flag:"-" to keep them off the command line, where any local user can
+// read them from the process list.
+type Config struct {
+	Env          string             `json:"env" env:"APP_ENV"`
+	Port         int                `json:"port" env:"PORT"`
+	Server       ServerConfig       `json:"server"`
+	Log          LogConfig          `json:"log"`
+	Metrics      MetricsConfig      `json:"metrics"`
+	DB           DBConfig           `json:"db"`
+	JWT          JWTConfig          `json:"jwt"`
+	Storage      StorageConfig      `json:"storage"`
+	Scanner      ScannerConfig      `json:"scanner"`
+	File         FileConfig         `json:"file"`
+	Upload       UploadConfig       `json:"upload"`
+	StorageQuota StorageQuotaConfig `json:"storage_quota"`
+}
+
+// ServerConfig holds the timeouts of the HTTP server, a zero timeout being none.
+type ServerConfig struct {
+	ReadHeaderTimeout time.Duration `json:"read_header_timeout" env:"SERVER_READ_HEADER_TIMEOUT"`
+	// ReadTimeout and WriteTimeout bound whole requests and responses, uploads
+	// and downloads included
+	ReadTimeout  time.Duration `json:"read_timeout" env:"SERVER_READ_TIMEOUT"`
+	WriteTimeout time.Duration `json:"write_timeout" env:"SERVER_WRITE_TIMEOUT"`
+	IdleTimeout  time.Duration `json:"idle_timeout" env:"SERVER_IDLE_TIMEOUT"`
+	// ShutdownDelay is how long requests keep being served once readiness
+	// fails on shutdown, giving the load balancer time to stop routing to it
+	ShutdownDelay time.Duration `json:"shutdown_delay" env:"SERVER_SHUTDOWN_DELAY"`
+	// ShutdownTimeout is how long in-flight requests are waited for on shutdown
+	ShutdownTimeout time.Duration `json:"shutdown_timeout" env:"SERVER_SHUTDOWN_TIMEOUT"`
+}
+
+type LogConfig struct {
+	// Level is either debug, info, warn or error
+	Level string `json:"level" env:"LOG_LEVEL"`
+	// Format is either text or json
+	Format string `json:"format" env:"LOG_FORMAT"`
+}
+
+type MetricsConfig struct {
+	// Token is the bearer token /metrics requires, which is open when unset
+	Token string `json:"token" env:"METRICS_TOKEN" flag:"-"`
+}
+
+type DBConfig struct {
+	Host     string `json:"host" env:"DB_HOST"`
+	User     string `json:"user" env:"DB_USER"`
+	Pass     string `json:"pass" env:"DB_PASS" flag:"-"`
+	Name     string `json:"name" env:"DB_NAME"`
+	Port     int    `json:"port" env:"DB_PORT"`
+	TimeZone string `json:"timezone" env:"DB_TIMEZONE"`
+	// LogLevel is either silent, error, warn (slow queries) or info (every query)
+	LogLevel string `json:"log_level" env:"DB_LOG_LEVEL"`
+	// SlowThreshold is how long a query takes to be logged as slow, never when zero
+	SlowThreshold time.Duration `json:"slow_threshold" env:"DB_SLOW_THRESHOLD"`
+	// LogParams logs the parameters along with the queries, password hashes
+	// being redacted
+	LogParams bool `json:"log_params" env:"DB_LOG_PARAMS"`
+}
+
+type JWTConfig struct {
+	Secret string `json:"secret" env:"JWT_SECRET" flag:"-"`
+}
+
+type StorageConfig struct {
+	// Driver is either local, memory or s3
+	Driver    string   `json:"driver" env:"STORAGE_DRIVER"`
+	LocalPath string   `json:"local_path" env:"STORAGE_LOCAL_PATH"`
+	S3        S3Config `json:"s3"`
+	// EncryptionKeys lists the <id>:<base64 key> master keys, stored files
+	// being encrypted once there is any
+	EncryptionKeys []string `json:"encryption_keys" env:"STORAGE_ENCRYPTION_KEYS" flag:"-"`
+	// EncryptionKeyID is the key new files are encrypted with, the last listed
+	// one when unset
+	EncryptionKeyID string `json:"encryption_key_id" env:"STORAGE_ENCRYPTION_KEY_ID"`
+}
+
+type S3Config struct {
+	Endpoint  string `json:"endpoint" env:"S3_ENDPOINT"`
+	AccessKey string `json:"access_key" env:"S3_ACCESS_KEY"`
+	SecretKey string `json:"secret_key" env:"S3_SECRET_KEY" flag:"-"`
+	Bucket    string `json:"bucket" env:"S3_BUCKET"`
+	Region    string `json:"region" env:"S3_REGION"`
+	UseSSL    bool   `json:"use_ssl" env:"S3_USE_SSL"`
+}
+
+type ScannerConfig struct {
+	// Driver is either none or clamd
+	Driver       string        `json:"driver" env:"SCANNER_DRIVER"`
+	ClamdNetwork string        `json:"clamd_network" env:"CLAMD_NETWORK"`
+	ClamdAddress string        `json:"clamd_address" env:"CLAMD_ADDRESS"`
+	ClamdTimeout time.Duration `json:"clamd_timeout" env:"CLAMD_TIMEOUT"`
+}
+
+type FileConfig struct {
+	SigningSecret string `json:"signing_secret" env:"FILE_SIGNING_SECRET" flag:"-"`
+	// PublicDirs lists the directories which files are served without signed URLs
+	PublicDirs []string `json:"public_dirs" env:"FILE_PUBLIC_DIRS"`
+	// GCInterval is how often unreferenced files are collected, never when zero
+	GCInterval time.Duration `json:"gc_interval" env:"FILE_GC_INTERVAL"`
+}
+
+// UploadConfig holds the maximum sizes in bytes of the upload requests.
+type UploadConfig struct {
+	MaxPictureSize    int64 `json:"max_picture_size" env:"UPLOAD_MAX_PICTURE_SIZE"`
+	MaxAttachmentSize int64 `json:"max_attachment_size" env:"UPLOAD_MAX_ATTACHMENT_SIZE"`
+	MaxResumableSize  int64 `json:"max_resumable_size" env:"UPLOAD_MAX_RESUMABLE_SIZE"`
+}
+
+// StorageQuotaConfig holds the storage quotas in bytes of each role, -1 being unlimited.
+type StorageQuotaConfig struct {
+	User  int64 `json:"user" env:"STORAGE_QUOTA_USER"`
+	Admin int64 `json:"admin" env:"STORAGE_QUOTA_ADMIN"`
+}
+
+func Default() Config {
+	return Config{
+		Env:  EnvDevelopment,
+		Port: 8080,
+		Server: ServerConfig{
+			ReadHeaderTimeout: 10 * time.Second,
+			ReadTimeout:       5 * time.Minute,
+			WriteTimeout:      5 * time.Minute,
+			IdleTimeout:       2 * time.Minute,
+			ShutdownTimeout:   30 * time.Second,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: logging.FormatText,
+		},
+		DB: DBConfig{
+			Host:          "localhost",
+			User:          "postgres",
+			Port:          5432,
+			TimeZone:      "Asia/Jakarta",
+			LogLevel:      "warn",
+			SlowThreshold: 200 * time.Millisecond,
+		},
+		JWT: JWTConfig{
+			Secret: defaultJWTSecret,
+		},
+		Storage: StorageConfig{
+			Driver:    storage.DriverLocal,
+			LocalPath: constant.FileBasePath,
+		},
+		Scanner: ScannerConfig{
+			Driver:       scanner.DriverNone,
+			ClamdNetwork: "tcp",
+			ClamdTimeout: time.Minute,
+		},
+		File: FileConfig{
+			SigningSecret: defaultFileSigningSecret,
+		},
+		Upload: UploadConfig{
+			MaxPictureSize:    constant.DefaultPictureMaxSize,
+			MaxAttachmentSize: constant.DefaultAttachmentMaxSize,
+			MaxResumableSize:  constant.DefaultUploadMaxSize,
+		},
+		StorageQuota: StorageQuotaConfig{
+			User:  constant.DefaultUserStorageQuota,
+			Admin: constant.UnlimitedStorageQuota,
+		},
+	}
+}
+
+func (c Config) IsProduction() bool {
+	return c.Env == EnvProduction
+}
+
+// Validate checks the whole configuration, reporting every problem at once.
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(slices.Contains([]string{EnvDevelopment, EnvProduction}, c.Env),
+		"APP_ENV must be %s or %s, got %q", EnvDevelopment, EnvProduction, c.Env)
+	check(c.Port > 0 && c.Port <= 65535, "PORT must be a valid port, got %d", c.Port)
+
+	check(c.Server.ReadHeaderTimeout >= 0, "SERVER_READ_HEADER_TIMEOUT must not be negative")
+	check(c.Server.ReadTimeout >= 0, "SERVER_READ_TIMEOUT must not be negative")
+	check(c.Server.WriteTimeout >= 0, "SERVER_WRITE_TIMEOUT must not be negative")
+	check(c.Server.IdleTimeout >= 0, "SERVER_IDLE_TIMEOUT must not be negative")
+	check(c.Server.ShutdownDelay >= 0, "SERVER_SHUTDOWN_DELAY must not be negative")
+	check(c.Server.ShutdownTimeout > 0, "SERVER_SHUTDOWN_TIMEOUT must be positive")
+
+	if _, err := logging.ParseLevel(c.Log.Level); err != nil {
+		errs = append(errs, fmt.Errorf("LOG_LEVEL must be debug, info, warn or error, got %q", c.Log.Level))
+	}
+	check(c.Log.Format == logging.FormatText || c.Log.Format == logging.FormatJSON,
+		"LOG_FORMAT must be %s or %s, got %q", logging.FormatText, logging.FormatJSON, c.Log.Format)
+
+	check(c.JWT.Secret != "", "JWT_SECRET is required")
+	check(c.File.SigningSecret != "", "FILE_SIGNING_SECRET is required")
+
+	check(c.DB.Host != "", "DB_HOST is required")
+	check(c.DB.User != "", "DB_USER is required")
+	check(c.DB.Name != "", "DB_NAME is required")
+	check(c.DB.Port > 0 && c.DB.Port <= 65535, "DB_PORT must be a valid port, got %d", c.DB.Port)
+	if _, err := time.LoadLocation(c.DB.TimeZone); err != nil {
+		errs = append(errs, fmt.Errorf("DB_TIMEZONE is invalid: %w", err))
+	}
+	if _, err := logging.ParseGormLevel(c.DB.LogLevel); err != nil {
+		errs = append(errs, fmt.Errorf("DB_LOG_LEVEL must be silent, error, warn or info, got %q", c.DB.LogLevel))
+	}
+	check(c.DB.SlowThreshold >= 0, "DB_SLOW_THRESHOLD must not be negative")
+
+	switch c.Storage.Driver {
+	case storage.DriverLocal:
+		check(c.Storage.LocalPath != "", "STORAGE_LOCAL_PATH is required by the local storage")
+	case storage.DriverMemory:
+	case storage.DriverS3:
+		check(c.Storage.S3.Endpoint != "", "S3_ENDPOINT is required by the s3 storage")
+		check(c.Storage.S3.Bucket != "", "S3_BUCKET is required by the s3 storage")
+		check(c.Storage.S3.AccessKey != "", "S3_ACCESS_KEY is required by the s3 storage")
+		check(c.Storage.S3.SecretKey != "", "S3_SECRET_KEY is required by the s3 storage")
+	default:
+		errs = append(errs, fmt.Errorf("STORAGE_DRIVER must be %s, %s or %s, got %q",
+			storage.DriverLocal, storage.DriverMemory, storage.DriverS3, c.Storage.Driver))
+	}
+	if _, err := c.Storage.encryptionConfig(); err != nil {
+		errs = append(errs, err)
+	}
+
+	switch c.Scanner.Driver {
+	case scanner.DriverNone:
+	case scanner.DriverClamd:
+		check(c.Scanner.ClamdNetwork == "tcp" || c.Scanner.ClamdNetwork == "unix",
+			"CLAMD_NETWORK must be tcp or unix, got %q", c.Scanner.ClamdNetwork)
+		check(c.Scanner.ClamdAddress != "", "CLAMD_ADDRESS is required by the clamd scanner")
+		check(c.Scanner.ClamdTimeout > 0, "CLAMD_TIMEOUT must be positive")
+	default:
+		errs = append(errs, fmt.Errorf("SCANNER_DRIVER must be %s or %s, got %q",
+			scanner.DriverNone, scanner.DriverClamd, c.Scanner.Driver))
+	}
+
+	check(c.File.GCInterval >= 0, "FILE_GC_INTERVAL must not be negative")
+	check(c.Upload.MaxPictureSize > 0, "UPLOAD_MAX_PICTURE_SIZE must be positive")
+	check(c.Upload.MaxAttachmentSize > 0, "UPLOAD_MAX_ATTACHMENT_SIZE must be positive")
+	check(c.Upload.MaxResumableSize > 0, "UPLOAD_MAX_RESUMABLE_SIZE must be positive")
+	check(c.StorageQuota.User >= constant.UnlimitedStorageQuota, "STORAGE_QUOTA_USER must be -1 (unlimited) or more")
+	check(c.StorageQuota.Admin >= constant.UnlimitedStorageQuota, "STORAGE_QUOTA_ADMIN must be -1 (unlimited) or more")
+
+	// the fallback secrets are public, so production refuses to run with them
+	if c.IsProduction() {
+		checkSecret := func(key string, secret string, fallback string) {
+			check(secret != fallback, "%s must be set in production", key)
+			check(secret == fallback || len(secret) >= minSecretLength,
+				"%s must be at least %d characters long in production", key, minSecretLength)
+		}
+		checkSecret("JWT_SECRET", c.JWT.Secret, defaultJWTSecret)
+		checkSecret("FILE_SIGNING_SECRET", c.File.SigningSecret, defaultFileSigningSecret)
+		check(c.DB.Pass != "", "DB_PASS must be set in production")
+	}
+
+	return errors.Join(errs...)
+}
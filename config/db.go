@@ -2,36 +2,35 @@ package config
 
 import (
 	"fmt"
-	"os"
+	"log/slog"
 
+	"github.com/zetsux/gin-gorm-clean-starter/common/logging"
+	"github.com/zetsux/gin-gorm-clean-starter/common/metrics"
 	migration "github.com/zetsux/gin-gorm-clean-starter/database"
 
-	"github.com/joho/godotenv"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-func DBSetup() *gorm.DB {
-	if os.Getenv("APP_ENV") != "production" {
-		err := godotenv.Load(".env")
-		if err != nil {
-			fmt.Println(err)
-			panic(err)
-		}
-	}
-
-	dbUser := os.Getenv("DB_USER")
-	dbPass := os.Getenv("DB_PASS")
-	dbHost := os.Getenv("DB_HOST")
-	dbName := os.Getenv("DB_NAME")
-	dbPort := os.Getenv("DB_PORT")
+func DBSetup(cfg DBConfig) *gorm.DB {
+	dsn := fmt.Sprintf("host=%v user=%v password=%v dbname=%v port=%v TimeZone=%v",
+		cfg.Host, cfg.User, cfg.Pass, cfg.Name, cfg.Port, cfg.TimeZone)
 
-	dsn := fmt.Sprintf("host=%v user=%v password=%v dbname=%v port=%v TimeZone=Asia/Jakarta",
-		dbHost, dbUser, dbPass, dbName, dbPort)
+	logLevel, err := logging.ParseGormLevel(cfg.LogLevel)
+	if err != nil {
+		panic(err)
+	}
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		Logger: logging.NewGormLogger(logLevel, cfg.SlowThreshold, cfg.LogParams),
+	})
 	if err != nil {
-		fmt.Println(err)
+		slog.Error("Failed to connect to the database", "error", err)
+		panic(err)
+	}
+
+	if err := metrics.RegisterDB(db, cfg.Name); err != nil {
+		slog.Error("Failed to measure the database", "error", err)
 		panic(err)
 	}
 
@@ -42,7 +41,7 @@ func DBSetup() *gorm.DB {
 func DBClose(db *gorm.DB) {
 	dbSQL, err := db.DB()
 	if err != nil {
-		fmt.Println(err)
+		slog.Error("Failed to close the database", "error", err)
 		panic(err)
 	}
 	dbSQL.Close()
@@ -0,0 +1,200 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zetsux/gin-gorm-clean-starter/common/util"
+
+	"github.com/joho/godotenv"
+)
+
+// configField is a single value of Config along with its key in each source,
+// flag being empty for the secrets which are not read from the command line.
+type configField struct {
+	value reflect.Value
+	path  []string
+	env   string
+	flag  string
+}
+
+// Load reads the configuration from its defaults, then the JSON config file
+// given by -config or CONFIG_FILE, then the environment (which .env fills in
+// when present) and finally the flags in args, returning the args left after
+// the flags. Every invalid value is reported at once along with the
+// validation errors.
+func Load(args []string) (Config, []string, error) {
+	cfg := Default()
+	fields := configFields(&cfg)
+
+	flags := flag.NewFlagSet("main", flag.ContinueOnError)
+	configFile := flags.String("config", "", "path of the JSON config file, CONFIG_FILE by default")
+	flagValues := map[string]*string{}
+	for _, field := range fields {
+		if field.flag != "" {
+			flagValues[field.flag] = flags.String(field.flag, "", "overrides "+field.env)
+		}
+	}
+	if err := flags.Parse(args); err != nil {
+		return cfg, nil, err
+	}
+
+	if err := godotenv.Load(".env"); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return cfg, nil, err
+	}
+
+	var errs []error
+	if *configFile == "" {
+		*configFile = os.Getenv("CONFIG_FILE")
+	}
+	if *configFile != "" {
+		if err := loadFile(*configFile, fields); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	// empty variables are left alone, just like unset ones
+	for _, field := range fields {
+		if value := os.Getenv(field.env); value != "" {
+			if err := setField(field.value, value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", field.env, err))
+			}
+		}
+	}
+
+	flags.Visit(func(f *flag.Flag) {
+		for _, field := range fields {
+			if field.flag == f.Name {
+				if err := setField(field.value, *flagValues[f.Name]); err != nil {
+					errs = append(errs, fmt.Errorf("-%s: %w", f.Name, err))
+				}
+			}
+		}
+	})
+
+	// values which could not be read keep their default while being validated
+	errs = append(errs, cfg.Validate())
+	return cfg, flags.Args(), errors.Join(errs...)
+}
+
+// loadFile sets the fields found in the JSON config file at name, whose
+// values are written just like in the environment, numbers and lists being
+// allowed as such.
+func loadFile(name string, fields []configField) error {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var file map[string]any
+	if err := decoder.Decode(&file); err != nil {
+		return fmt.Errorf("config file %s: %w", name, err)
+	}
+
+	var errs []error
+	for _, field := range fields {
+		value, ok := lookupFile(file, field.path)
+		if !ok {
+			continue
+		}
+
+		if err := setField(field.value, value); err != nil {
+			errs = append(errs, fmt.Errorf("config file %s: %s: %w", name, strings.Join(field.path, "."), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func lookupFile(file map[string]any, path []string) (string, bool) {
+	var value any = file
+	for _, key := range path {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return "", false
+		}
+		if value, ok = object[key]; !ok {
+			return "", false
+		}
+	}
+
+	switch value := value.(type) {
+	case []any:
+		items := make([]string, 0, len(value))
+		for _, item := range value {
+			items = append(items, fmt.Sprint(item))
+		}
+		return strings.Join(items, ","), true
+	case nil:
+		return "", false
+	default:
+		return fmt.Sprint(value), true
+	}
+}
+
+// configFields lists the fields of cfg which have an env key, going through
+// the nested structs.
+func configFields(cfg *Config) []configField {
+	var fields []configField
+	var walk func(v reflect.Value, path []string)
+	walk = func(v reflect.Value, path []string) {
+		for i := 0; i < v.NumField(); i++ {
+			structField := v.Type().Field(i)
+			key, _, _ := strings.Cut(structField.Tag.Get("json"), ",")
+			fieldPath := append(append([]string{}, path...), key)
+
+			if env := structField.Tag.Get("env"); env != "" {
+				field := configField{value: v.Field(i), path: fieldPath, env: env}
+				if structField.Tag.Get("flag") != "-" {
+					field.flag = strings.ReplaceAll(strings.ToLower(env), "_", "-")
+				}
+				fields = append(fields, field)
+			} else if structField.Type.Kind() == reflect.Struct {
+				walk(v.Field(i), fieldPath)
+			}
+		}
+	}
+	walk(reflect.ValueOf(cfg).Elem(), nil)
+	return fields
+}
+
+// setField parses value into the field according to its type, lists being
+// comma separated.
+func setField(field reflect.Value, value string) error {
+	switch field.Interface().(type) {
+	case string:
+		field.SetString(value)
+	case bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", value)
+		}
+		field.SetBool(b)
+	case time.Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration", value)
+		}
+		field.SetInt(int64(d))
+	case int, int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", value)
+		}
+		field.SetInt(n)
+	case []string:
+		field.Set(reflect.ValueOf(util.ParseQueryList(value)))
+	default:
+		return fmt.Errorf("unsupported config type %s", field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+
+	"github.com/zetsux/gin-gorm-clean-starter/common/logging"
+)
+
+// LoggerSetup makes the logger configured by cfg the default one, which the
+// log package writes through as well.
+func LoggerSetup(cfg LogConfig) *slog.Logger {
+	level, err := logging.ParseLevel(cfg.Level)
+	if err != nil {
+		panic(err)
+	}
+
+	logger, err := logging.New(os.Stderr, level, cfg.Format)
+	if err != nil {
+		panic(err)
+	}
+
+	slog.SetDefault(logger)
+	return logger
+}
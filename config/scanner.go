@@ -0,0 +1,27 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/zetsux/gin-gorm-clean-starter/common/scanner"
+)
+
+func ScannerSetup(cfg ScannerConfig) scanner.Scanner {
+	switch cfg.Driver {
+	case scanner.DriverNone:
+		return scanner.NewNoopScanner()
+
+	case scanner.DriverClamd:
+		return scanner.NewClamdScanner(scanner.ClamdConfig{
+			Network: cfg.ClamdNetwork,
+			Address: cfg.ClamdAddress,
+			Timeout: cfg.ClamdTimeout,
+		})
+
+	default:
+		err := fmt.Errorf("unknown scanner driver %q", cfg.Driver)
+		slog.Error("Failed to set up the scanner", "error", err)
+		panic(err)
+	}
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/zetsux/gin-gorm-clean-starter/common/storage"
+)
+
+func StorageSetup(cfg StorageConfig) storage.Storage {
+	store := storageDriverSetup(cfg)
+	if len(cfg.EncryptionKeys) == 0 {
+		return store
+	}
+
+	config, err := cfg.encryptionConfig()
+	if err != nil {
+		slog.Error("Invalid storage encryption keys", "error", err)
+		panic(err)
+	}
+
+	encrypted, err := storage.NewEncryptedStorage(store, config)
+	if err != nil {
+		slog.Error("Failed to set up the storage encryption", "error", err)
+		panic(err)
+	}
+	return encrypted
+}
+
+func storageDriverSetup(cfg StorageConfig) storage.Storage {
+	switch cfg.Driver {
+	case storage.DriverLocal:
+		return storage.NewLocalStorage(cfg.LocalPath)
+
+	case storage.DriverMemory:
+		return storage.NewMemoryStorage()
+
+	case storage.DriverS3:
+		store, err := storage.NewS3Storage(context.Background(), storage.S3Config{
+			Endpoint:  cfg.S3.Endpoint,
+			AccessKey: cfg.S3.AccessKey,
+			SecretKey: cfg.S3.SecretKey,
+			Bucket:    cfg.S3.Bucket,
+			Region:    cfg.S3.Region,
+			UseSSL:    cfg.S3.UseSSL,
+		})
+		if err != nil {
+			slog.Error("Failed to set up the s3 storage", "error", err)
+			panic(err)
+		}
+		return store
+
+	default:
+		err := fmt.Errorf("unknown storage driver %q", cfg.Driver)
+		slog.Error("Failed to set up the storage", "error", err)
+		panic(err)
+	}
+}
+
+// encryptionConfig parses the master keys, which are listed as <id>:<base64 key>,
+// checking them against the encrypted storage.
+func (cfg StorageConfig) encryptionConfig() (storage.EncryptionConfig, error) {
+	config := storage.EncryptionConfig{
+		Keys:         map[string][]byte{},
+		CurrentKeyID: cfg.EncryptionKeyID,
+	}
+	if len(cfg.EncryptionKeys) == 0 {
+		return config, nil
+	}
+
+	for _, entry := range cfg.EncryptionKeys {
+		id, encoded, _ := strings.Cut(entry, ":")
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return config, fmt.Errorf("STORAGE_ENCRYPTION_KEYS key %q is not valid base64: %w", id, err)
+		}
+		config.Keys[id] = key
+		if cfg.EncryptionKeyID == "" {
+			config.CurrentKeyID = id
+		}
+	}
+
+	if _, err := storage.NewEncryptedStorage(storage.NewMemoryStorage(), config); err != nil {
+		return config, fmt.Errorf("STORAGE_ENCRYPTION_KEYS is invalid: %w", err)
+	}
+	return config, nil
+}
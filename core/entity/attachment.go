@@ -0,0 +1,24 @@
+package entity
+
+import (
+	"github.com/google/uuid"
+	"github.com/zetsux/gin-gorm-clean-starter/common/base"
+)
+
+// Attachment links a file to an owner of any type, the attachments of an owner
+// being ordered by Position. OwnerID is kept as text so that owners are not
+// bound to have uuid keys.
+type Attachment struct {
+	ID         uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	OwnerType  string    `gorm:"not null;index:idx_attachments_owner" json:"ownerType"`
+	OwnerID    string    `gorm:"not null;index:idx_attachments_owner" json:"ownerId"`
+	FileID     uuid.UUID `gorm:"type:uuid;not null;index" json:"fileId"`
+	File       File      `gorm:"foreignKey:FileID;constraint:OnDelete:CASCADE" json:"file"`
+	UploaderID uuid.UUID `gorm:"type:uuid;not null;index" json:"uploaderId"`
+	Position   int       `gorm:"not null;default:0" json:"position"`
+	Caption    string    `json:"caption"`
+	// Visibility tells whether the attachment is shown to everyone who can see
+	// the attachments of the owner, or only to its uploader and the managers
+	Visibility string `gorm:"not null;default:public" json:"visibility"`
+	base.Model
+}
@@ -0,0 +1,68 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zetsux/gin-gorm-clean-starter/common/base"
+	"github.com/zetsux/gin-gorm-clean-starter/common/constant"
+)
+
+// File is the metadata of a single upload, identical uploads share the same
+// stored content through their FileBlob.
+type File struct {
+	ID           uuid.UUID     `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	OwnerID      *uuid.UUID    `gorm:"type:uuid;index" json:"ownerId"`
+	Dir          string        `gorm:"not null;index" json:"dir"`
+	OriginalName string        `json:"originalName"`
+	ContentType  string        `gorm:"not null" json:"contentType"`
+	Size         int64         `gorm:"not null" json:"size"`
+	SHA256       string        `gorm:"column:sha256;type:char(64);not null;index" json:"sha256"`
+	StorageKey   string        `gorm:"not null" json:"storageKey"`
+	Variants     []FileVariant `gorm:"foreignKey:FileID" json:"variants,omitempty"`
+	// ScanStatus is the outcome of scanning the content, which is quarantined
+	// when found infected or when it could not be scanned
+	ScanStatus    string     `gorm:"not null;default:skipped" json:"scanStatus"`
+	ScanSignature string     `json:"scanSignature"`
+	ScannedAt     *time.Time `json:"scannedAt"`
+	base.Model
+}
+
+// IsQuarantined reports whether the content is kept apart from the other files
+// and never served, in which case it is not shared through a FileBlob either.
+func (f File) IsQuarantined() bool {
+	return f.ScanStatus == constant.EnumScanStatusInfected || f.ScanStatus == constant.EnumScanStatusFailed
+}
+
+// FileVariant is a derived version of a file, such as a thumbnail, which is
+// stored on its own and goes away along with its file.
+type FileVariant struct {
+	FileID      uuid.UUID `gorm:"type:uuid;primary_key" json:"fileId"`
+	Name        string    `gorm:"primary_key" json:"name"`
+	ContentType string    `gorm:"not null" json:"contentType"`
+	Size        int64     `gorm:"not null" json:"size"`
+	StorageKey  string    `gorm:"not null" json:"storageKey"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// FileBlob is a unique stored content, referenced by RefCount files.
+type FileBlob struct {
+	SHA256     string    `gorm:"column:sha256;type:char(64);primary_key" json:"sha256"`
+	StorageKey string    `gorm:"not null;unique" json:"storageKey"`
+	Size       int64     `gorm:"not null" json:"size"`
+	RefCount   int64     `gorm:"not null" json:"refCount"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// StorageReservation is storage quota set aside for content of its owner which
+// is still being uploaded, it counts against the quota until it is released or
+// it expires.
+type StorageReservation struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	OwnerID   uuid.UUID `gorm:"type:uuid;not null;index" json:"ownerId"`
+	Size      int64     `gorm:"not null" json:"size"`
+	ExpiresAt time.Time `gorm:"not null" json:"expiresAt"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
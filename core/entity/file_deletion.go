@@ -0,0 +1,18 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FileDeletion is a file queued for deletion, which is retried until the file
+// is gone so that no file outlives the change that dropped it.
+type FileDeletion struct {
+	ID            uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	Path          string    `gorm:"not null" json:"path"`
+	Attempts      int       `gorm:"not null;default:0" json:"attempts"`
+	LastError     string    `json:"lastError"`
+	NextAttemptAt time.Time `gorm:"not null;index" json:"nextAttemptAt"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
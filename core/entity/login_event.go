@@ -0,0 +1,20 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LoginEvent records a single login attempt, the user is left empty when the
+// attempt was made with an unknown email.
+type LoginEvent struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	UserID        *uuid.UUID `gorm:"type:uuid;index" json:"userId"`
+	Email         string     `gorm:"not null" json:"email"`
+	IP            string     `json:"ip"`
+	UserAgent     string     `json:"userAgent"`
+	Success       bool       `gorm:"not null" json:"success"`
+	FailureReason string     `json:"failureReason"`
+	CreatedAt     time.Time  `gorm:"index" json:"createdAt"`
+}
@@ -0,0 +1,40 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zetsux/gin-gorm-clean-starter/common/base"
+)
+
+// Upload is a resumable upload in progress, its content is stored as chunks
+// which are assembled into a file once the upload is attached.
+type Upload struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	OwnerID     uuid.UUID `gorm:"type:uuid;not null;index" json:"ownerId"`
+	Length      int64     `gorm:"not null" json:"length"`
+	Offset      int64     `gorm:"not null;default:0" json:"offset"`
+	Metadata    string    `json:"metadata"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"contentType"`
+	ExpiresAt   time.Time `gorm:"not null;index" json:"expiresAt"`
+	// ClaimedUntil is when the claim of whoever is attaching or sweeping the
+	// upload runs out, unclaimed uploads having none
+	ClaimedUntil *time.Time   `json:"claimedUntil,omitempty"`
+	Parts        []UploadPart `gorm:"foreignKey:UploadID" json:"parts,omitempty"`
+	base.Model
+}
+
+// UploadPart is a chunk of an upload accepted at Offset.
+type UploadPart struct {
+	UploadID   uuid.UUID `gorm:"type:uuid;primary_key" json:"uploadId"`
+	Offset     int64     `gorm:"primary_key;autoIncrement:false" json:"offset"`
+	Size       int64     `gorm:"not null" json:"size"`
+	StorageKey string    `gorm:"not null" json:"storageKey"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// IsComplete tells whether every byte of the upload has been received.
+func (u Upload) IsComplete() bool {
+	return u.Offset >= u.Length
+}
@@ -1,20 +1,31 @@
 package entity
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 	"github.com/zetsux/gin-gorm-clean-starter/common/base"
+	"github.com/zetsux/gin-gorm-clean-starter/common/constant"
 	"github.com/zetsux/gin-gorm-clean-starter/common/util"
 
 	"gorm.io/gorm"
 )
 
 type User struct {
-	ID       uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
-	Name     string    `json:"name" gorm:"not null"`
-	Email    string    `json:"email" gorm:"unique;not null"`
-	Password string    `json:"password" gorm:"not null"`
-	Role     string    `json:"role" gorm:"not null"`
-	Picture  *string   `json:"picture"`
+	ID              uuid.UUID       `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	Name            string          `json:"name" gorm:"not null"`
+	Email           string          `json:"email" gorm:"unique;not null"`
+	Password        string          `json:"password" gorm:"not null"`
+	Role            string          `json:"role" gorm:"not null"`
+	Picture         *string         `json:"picture"`
+	Status          string          `json:"status" gorm:"not null;default:active"`
+	StatusReason    *string         `json:"statusReason"`
+	StatusExpiresAt *time.Time      `json:"statusExpiresAt"`
+	StatusLogs      []UserStatusLog `json:"statusLogs,omitempty" gorm:"foreignKey:UserID"`
+	LastLoginAt     *time.Time      `json:"lastLoginAt"`
+	LastSeenAt      *time.Time      `json:"lastSeenAt"`
+	// StorageQuota overrides the storage quota of the user's role when set
+	StorageQuota *int64 `json:"storageQuota"`
 	base.Model
 }
 
@@ -37,3 +48,19 @@ func (u *User) BeforeUpdate(tx *gorm.DB) error {
 	}
 	return nil
 }
+
+// EffectiveStatus returns the status the user is in at the given time, which
+// falls back to active once a status with an expiry has run out.
+func (u User) EffectiveStatus(now time.Time) string {
+	if u.Status == "" {
+		return constant.EnumStatusActive
+	}
+	if u.StatusExpiresAt != nil && !now.Before(*u.StatusExpiresAt) {
+		return constant.EnumStatusActive
+	}
+	return u.Status
+}
+
+func (u User) IsActive(now time.Time) bool {
+	return u.EffectiveStatus(now) == constant.EnumStatusActive
+}
@@ -0,0 +1,19 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserStatusLog is the audit trail entry of a single user status transition.
+type UserStatusLog struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	UserID     uuid.UUID  `gorm:"type:uuid;not null;index" json:"userId"`
+	ActorID    *uuid.UUID `gorm:"type:uuid" json:"actorId"`
+	FromStatus string     `gorm:"not null" json:"fromStatus"`
+	ToStatus   string     `gorm:"not null" json:"toStatus"`
+	Reason     string     `json:"reason"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
@@ -0,0 +1,58 @@
+package dto
+
+import (
+	"io"
+	"time"
+)
+
+type (
+	// AttachmentOwner is the entity attachments belong to, Type being the
+	// type its attachment policy is registered under
+	AttachmentOwner struct {
+		Type string
+		ID   string
+	}
+
+	// AttachmentActor is the authenticated user acting on attachments
+	AttachmentActor struct {
+		ID   string
+		Role string
+	}
+
+	// AttachmentCreateRequest adds either the uploaded Content or the content
+	// of the resumable upload UploadID
+	AttachmentCreateRequest struct {
+		Caption     string    `json:"caption" form:"caption" binding:"max=1000"`
+		Visibility  string    `json:"visibility" form:"visibility" binding:"omitempty,oneof=public private"`
+		UploadID    string    `json:"upload_id" form:"upload_id" binding:"omitempty,uuid"`
+		Filename    string    `json:"-" form:"-"`
+		ContentType string    `json:"-" form:"-"`
+		Content     io.Reader `json:"-" form:"-"`
+	}
+
+	AttachmentUpdateRequest struct {
+		Caption    *string `json:"caption" form:"caption" binding:"omitempty,max=1000"`
+		Visibility *string `json:"visibility" form:"visibility" binding:"omitempty,oneof=public private"`
+	}
+
+	AttachmentReorderRequest struct {
+		IDs []string `json:"ids" form:"ids" binding:"required,dive,uuid"`
+	}
+
+	AttachmentResponse struct {
+		ID          string            `json:"id"`
+		OwnerType   string            `json:"owner_type"`
+		OwnerID     string            `json:"owner_id"`
+		UploaderID  string            `json:"uploader_id"`
+		Position    int               `json:"position"`
+		Caption     string            `json:"caption"`
+		Visibility  string            `json:"visibility"`
+		URL         string            `json:"url"`
+		Name        string            `json:"name"`
+		ContentType string            `json:"content_type"`
+		Size        int64             `json:"size"`
+		Variants    map[string]string `json:"variants"`
+		CreatedAt   time.Time         `json:"created_at"`
+		UpdatedAt   time.Time         `json:"updated_at"`
+	}
+)
@@ -0,0 +1,16 @@
+package dto
+
+type (
+	AvatarGetRequest struct {
+		Size   int    `form:"size"`
+		Format string `form:"format"`
+	}
+
+	// AvatarResponse is a rendered avatar along with the entity tag which
+	// identifies it, as the same request always renders the same avatar
+	AvatarResponse struct {
+		Content     []byte
+		ContentType string
+		ETag        string
+	}
+)
@@ -0,0 +1,62 @@
+package dto
+
+import (
+	"io"
+	"time"
+)
+
+type (
+	FileGetRequest struct {
+		Expires     int64  `form:"expires"`
+		Disposition string `form:"disposition"`
+		Signature   string `form:"signature"`
+		Variant     string `form:"variant"`
+
+		// transformations, only applicable to images
+		Width  int    `form:"w"`
+		Height int    `form:"h"`
+		Fit    string `form:"fit"`
+		Format string `form:"format"`
+	}
+
+	// FileUploadRequest describes a file being uploaded into Dir, its content
+	// is streamed from Content which holds Size bytes (-1 when unknown). The
+	// storage already reserved for the content under ReservationID, if any, is
+	// taken over by the file
+	FileUploadRequest struct {
+		Dir           string
+		OwnerID       string
+		Name          string
+		ContentType   string
+		Size          int64
+		Content       io.Reader
+		Variants      []FileVariantUpload
+		ReservationID string
+	}
+
+	// FileVariantUpload is a derived version stored along with the uploaded file
+	FileVariantUpload struct {
+		Name        string
+		ContentType string
+		Size        int64
+		Content     io.Reader
+	}
+
+	FileResponse struct {
+		ID            string            `json:"id"`
+		Path          string            `json:"path"`
+		URL           string            `json:"url"`
+		OwnerID       string            `json:"owner_id"`
+		Dir           string            `json:"dir"`
+		OriginalName  string            `json:"original_name"`
+		ContentType   string            `json:"content_type"`
+		Size          int64             `json:"size"`
+		SHA256        string            `json:"sha256"`
+		StorageKey    string            `json:"storage_key"`
+		Variants      map[string]string `json:"variants"`
+		ScanStatus    string            `json:"scan_status"`
+		ScanSignature string            `json:"scan_signature"`
+		ScannedAt     *time.Time        `json:"scanned_at"`
+		CreatedAt     time.Time         `json:"created_at"`
+	}
+)
@@ -0,0 +1,43 @@
+package dto
+
+import "time"
+
+type (
+	// GarbageCollectionRequest only collects what is older than MinAge, with
+	// the default minimum age used when it is zero
+	GarbageCollectionRequest struct {
+		MinAge time.Duration `form:"min_age"`
+		DryRun bool          `form:"-"`
+	}
+
+	GarbageCollectionResponse struct {
+		DryRun    bool             `json:"dry_run"`
+		Before    time.Time        `json:"before"`
+		Files     []GarbageFile    `json:"files"`
+		Objects   []GarbageObject  `json:"objects"`
+		TotalSize int64            `json:"total_size"`
+		Collected int              `json:"collected"`
+		Failed    []GarbageFailure `json:"failed"`
+		// ExpiredUploads counts the expired resumable uploads deleted
+		ExpiredUploads int `json:"expired_uploads"`
+	}
+
+	// GarbageFile is a file which metadata nothing references anymore
+	GarbageFile struct {
+		Path      string    `json:"path"`
+		Size      int64     `json:"size"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+
+	// GarbageObject is a stored object which no file metadata accounts for
+	GarbageObject struct {
+		Key          string    `json:"key"`
+		Size         int64     `json:"size"`
+		LastModified time.Time `json:"last_modified"`
+	}
+
+	GarbageFailure struct {
+		Path  string `json:"path"`
+		Error string `json:"error"`
+	}
+)
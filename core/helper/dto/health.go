@@ -0,0 +1,14 @@
+package dto
+
+type (
+	HealthResponse struct {
+		Status     string                     `json:"status"`
+		Components map[string]ComponentHealth `json:"components,omitempty"`
+	}
+
+	ComponentHealth struct {
+		Status    string  `json:"status"`
+		LatencyMS float64 `json:"latency_ms"`
+		Error     string  `json:"error,omitempty"`
+	}
+)
@@ -0,0 +1,22 @@
+package dto
+
+type (
+	// StorageQuotaUpdateRequest overrides the quota of a user with Quota bytes,
+	// -1 being unlimited, or resets it to the quota of their role when null
+	StorageQuotaUpdateRequest struct {
+		Quota *int64 `json:"quota"`
+	}
+
+	// StorageUsageResponse reports the storage taken by the files of a user,
+	// and reserved for their uploads in progress. Quota and Remaining are null
+	// when the user has no limit
+	StorageUsageResponse struct {
+		UserID      string `json:"user_id"`
+		Files       int64  `json:"files"`
+		Used        int64  `json:"used"`
+		Reserved    int64  `json:"reserved"`
+		Quota       *int64 `json:"quota"`
+		Remaining   *int64 `json:"remaining"`
+		CustomQuota bool   `json:"custom_quota"`
+	}
+)
@@ -0,0 +1,28 @@
+package dto
+
+import (
+	"io"
+	"time"
+)
+
+type (
+	UploadCreateRequest struct {
+		Length      *int64 `header:"Upload-Length" binding:"required,gte=0"`
+		DeferLength string `header:"Upload-Defer-Length"`
+		Metadata    string `header:"Upload-Metadata"`
+	}
+
+	UploadChunkRequest struct {
+		Offset        *int64    `header:"Upload-Offset" binding:"required,gte=0"`
+		ContentLength int64     `header:"-"`
+		Content       io.Reader `header:"-"`
+	}
+
+	UploadResponse struct {
+		ID        string    `json:"id"`
+		Length    int64     `json:"length"`
+		Offset    int64     `json:"offset"`
+		Metadata  string    `json:"metadata"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+)
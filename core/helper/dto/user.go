@@ -1,6 +1,9 @@
 package dto
 
-import "mime/multipart"
+import (
+	"io"
+	"time"
+)
 
 type (
 	UserRegisterRequest struct {
@@ -10,31 +13,79 @@ type (
 	}
 
 	UserResponse struct {
-		ID      string `json:"id"`
-		Name    string `json:"name,omitempty"`
-		Email   string `json:"email,omitempty"`
-		Role    string `json:"role,omitempty"`
-		Picture string `json:"picture,omitempty"`
+		ID              string                  `json:"id"`
+		Name            string                  `json:"name"`
+		Email           string                  `json:"email"`
+		Role            string                  `json:"role"`
+		Picture         string                  `json:"picture"`
+		PictureVariants map[string]string       `json:"picture_variants"`
+		Status          string                  `json:"status"`
+		StatusReason    string                  `json:"status_reason"`
+		StatusExpiresAt *time.Time              `json:"status_expires_at"`
+		StatusHistory   []UserStatusLogResponse `json:"status_history,omitempty"`
+		LastLoginAt     *time.Time              `json:"last_login_at"`
+		LastSeenAt      *time.Time              `json:"last_seen_at"`
+		Version         uint                    `json:"version"`
 	}
 
 	UserLoginRequest struct {
-		Email    string `json:"email" form:"email" binding:"required"`
-		Password string `json:"password" form:"password" binding:"required"`
+		Email     string `json:"email" form:"email" binding:"required"`
+		Password  string `json:"password" form:"password" binding:"required"`
+		IP        string `json:"-" form:"-"`
+		UserAgent string `json:"-" form:"-"`
 	}
 
 	UserNameUpdateRequest struct {
 		Name string `json:"name" binding:"required"`
 	}
 
+	// UserUpdateRequest is the writable representation of a user, used as is to
+	// fully replace a user and as the merge target of merge patch documents
 	UserUpdateRequest struct {
-		ID       string `json:"id"`
-		Name     string `json:"name" form:"name"`
-		Email    string `json:"email" form:"email"`
-		Role     string `json:"role" form:"role"`
-		Password string `json:"password" form:"password"`
+		Name     string  `json:"name" binding:"required"`
+		Email    string  `json:"email" binding:"required,email"`
+		Role     string  `json:"role" binding:"required,oneof=admin user"`
+		Password *string `json:"password,omitempty" binding:"omitempty,min=1"`
+		Picture  *string `json:"picture"`
+		// PictureSet tells whether picture was given at all, a replacement
+		// leaving it out keeping the current picture
+		PictureSet bool `json:"-"`
 	}
 
+	// UserChangePictureRequest holds the picture streamed from the request body
 	UserChangePictureRequest struct {
-		Picture *multipart.FileHeader `json:"picture" form:"picture"`
+		Filename    string
+		ContentType string
+		Picture     io.Reader
+	}
+
+	UserAttachPictureRequest struct {
+		UploadID string `json:"upload_id" form:"upload_id" binding:"required,uuid"`
+	}
+
+	UserStatusUpdateRequest struct {
+		Status    string     `json:"status" form:"status" binding:"required,oneof=active pending suspended banned"`
+		Reason    string     `json:"reason" form:"reason"`
+		ExpiresAt *time.Time `json:"expires_at" form:"expires_at"`
+	}
+
+	UserStatusLogResponse struct {
+		ID         string     `json:"id"`
+		ActorID    string     `json:"actor_id"`
+		FromStatus string     `json:"from_status"`
+		ToStatus   string     `json:"to_status"`
+		Reason     string     `json:"reason"`
+		ExpiresAt  *time.Time `json:"expires_at"`
+		CreatedAt  time.Time  `json:"created_at"`
+	}
+
+	LoginEventResponse struct {
+		ID            string    `json:"id"`
+		Email         string    `json:"email"`
+		IP            string    `json:"ip"`
+		UserAgent     string    `json:"user_agent"`
+		Success       bool      `json:"success"`
+		FailureReason string    `json:"failure_reason,omitempty"`
+		CreatedAt     time.Time `json:"created_at"`
 	}
 )
@@ -0,0 +1,11 @@
+package errors
+
+import "errors"
+
+var (
+	ErrAttachmentNotFound      = errors.New("attachment not found")
+	ErrAttachmentOwnerNotFound = errors.New("attachment owner not found")
+	ErrAttachmentOwnerUnknown  = errors.New("attachment owner type is not registered")
+	ErrAttachmentForbidden     = errors.New("action on attachment is not allowed")
+	ErrAttachmentOrderMismatch = errors.New("attachment order must list every attachment exactly once")
+)
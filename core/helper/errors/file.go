@@ -4,5 +4,30 @@ import "errors"
 
 var (
 	ErrFileNotFound     = errors.New("file not found")
+	ErrFileReferenced   = errors.New("file is still referenced")
 	ErrFileDeleteFailed = errors.New("failed to delete file")
+	ErrInvalidFileKey   = errors.New("file key is invalid")
+	ErrFormFileMissing  = errors.New("form file is missing")
+
+	ErrFormValueTooLarge = errors.New("form value is too large")
+
+	ErrFileSignatureInvalid   = errors.New("file signature is invalid")
+	ErrFileURLExpired         = errors.New("file url has expired")
+	ErrInvalidFileDisposition = errors.New("file disposition is invalid")
+
+	ErrImageUnsupported = errors.New("image is not a JPEG, PNG or WebP image")
+	ErrImageInvalid     = errors.New("image is corrupted")
+	ErrImageTooLarge    = errors.New("image dimensions are too large")
+
+	ErrFileQuarantined = errors.New("file did not pass the malware scan and was quarantined")
+
+	ErrStorageQuotaExceeded = errors.New("storage quota exceeded")
+	ErrInvalidStorageQuota  = errors.New("storage quota must be -1 (unlimited) or more")
+
+	ErrInvalidGarbageMinAge = errors.New("garbage collection minimum age must not be negative")
+
+	ErrInvalidAvatar = errors.New("avatar size or format is not allowed")
+
+	ErrInvalidTransform     = errors.New("file transformation is not allowed")
+	ErrFileNotTransformable = errors.New("file is not an image that can be transformed")
 )
@@ -0,0 +1,9 @@
+package errors
+
+import "errors"
+
+var (
+	ErrServiceUnhealthy    = errors.New("some components are down")
+	ErrServiceShuttingDown = errors.New("service is shutting down")
+	ErrHealthCheckTimeout  = errors.New("check timed out")
+)
@@ -3,5 +3,14 @@ package errors
 import "errors"
 
 var (
-	ErrInvalidPage = errors.New("entered page is invalid")
+	ErrInvalidPage    = errors.New("entered page is invalid")
+	ErrInvalidField   = errors.New("entered field is invalid")
+	ErrInvalidInclude = errors.New("entered include is invalid")
+	ErrInvalidIfMatch = errors.New("entered If-Match header is invalid")
+
+	ErrInvalidMergePatch      = errors.New("entered merge patch document is invalid")
+	ErrUnsupportedContentType = errors.New("entered content type is not supported")
+
+	ErrPreconditionFailed = errors.New("resource has been modified since the given version")
+	ErrVersionConflict    = errors.New("resource has been modified concurrently")
 )
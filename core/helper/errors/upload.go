@@ -0,0 +1,15 @@
+package errors
+
+import "errors"
+
+var (
+	ErrUploadNotFound        = errors.New("upload not found")
+	ErrUploadExpired         = errors.New("upload has expired")
+	ErrUploadIncomplete      = errors.New("upload is not complete yet")
+	ErrUploadOffsetMismatch  = errors.New("upload offset does not match")
+	ErrUploadTooLarge        = errors.New("upload is too large")
+	ErrUploadChunkTooLarge   = errors.New("chunk goes past the upload length")
+	ErrInvalidUploadLength   = errors.New("upload length is invalid")
+	ErrInvalidUploadMetadata = errors.New("upload metadata is invalid")
+	ErrTusVersionUnsupported = errors.New("tus version is not supported")
+)
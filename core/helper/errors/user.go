@@ -3,7 +3,16 @@ package errors
 import "errors"
 
 var (
-	ErrEmailAlreadyExists = errors.New("email already exists")
-	ErrUserNotFound       = errors.New("user not found")
-	ErrUserNoPicture      = errors.New("user don't have any picture")
+	ErrEmailAlreadyExists  = errors.New("email already exists")
+	ErrUserNotFound        = errors.New("user not found")
+	ErrUserNoPicture       = errors.New("user don't have any picture")
+	ErrUserPictureChanged  = errors.New("user picture can only be changed through the picture endpoint")
+	ErrUserPictureTooLarge = errors.New("user picture is too large")
+	ErrUserWrongCredential = errors.New("entered credentials invalid")
+	ErrUserNotActive       = errors.New("user account is not active")
+
+	ErrUserStatusTransition     = errors.New("user status transition is not allowed")
+	ErrUserStatusReasonRequired = errors.New("user status reason is required")
+	ErrUserStatusExpiryInvalid  = errors.New("user status expiry is invalid")
+	ErrUserStatusSelfChange     = errors.New("user can't change their own status")
 )
@@ -0,0 +1,16 @@
+package messages
+
+const (
+	MsgAttachmentsFetchSuccess   = "Attachments fetched successfully"
+	MsgAttachmentsFetchFailed    = "Failed to fetch attachments"
+	MsgAttachmentFetchSuccess    = "Attachment fetched successfully"
+	MsgAttachmentFetchFailed     = "Failed to fetch attachment"
+	MsgAttachmentCreateSuccess   = "Attachment added successfully"
+	MsgAttachmentCreateFailed    = "Failed to add attachment"
+	MsgAttachmentUpdateSuccess   = "Attachment updated successfully"
+	MsgAttachmentUpdateFailed    = "Failed to update attachment"
+	MsgAttachmentsReorderSuccess = "Attachments reordered successfully"
+	MsgAttachmentsReorderFailed  = "Failed to reorder attachments"
+	MsgAttachmentDeleteSuccess   = "Attachment deleted successfully"
+	MsgAttachmentDeleteFailed    = "Failed to delete attachment"
+)
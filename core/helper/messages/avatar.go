@@ -0,0 +1,5 @@
+package messages
+
+const (
+	MsgAvatarFetchFailed = "Failed to fetch avatar"
+)
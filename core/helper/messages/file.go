@@ -1,6 +1,14 @@
 package messages
 
 const (
-	MsgFileFetchSuccess = "File fetched successfully"
-	MsgFileFetchFailed  = "Failed to fetch file"
+	MsgFileFetchSuccess    = "File fetched successfully"
+	MsgFileFetchFailed     = "Failed to fetch file"
+	MsgFilesFetchSuccess   = "Files fetched successfully"
+	MsgFilesFetchFailed    = "Failed to fetch files"
+	MsgFileMetadataSuccess = "File metadata fetched successfully"
+	MsgFileMetadataFailed  = "Failed to fetch file metadata"
+	MsgFileDeleteSuccess   = "File deleted successfully"
+	MsgFileDeleteFailed    = "Failed to delete file"
+	MsgGarbageFetchSuccess = "Garbage files fetched successfully"
+	MsgGarbageFetchFailed  = "Failed to fetch garbage files"
 )
@@ -0,0 +1,7 @@
+package messages
+
+const (
+	MsgServiceAlive    = "Service is alive"
+	MsgServiceReady    = "Service is ready"
+	MsgServiceNotReady = "Service is not ready"
+)
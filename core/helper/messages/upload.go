@@ -0,0 +1,9 @@
+package messages
+
+const (
+	MsgUploadCreateSuccess = "Upload created successfully"
+	MsgUploadCreateFailed  = "Failed to create upload"
+	MsgUploadFetchFailed   = "Failed to fetch upload"
+	MsgUploadWriteFailed   = "Failed to write upload"
+	MsgUploadDeleteFailed  = "Failed to delete upload"
+)
@@ -24,4 +24,20 @@ const (
 
 	MsgUserPictureDeleteSuccess = "User picture delete successful"
 	MsgUserPictureDeleteFailed  = "Failed to process user picture delete request"
+
+	MsgUserStatusUpdateSuccess = "User status update successful"
+	MsgUserStatusUpdateFailed  = "Failed to process user status update request"
+
+	MsgUserStatusHistoryFetchSuccess = "User status history fetched successfully"
+	MsgUserStatusHistoryFetchFailed  = "Failed to fetch user status history"
+
+	MsgUserNotActive = "User account is not active"
+
+	MsgUserLoginsFetchSuccess = "User login history fetched successfully"
+	MsgUserLoginsFetchFailed  = "Failed to fetch user login history"
+
+	MsgUserStorageFetchSuccess = "User storage usage fetched successfully"
+	MsgUserStorageFetchFailed  = "Failed to fetch user storage usage"
+	MsgUserStorageQuotaSuccess = "User storage quota update successful"
+	MsgUserStorageQuotaFailed  = "Failed to process user storage quota update request"
 )
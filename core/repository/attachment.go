@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/zetsux/gin-gorm-clean-starter/common/constant"
+	"github.com/zetsux/gin-gorm-clean-starter/core/entity"
+
+	"gorm.io/gorm"
+)
+
+type attachmentRepository struct {
+	txr *txRepository
+}
+
+type AttachmentRepository interface {
+	// tx
+	TxRepository() *txRepository
+
+	// functional
+	CreateAttachment(ctx context.Context, tx *gorm.DB, attachment entity.Attachment) (entity.Attachment, error)
+	GetAttachmentByID(ctx context.Context, tx *gorm.DB,
+		ownerType string, ownerID string, id string) (entity.Attachment, error)
+	GetAttachmentsByOwner(ctx context.Context, tx *gorm.DB, ownerType string, ownerID string) ([]entity.Attachment, error)
+	GetNextAttachmentPosition(ctx context.Context, tx *gorm.DB, ownerType string, ownerID string) (int, error)
+	LockAttachmentOwner(ctx context.Context, tx *gorm.DB, ownerType string, ownerID string) error
+	UpdateAttachment(ctx context.Context, tx *gorm.DB, attachment entity.Attachment) error
+	UpdateAttachmentPosition(ctx context.Context, tx *gorm.DB, id string, position int) error
+	DeleteAttachmentByID(ctx context.Context, tx *gorm.DB, id string) error
+	DeleteAttachmentsByOwner(ctx context.Context, tx *gorm.DB,
+		ownerType string, ownerID string) ([]entity.Attachment, error)
+}
+
+func NewAttachmentRepository(txr *txRepository) *attachmentRepository {
+	return &attachmentRepository{txr: txr}
+}
+
+func (ar *attachmentRepository) TxRepository() *txRepository {
+	return ar.txr
+}
+
+func (ar *attachmentRepository) CreateAttachment(ctx context.Context,
+	tx *gorm.DB, attachment entity.Attachment) (entity.Attachment, error) {
+	if tx == nil {
+		tx = ar.txr.DB()
+	}
+
+	if err := tx.WithContext(ctx).Create(&attachment).Error; err != nil {
+		return entity.Attachment{}, err
+	}
+	return attachment, nil
+}
+
+func (ar *attachmentRepository) GetAttachmentByID(ctx context.Context, tx *gorm.DB,
+	ownerType string, ownerID string, id string) (entity.Attachment, error) {
+	var attachment entity.Attachment
+
+	if tx == nil {
+		tx = ar.txr.DB()
+	}
+
+	err := tx.WithContext(ctx).Preload("File.Variants").
+		Where("owner_type = ? AND owner_id = ?", ownerType, ownerID).
+		Where(constant.DBAttrID+" = ?", id).Take(&attachment).Error
+	if err != nil && !(errors.Is(err, gorm.ErrRecordNotFound)) {
+		return attachment, err
+	}
+	return attachment, nil
+}
+
+func (ar *attachmentRepository) GetAttachmentsByOwner(ctx context.Context,
+	tx *gorm.DB, ownerType string, ownerID string) ([]entity.Attachment, error) {
+	var attachments []entity.Attachment
+
+	if tx == nil {
+		tx = ar.txr.DB()
+	}
+
+	err := tx.WithContext(ctx).Preload("File.Variants").
+		Where("owner_type = ? AND owner_id = ?", ownerType, ownerID).
+		Order("position, created_at").Find(&attachments).Error
+	if err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+// GetNextAttachmentPosition returns the position right after the last
+// attachment of the owner.
+func (ar *attachmentRepository) GetNextAttachmentPosition(ctx context.Context,
+	tx *gorm.DB, ownerType string, ownerID string) (int, error) {
+	var position int
+
+	if tx == nil {
+		tx = ar.txr.DB()
+	}
+
+	err := tx.WithContext(ctx).Model(&entity.Attachment{}).
+		Select("COALESCE(MAX(position) + 1, 0)").
+		Where("owner_type = ? AND owner_id = ?", ownerType, ownerID).
+		Scan(&position).Error
+	return position, err
+}
+
+// LockAttachmentOwner locks the attachments of the owner until tx ends, so
+// that their positions are changed by a single transaction at a time. As
+// owners can be of any type, the lock is an advisory one on the owner rather
+// than a row lock, and tx must be a transaction.
+func (ar *attachmentRepository) LockAttachmentOwner(ctx context.Context,
+	tx *gorm.DB, ownerType string, ownerID string) error {
+	if tx == nil {
+		tx = ar.txr.DB()
+	}
+
+	return tx.WithContext(ctx).Exec("SELECT pg_advisory_xact_lock(hashtext(?))", ownerType+"/"+ownerID).Error
+}
+
+func (ar *attachmentRepository) UpdateAttachment(ctx context.Context, tx *gorm.DB, attachment entity.Attachment) error {
+	if tx == nil {
+		tx = ar.txr.DB()
+	}
+
+	return tx.WithContext(ctx).Model(&attachment).
+		Select("caption", "visibility").
+		Updates(&attachment).Error
+}
+
+func (ar *attachmentRepository) UpdateAttachmentPosition(ctx context.Context,
+	tx *gorm.DB, id string, position int) error {
+	if tx == nil {
+		tx = ar.txr.DB()
+	}
+
+	return tx.WithContext(ctx).Model(&entity.Attachment{}).
+		Where(constant.DBAttrID+" = ?", id).
+		Update("position", position).Error
+}
+
+func (ar *attachmentRepository) DeleteAttachmentByID(ctx context.Context, tx *gorm.DB, id string) error {
+	if tx == nil {
+		tx = ar.txr.DB()
+	}
+
+	return tx.WithContext(ctx).Delete(&entity.Attachment{}, constant.DBAttrID+" = ?", id).Error
+}
+
+// DeleteAttachmentsByOwner deletes every attachment of the owner, returning
+// them so that their files can be released.
+func (ar *attachmentRepository) DeleteAttachmentsByOwner(ctx context.Context,
+	tx *gorm.DB, ownerType string, ownerID string) ([]entity.Attachment, error) {
+	var attachments []entity.Attachment
+
+	if tx == nil {
+		tx = ar.txr.DB()
+	}
+
+	err := tx.WithContext(ctx).Raw("UPDATE attachments SET deleted_at = NOW() "+
+		"WHERE owner_type = ? AND owner_id = ? AND deleted_at IS NULL RETURNING *", ownerType, ownerID).
+		Scan(&attachments).Error
+	if err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
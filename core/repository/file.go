@@ -0,0 +1,395 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/zetsux/gin-gorm-clean-starter/common/base"
+	"github.com/zetsux/gin-gorm-clean-starter/common/constant"
+	"github.com/zetsux/gin-gorm-clean-starter/core/entity"
+	errs "github.com/zetsux/gin-gorm-clean-starter/core/helper/errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type fileRepository struct {
+	txr *txRepository
+}
+
+type FileRepository interface {
+	// tx
+	TxRepository() *txRepository
+
+	// functional
+	CreateFile(ctx context.Context, tx *gorm.DB, file entity.File) (entity.File, error)
+	GetFileByID(ctx context.Context, tx *gorm.DB, id string) (entity.File, error)
+	GetAllFiles(ctx context.Context, tx *gorm.DB, req base.GetsRequest) ([]entity.File, int64, int64, error)
+	DeleteFileByID(ctx context.Context, tx *gorm.DB, id string) error
+	GetUnreferencedFiles(ctx context.Context, tx *gorm.DB, dir string,
+		refTable string, refColumn string, refByID bool, before time.Time) ([]entity.File, error)
+	IsFileReferenced(ctx context.Context, tx *gorm.DB, file entity.File,
+		refTable string, refColumn string, refByID bool) (bool, error)
+	GetReferencedStorageKeys(ctx context.Context, tx *gorm.DB) ([]string, error)
+	GetStorageUsage(ctx context.Context, tx *gorm.DB, ownerID string) (int64, int64, error)
+
+	// reservation
+	ReserveStorage(ctx context.Context, tx *gorm.DB, reservation entity.StorageReservation,
+		quota int64) (entity.StorageReservation, error)
+	GetReservedStorage(ctx context.Context, tx *gorm.DB, ownerID string) (int64, error)
+	DeleteStorageReservationByID(ctx context.Context, tx *gorm.DB, id string) error
+
+	// variant
+	CreateFileVariant(ctx context.Context, tx *gorm.DB, variant entity.FileVariant) (entity.FileVariant, error)
+
+	// blob
+	AcquireBlob(ctx context.Context, tx *gorm.DB, blob entity.FileBlob) (entity.FileBlob, error)
+	ReleaseBlob(ctx context.Context, tx *gorm.DB, sha256 string) (entity.FileBlob, error)
+}
+
+func NewFileRepository(txr *txRepository) *fileRepository {
+	return &fileRepository{txr: txr}
+}
+
+func (fr *fileRepository) TxRepository() *txRepository {
+	return fr.txr
+}
+
+func (fr *fileRepository) CreateFile(ctx context.Context, tx *gorm.DB, file entity.File) (entity.File, error) {
+	if tx == nil {
+		tx = fr.txr.DB()
+	}
+
+	if err := tx.WithContext(ctx).Create(&file).Error; err != nil {
+		return entity.File{}, err
+	}
+	return file, nil
+}
+
+func (fr *fileRepository) GetFileByID(ctx context.Context, tx *gorm.DB, id string) (entity.File, error) {
+	var file entity.File
+
+	if tx == nil {
+		tx = fr.txr.DB()
+	}
+
+	err := tx.WithContext(ctx).Preload("Variants").Where(constant.DBAttrID+" = ?", id).Take(&file).Error
+	if err != nil && !(errors.Is(err, gorm.ErrRecordNotFound)) {
+		return file, err
+	}
+	return file, nil
+}
+
+func (fr *fileRepository) GetAllFiles(ctx context.Context, tx *gorm.DB,
+	req base.GetsRequest) ([]entity.File, int64, int64, error) {
+	var files []entity.File
+	var total int64
+
+	if tx == nil {
+		tx = fr.txr.DB()
+	}
+
+	stmt := tx.WithContext(ctx).Preload("Variants")
+	countStmt := tx.WithContext(ctx).Model(&entity.File{})
+	if req.Search != "" {
+		searchQuery := "%" + req.Search + "%"
+		stmt = stmt.Where("original_name ILIKE ? OR dir ILIKE ?", searchQuery, searchQuery)
+		countStmt = countStmt.Where("original_name ILIKE ? OR dir ILIKE ?", searchQuery, searchQuery)
+	}
+
+	if err := countStmt.Count(&total).Error; err != nil {
+		return nil, 0, 0, err
+	}
+
+	if req.Sort != "" {
+		stmt = stmt.Order(req.Sort)
+	} else {
+		stmt = stmt.Order("created_at DESC")
+	}
+
+	var err error
+	lastPage := int64(math.Ceil(float64(total) / float64(req.PerPage)))
+	if req.PerPage == 0 {
+		err = stmt.Find(&files).Error
+	} else {
+		if req.Page <= 0 || int64(req.Page) > lastPage {
+			return nil, 0, 0, errs.ErrInvalidPage
+		}
+		err = stmt.Offset(((req.Page - 1) * req.PerPage)).Limit(req.PerPage).Find(&files).Error
+	}
+
+	if err != nil && !(errors.Is(err, gorm.ErrRecordNotFound)) {
+		return files, 0, 0, err
+	}
+	return files, lastPage, total, nil
+}
+
+// DeleteFileByID permanently deletes the file metadata along with its variants,
+// as the reference it holds on its blob is released along with it.
+func (fr *fileRepository) DeleteFileByID(ctx context.Context, tx *gorm.DB, id string) error {
+	if tx == nil {
+		tx = fr.txr.DB()
+	}
+
+	err := tx.WithContext(ctx).Delete(&entity.FileVariant{}, "file_id = ?", id).Error
+	if err != nil {
+		return err
+	}
+	res := tx.WithContext(ctx).Unscoped().Delete(&entity.File{}, constant.DBAttrID+" = ?", id)
+	if res.Error != nil {
+		return res.Error
+	}
+
+	// the file is already gone, so its reference must not be released twice
+	if res.RowsAffected == 0 {
+		return errs.ErrFileNotFound
+	}
+	return nil
+}
+
+// GetUnreferencedFiles lists the files under dir created before the given
+// time which no live row of refTable points at through refColumn, holding the
+// path of the file or its ID when refByID is set. Quarantined files are kept
+// until an admin deletes them. refTable and refColumn must not come from user input.
+func (fr *fileRepository) GetUnreferencedFiles(ctx context.Context, tx *gorm.DB, dir string,
+	refTable string, refColumn string, refByID bool, before time.Time) ([]entity.File, error) {
+	var files []entity.File
+
+	if tx == nil {
+		tx = fr.txr.DB()
+	}
+
+	ref := "files.dir || '/' || files.id::text"
+	if refByID {
+		ref = "files.id"
+	}
+
+	err := tx.WithContext(ctx).Preload("Variants").
+		Where("dir = ? AND created_at < ?", dir, before).
+		Where("scan_status NOT IN ?", []string{constant.EnumScanStatusInfected, constant.EnumScanStatusFailed}).
+		Where(fmt.Sprintf("NOT EXISTS (SELECT 1 FROM %[1]s WHERE %[1]s.deleted_at IS NULL AND "+
+			"%[1]s.%[2]s = %[3]s)", refTable, refColumn, ref)).
+		Find(&files).Error
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// IsFileReferenced tells whether a live row of refTable points at file through
+// refColumn, holding the path of the file or its ID when refByID is set.
+// refTable and refColumn must not come from user input.
+func (fr *fileRepository) IsFileReferenced(ctx context.Context, tx *gorm.DB, file entity.File,
+	refTable string, refColumn string, refByID bool) (bool, error) {
+	var referenced bool
+
+	if tx == nil {
+		tx = fr.txr.DB()
+	}
+
+	ref := file.Dir + "/" + file.ID.String()
+	if refByID {
+		ref = file.ID.String()
+	}
+
+	err := tx.WithContext(ctx).Raw(fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %[1]s WHERE %[1]s.deleted_at IS NULL AND "+
+		"%[1]s.%[2]s = ?)", refTable, refColumn), ref).Scan(&referenced).Error
+	return referenced, err
+}
+
+// GetReferencedStorageKeys lists every storage key still in use, being the
+// stored contents including quarantined ones, variants and the chunks of the
+// uploads which have not expired, along with the pictures of live users which
+// were stored before file metadata was tracked.
+func (fr *fileRepository) GetReferencedStorageKeys(ctx context.Context, tx *gorm.DB) ([]string, error) {
+	var keys []string
+
+	if tx == nil {
+		tx = fr.txr.DB()
+	}
+
+	err := tx.WithContext(ctx).Raw("SELECT storage_key FROM files "+
+		"UNION SELECT storage_key FROM file_blobs "+
+		"UNION SELECT storage_key FROM file_variants "+
+		"UNION SELECT upload_parts.storage_key FROM upload_parts "+
+		"JOIN uploads ON uploads.id = upload_parts.upload_id WHERE uploads.expires_at > ? "+
+		"UNION SELECT picture FROM users WHERE deleted_at IS NULL AND picture IS NOT NULL AND picture <> ''",
+		time.Now()).Scan(&keys).Error
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// GetStorageUsage counts the files of the owner along with the bytes they take,
+// variants included. Deduplicated content is counted once per file.
+func (fr *fileRepository) GetStorageUsage(ctx context.Context, tx *gorm.DB, ownerID string) (int64, int64, error) {
+	var usage struct {
+		Files int64
+		Size  int64
+	}
+
+	if tx == nil {
+		tx = fr.txr.DB()
+	}
+
+	err := tx.WithContext(ctx).Model(&entity.File{}).
+		Select("COUNT(*) AS files, COALESCE(SUM(files.size + COALESCE("+
+			"(SELECT SUM(file_variants.size) FROM file_variants WHERE file_variants.file_id = files.id), 0)), 0) AS size").
+		Where("owner_id = ?", ownerID).
+		Scan(&usage).Error
+	if err != nil {
+		return 0, 0, err
+	}
+	return usage.Files, usage.Size, nil
+}
+
+// ReserveStorage saves reservation, replacing the one with the same ID, unless
+// the files of its owner along with its other live reservations would then take
+// more than quota bytes. The owner is locked until tx ends, so that concurrent
+// reservations are checked one after another, which is why tx must be a
+// transaction. Expired reservations of the owner are dropped on the way.
+func (fr *fileRepository) ReserveStorage(ctx context.Context, tx *gorm.DB,
+	reservation entity.StorageReservation, quota int64) (entity.StorageReservation, error) {
+	if tx == nil {
+		tx = fr.txr.DB()
+	}
+
+	var owner entity.User
+	err := tx.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).Select(constant.DBAttrID).
+		Where(constant.DBAttrID+" = ?", reservation.OwnerID).Take(&owner).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return entity.StorageReservation{}, errs.ErrUserNotFound
+	} else if err != nil {
+		return entity.StorageReservation{}, err
+	}
+
+	now := time.Now()
+	err = tx.WithContext(ctx).Delete(&entity.StorageReservation{},
+		"owner_id = ? AND expires_at <= ?", reservation.OwnerID, now).Error
+	if err != nil {
+		return entity.StorageReservation{}, err
+	}
+
+	_, used, err := fr.GetStorageUsage(ctx, tx, reservation.OwnerID.String())
+	if err != nil {
+		return entity.StorageReservation{}, err
+	}
+
+	var reserved int64
+	err = tx.WithContext(ctx).Model(&entity.StorageReservation{}).Select("COALESCE(SUM(size), 0)").
+		Where("owner_id = ? AND "+constant.DBAttrID+" <> ?", reservation.OwnerID, reservation.ID).
+		Scan(&reserved).Error
+	if err != nil {
+		return entity.StorageReservation{}, err
+	}
+
+	if used+reserved+reservation.Size > quota {
+		return entity.StorageReservation{}, errs.ErrStorageQuotaExceeded
+	}
+
+	err = tx.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: constant.DBAttrID}},
+		DoUpdates: clause.AssignmentColumns([]string{"size", "expires_at", "updated_at"}),
+	}).Create(&reservation).Error
+	if err != nil {
+		return entity.StorageReservation{}, err
+	}
+	return reservation, nil
+}
+
+// GetReservedStorage sums the sizes of the live reservations of the owner.
+func (fr *fileRepository) GetReservedStorage(ctx context.Context, tx *gorm.DB, ownerID string) (int64, error) {
+	var reserved int64
+
+	if tx == nil {
+		tx = fr.txr.DB()
+	}
+
+	err := tx.WithContext(ctx).Model(&entity.StorageReservation{}).Select("COALESCE(SUM(size), 0)").
+		Where("owner_id = ? AND expires_at > ?", ownerID, time.Now()).
+		Scan(&reserved).Error
+	if err != nil {
+		return 0, err
+	}
+	return reserved, nil
+}
+
+// DeleteStorageReservationByID releases a reservation, releasing one which is
+// already gone is not an error.
+func (fr *fileRepository) DeleteStorageReservationByID(ctx context.Context, tx *gorm.DB, id string) error {
+	if tx == nil {
+		tx = fr.txr.DB()
+	}
+
+	return tx.WithContext(ctx).Delete(&entity.StorageReservation{}, constant.DBAttrID+" = ?", id).Error
+}
+
+func (fr *fileRepository) CreateFileVariant(ctx context.Context,
+	tx *gorm.DB, variant entity.FileVariant) (entity.FileVariant, error) {
+	if tx == nil {
+		tx = fr.txr.DB()
+	}
+
+	if err := tx.WithContext(ctx).Create(&variant).Error; err != nil {
+		return entity.FileVariant{}, err
+	}
+	return variant, nil
+}
+
+// AcquireBlob stores blob with a single reference, or adds a reference to the
+// blob already holding the same content. The returned blob is the stored one,
+// so a storage key other than the given one means the content was a duplicate.
+func (fr *fileRepository) AcquireBlob(ctx context.Context,
+	tx *gorm.DB, blob entity.FileBlob) (entity.FileBlob, error) {
+	if tx == nil {
+		tx = fr.txr.DB()
+	}
+
+	blob.RefCount = 1
+	err := tx.WithContext(ctx).Clauses(
+		clause.OnConflict{
+			Columns: []clause.Column{{Name: "sha256"}},
+			DoUpdates: clause.Assignments(map[string]any{
+				"ref_count":  gorm.Expr("file_blobs.ref_count + 1"),
+				"updated_at": gorm.Expr("NOW()"),
+			}),
+		},
+		clause.Returning{},
+	).Create(&blob).Error
+	if err != nil {
+		return entity.FileBlob{}, err
+	}
+	return blob, nil
+}
+
+// ReleaseBlob removes a reference from the blob and deletes the blob once no
+// reference is left, in which case the returned blob has a RefCount of 0.
+func (fr *fileRepository) ReleaseBlob(ctx context.Context, tx *gorm.DB, sha256 string) (entity.FileBlob, error) {
+	var blob entity.FileBlob
+
+	if tx == nil {
+		tx = fr.txr.DB()
+	}
+
+	err := tx.WithContext(ctx).Raw("UPDATE file_blobs SET ref_count = ref_count - 1, updated_at = ? "+
+		"WHERE sha256 = ? RETURNING *", time.Now(), sha256).Scan(&blob).Error
+	if err != nil {
+		return entity.FileBlob{}, err
+	}
+
+	if blob.SHA256 == "" {
+		return entity.FileBlob{}, errs.ErrFileNotFound
+	}
+
+	if blob.RefCount <= 0 {
+		err = tx.WithContext(ctx).Delete(&entity.FileBlob{}, "sha256 = ?", sha256).Error
+		if err != nil {
+			return entity.FileBlob{}, err
+		}
+	}
+	return blob, nil
+}
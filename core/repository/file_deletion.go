@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/zetsux/gin-gorm-clean-starter/common/constant"
+	"github.com/zetsux/gin-gorm-clean-starter/core/entity"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type fileDeletionRepository struct {
+	txr *txRepository
+}
+
+type FileDeletionRepository interface {
+	// tx
+	TxRepository() *txRepository
+
+	// functional
+	CreateFileDeletion(ctx context.Context, tx *gorm.DB, deletion entity.FileDeletion) (entity.FileDeletion, error)
+	GetFileDeletionByID(ctx context.Context, tx *gorm.DB, id string) (entity.FileDeletion, error)
+	ClaimDueFileDeletions(ctx context.Context, tx *gorm.DB,
+		now time.Time, until time.Time, limit int) ([]entity.FileDeletion, error)
+	RescheduleFileDeletion(ctx context.Context, tx *gorm.DB, deletion entity.FileDeletion) error
+	DeleteFileDeletionByID(ctx context.Context, tx *gorm.DB, id string) error
+}
+
+func NewFileDeletionRepository(txr *txRepository) *fileDeletionRepository {
+	return &fileDeletionRepository{txr: txr}
+}
+
+func (fdr *fileDeletionRepository) TxRepository() *txRepository {
+	return fdr.txr
+}
+
+func (fdr *fileDeletionRepository) CreateFileDeletion(ctx context.Context,
+	tx *gorm.DB, deletion entity.FileDeletion) (entity.FileDeletion, error) {
+	if tx == nil {
+		tx = fdr.txr.DB()
+	}
+
+	if err := tx.WithContext(ctx).Create(&deletion).Error; err != nil {
+		return entity.FileDeletion{}, err
+	}
+	return deletion, nil
+}
+
+func (fdr *fileDeletionRepository) GetFileDeletionByID(ctx context.Context,
+	tx *gorm.DB, id string) (entity.FileDeletion, error) {
+	var deletion entity.FileDeletion
+
+	if tx == nil {
+		tx = fdr.txr.DB()
+	}
+
+	err := tx.WithContext(ctx).Where(constant.DBAttrID+" = ?", id).Take(&deletion).Error
+	if err != nil && !(errors.Is(err, gorm.ErrRecordNotFound)) {
+		return deletion, err
+	}
+	return deletion, nil
+}
+
+// ClaimDueFileDeletions takes up to limit deletions due by now and postpones
+// them until the given time, so that concurrent workers never claim the same
+// deletion while it is being processed.
+func (fdr *fileDeletionRepository) ClaimDueFileDeletions(ctx context.Context, tx *gorm.DB,
+	now time.Time, until time.Time, limit int) ([]entity.FileDeletion, error) {
+	var deletions []entity.FileDeletion
+
+	if tx == nil {
+		tx = fdr.txr.DB()
+	}
+
+	due := tx.Model(&entity.FileDeletion{}).Select(constant.DBAttrID).
+		Where("next_attempt_at <= ?", now).
+		Order("next_attempt_at").Limit(limit).
+		Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+
+	err := tx.WithContext(ctx).Raw("UPDATE file_deletions SET next_attempt_at = ? "+
+		"WHERE "+constant.DBAttrID+" IN (?) RETURNING *", until, due).Scan(&deletions).Error
+	if err != nil {
+		return nil, err
+	}
+	return deletions, nil
+}
+
+// RescheduleFileDeletion stores the outcome of a failed attempt along with
+// when the next one is due.
+func (fdr *fileDeletionRepository) RescheduleFileDeletion(ctx context.Context,
+	tx *gorm.DB, deletion entity.FileDeletion) error {
+	if tx == nil {
+		tx = fdr.txr.DB()
+	}
+
+	return tx.WithContext(ctx).Model(&deletion).
+		Select("attempts", "last_error", "next_attempt_at").
+		Updates(&deletion).Error
+}
+
+func (fdr *fileDeletionRepository) DeleteFileDeletionByID(ctx context.Context, tx *gorm.DB, id string) error {
+	if tx == nil {
+		tx = fdr.txr.DB()
+	}
+
+	return tx.WithContext(ctx).Delete(&entity.FileDeletion{}, constant.DBAttrID+" = ?", id).Error
+}
@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zetsux/gin-gorm-clean-starter/common/constant"
+	"github.com/zetsux/gin-gorm-clean-starter/core/entity"
+	errs "github.com/zetsux/gin-gorm-clean-starter/core/helper/errors"
+	migration "github.com/zetsux/gin-gorm-clean-starter/database"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB connects to the database of TEST_DATABASE_DSN and migrates it,
+// skipping the test when no database is given.
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("connecting to the database: %v", err)
+	}
+	migration.DBMigrate(db)
+	return db
+}
+
+func TestReserveStorageLocksTheOwner(t *testing.T) {
+	var statements []string
+	db := newDryRunDB(t, &statements)
+	err := db.Callback().Query().After("gorm:query").Register("test:queries", func(db *gorm.DB) {
+		statements = append(statements, db.Dialector.Explain(db.Statement.SQL.String(), db.Statement.Vars...))
+	})
+	if err != nil {
+		t.Fatalf("registering the callback: %v", err)
+	}
+
+	fr := NewFileRepository(NewTxRepository(db))
+	fr.ReserveStorage(context.Background(), db, entity.StorageReservation{
+		ID:      uuid.New(),
+		OwnerID: uuid.New(),
+		Size:    1,
+	}, 100)
+
+	// the owner is locked before anything the check depends on is read
+	if len(statements) == 0 || !strings.Contains(statements[0], `FROM "users"`) ||
+		!strings.HasSuffix(statements[0], "FOR UPDATE") {
+		t.Errorf("ReserveStorage does not start by locking the owner: %v", statements)
+	}
+}
+
+func TestReserveStorageConcurrently(t *testing.T) {
+	db := newTestDB(t)
+	txr := NewTxRepository(db)
+	fr := NewFileRepository(txr)
+	ctx := context.Background()
+
+	owner := entity.User{
+		ID:       uuid.New(),
+		Name:     "Quota",
+		Email:    uuid.NewString() + "@mail.test",
+		Password: "password",
+		Role:     constant.EnumRoleUser,
+	}
+	if err := db.Create(&owner).Error; err != nil {
+		t.Fatalf("creating the owner: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Delete(&entity.StorageReservation{}, "owner_id = ?", owner.ID)
+		db.Unscoped().Delete(&owner)
+	})
+
+	// each reservation fits in the quota on its own, but not along with the other
+	reserve := func(tx *gorm.DB) error {
+		_, err := fr.ReserveStorage(ctx, tx, entity.StorageReservation{
+			ID:        uuid.New(),
+			OwnerID:   owner.ID,
+			Size:      60,
+			ExpiresAt: time.Now().Add(time.Hour),
+		}, 100)
+		return err
+	}
+
+	first, err := txr.BeginTx(ctx)
+	if err != nil {
+		t.Fatalf("BeginTx: %v", err)
+	}
+	if err := reserve(first); err != nil {
+		txr.CommitOrRollbackTx(ctx, first, err)
+		t.Fatalf("the first reservation failed: %v", err)
+	}
+
+	second := make(chan error, 1)
+	go func() {
+		tx, err := txr.BeginTx(ctx)
+		if err != nil {
+			second <- err
+			return
+		}
+		second <- txr.CommitOrRollbackTx(ctx, tx, reserve(tx))
+	}()
+
+	select {
+	case err := <-second:
+		txr.CommitOrRollbackTx(ctx, first, errors.New("test failed"))
+		t.Fatalf("the second reservation returned %v before the first one was committed", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	if err := txr.CommitOrRollbackTx(ctx, first, nil); err != nil {
+		t.Fatalf("committing the first reservation: %v", err)
+	}
+
+	select {
+	case err := <-second:
+		if !errors.Is(err, errs.ErrStorageQuotaExceeded) {
+			t.Errorf("the second reservation returned %v, want %v", err, errs.ErrStorageQuotaExceeded)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the second reservation was not checked once the first one was committed")
+	}
+
+	if reserved, err := fr.GetReservedStorage(ctx, nil, owner.ID.String()); err != nil || reserved != 60 {
+		t.Errorf("GetReservedStorage returned %d, %v, want only the first reservation", reserved, err)
+	}
+}
@@ -0,0 +1,26 @@
+package repository
+
+import (
+	"context"
+)
+
+type healthRepository struct {
+	txr *txRepository
+}
+
+type HealthRepository interface {
+	// Ping checks that the database is reachable
+	Ping(ctx context.Context) error
+}
+
+func NewHealthRepository(txr *txRepository) *healthRepository {
+	return &healthRepository{txr: txr}
+}
+
+func (hr *healthRepository) Ping(ctx context.Context) error {
+	sqlDB, err := hr.txr.DB().DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
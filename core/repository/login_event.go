@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"math"
+
+	"github.com/zetsux/gin-gorm-clean-starter/common/base"
+	"github.com/zetsux/gin-gorm-clean-starter/core/entity"
+	errs "github.com/zetsux/gin-gorm-clean-starter/core/helper/errors"
+
+	"gorm.io/gorm"
+)
+
+type loginEventRepository struct {
+	txr *txRepository
+}
+
+type LoginEventRepository interface {
+	// tx
+	TxRepository() *txRepository
+
+	// functional
+	CreateLoginEvent(ctx context.Context, tx *gorm.DB, event entity.LoginEvent) (entity.LoginEvent, error)
+	GetLoginEventsByUserID(ctx context.Context, tx *gorm.DB,
+		userID string, req base.GetsRequest) ([]entity.LoginEvent, int64, int64, error)
+}
+
+func NewLoginEventRepository(txr *txRepository) *loginEventRepository {
+	return &loginEventRepository{txr: txr}
+}
+
+func (ler *loginEventRepository) TxRepository() *txRepository {
+	return ler.txr
+}
+
+func (ler *loginEventRepository) CreateLoginEvent(ctx context.Context,
+	tx *gorm.DB, event entity.LoginEvent) (entity.LoginEvent, error) {
+	if tx == nil {
+		tx = ler.txr.DB()
+	}
+
+	if err := tx.WithContext(ctx).Create(&event).Error; err != nil {
+		return entity.LoginEvent{}, err
+	}
+	return event, nil
+}
+
+func (ler *loginEventRepository) GetLoginEventsByUserID(ctx context.Context, tx *gorm.DB,
+	userID string, req base.GetsRequest) ([]entity.LoginEvent, int64, int64, error) {
+	var events []entity.LoginEvent
+	var total int64
+
+	if tx == nil {
+		tx = ler.txr.DB()
+	}
+
+	err := tx.WithContext(ctx).Model(&entity.LoginEvent{}).Where("user_id = ?", userID).Count(&total).Error
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	stmt := tx.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC")
+
+	lastPage := int64(math.Ceil(float64(total) / float64(req.PerPage)))
+	if req.PerPage == 0 {
+		err = stmt.Find(&events).Error
+	} else {
+		if req.Page <= 0 || int64(req.Page) > lastPage {
+			return nil, 0, 0, errs.ErrInvalidPage
+		}
+		err = stmt.Offset(((req.Page - 1) * req.PerPage)).Limit(req.PerPage).Find(&events).Error
+	}
+
+	if err != nil && !(errors.Is(err, gorm.ErrRecordNotFound)) {
+		return events, 0, 0, err
+	}
+	return events, lastPage, total, nil
+}
@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/zetsux/gin-gorm-clean-starter/common/constant"
+	errs "github.com/zetsux/gin-gorm-clean-starter/core/helper/errors"
+
+	"gorm.io/gorm"
+)
+
+// applySparse narrows stmt to the requested fields and preloads the requested
+// includes, both of which are validated against the given allow-lists that map
+// the public (JSON) name to the column or relation name.
+func applySparse(stmt *gorm.DB, fields []string, includes []string,
+	selectable map[string]string, includable map[string]string) (*gorm.DB, error) {
+	if len(fields) > 0 {
+		// primary key is always needed to identify the row and to preload relations,
+		// while version is always needed to tag the representation
+		columns := []string{constant.DBAttrID, constant.DBAttrVersion}
+		for _, field := range fields {
+			column, ok := selectable[field]
+			if !ok {
+				return nil, fmt.Errorf("%w: %s", errs.ErrInvalidField, field)
+			}
+			if !slices.Contains(columns, column) {
+				columns = append(columns, column)
+			}
+		}
+		stmt = stmt.Select(columns)
+	}
+
+	for _, include := range includes {
+		relation, ok := includable[include]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", errs.ErrInvalidInclude, include)
+		}
+		stmt = stmt.Preload(relation)
+	}
+	return stmt, nil
+}
@@ -2,7 +2,7 @@ package repository
 
 import (
 	"context"
-	"log"
+	"log/slog"
 
 	"gorm.io/gorm"
 )
@@ -17,7 +17,7 @@ type TxRepository interface {
 
 	// tx
 	BeginTx(ctx context.Context, db *gorm.DB) (*gorm.DB, error)
-	CommitOrRollbackTx(ctx context.Context, tx *gorm.DB, err error)
+	CommitOrRollbackTx(ctx context.Context, tx *gorm.DB, err error) error
 }
 
 func NewTxRepository(db *gorm.DB) *txRepository {
@@ -36,17 +36,20 @@ func (txr txRepository) BeginTx(ctx context.Context) (*gorm.DB, error) {
 	return tx, nil
 }
 
-func (txr txRepository) CommitOrRollbackTx(ctx context.Context, tx *gorm.DB, err error) {
+// CommitOrRollbackTx ends tx depending on err, returning err or the error the
+// commit failed with so that callers know whether their changes went through.
+func (txr txRepository) CommitOrRollbackTx(ctx context.Context, tx *gorm.DB, err error) error {
 	if err != nil {
-		log.Println("Error occurred: ", err)
-		tx.WithContext(ctx).Debug().Rollback()
-		return
+		slog.DebugContext(ctx, "Rolling back transaction", "error", err)
+		tx.WithContext(ctx).Rollback()
+		return err
 	}
 
 	err = tx.WithContext(ctx).Commit().Error
 	if err != nil {
-		log.Println("Commit failed: ", err)
-		return
+		slog.ErrorContext(ctx, "Failed to commit transaction", "error", err)
+		return err
 	}
-	log.Println("Committed successfully")
+	slog.DebugContext(ctx, "Committed transaction")
+	return nil
 }
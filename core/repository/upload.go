@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/zetsux/gin-gorm-clean-starter/common/constant"
+	"github.com/zetsux/gin-gorm-clean-starter/core/entity"
+	errs "github.com/zetsux/gin-gorm-clean-starter/core/helper/errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type uploadRepository struct {
+	txr *txRepository
+}
+
+type UploadRepository interface {
+	// tx
+	TxRepository() *txRepository
+
+	// functional
+	CreateUpload(ctx context.Context, tx *gorm.DB, upload entity.Upload) (entity.Upload, error)
+	GetUploadByID(ctx context.Context, tx *gorm.DB, id string) (entity.Upload, error)
+	GetUploadParts(ctx context.Context, tx *gorm.DB, id string) ([]entity.UploadPart, error)
+	AdvanceUpload(ctx context.Context, tx *gorm.DB, upload entity.Upload, part entity.UploadPart) (entity.Upload, error)
+	ClaimUpload(ctx context.Context, tx *gorm.DB, id string, now time.Time, until time.Time) error
+	UnclaimUpload(ctx context.Context, tx *gorm.DB, id string) error
+	ClaimExpiredUploads(ctx context.Context, tx *gorm.DB,
+		now time.Time, until time.Time, limit int) ([]entity.Upload, error)
+	DeleteUploadByID(ctx context.Context, tx *gorm.DB, id string) error
+}
+
+func NewUploadRepository(txr *txRepository) *uploadRepository {
+	return &uploadRepository{txr: txr}
+}
+
+func (ur *uploadRepository) TxRepository() *txRepository {
+	return ur.txr
+}
+
+func (ur *uploadRepository) CreateUpload(ctx context.Context,
+	tx *gorm.DB, upload entity.Upload) (entity.Upload, error) {
+	if tx == nil {
+		tx = ur.txr.DB()
+	}
+
+	if err := tx.WithContext(ctx).Create(&upload).Error; err != nil {
+		return entity.Upload{}, err
+	}
+	return upload, nil
+}
+
+func (ur *uploadRepository) GetUploadByID(ctx context.Context, tx *gorm.DB, id string) (entity.Upload, error) {
+	var upload entity.Upload
+
+	if tx == nil {
+		tx = ur.txr.DB()
+	}
+
+	err := tx.WithContext(ctx).Where(constant.DBAttrID+" = ?", id).Take(&upload).Error
+	if err != nil && !(errors.Is(err, gorm.ErrRecordNotFound)) {
+		return upload, err
+	}
+	return upload, nil
+}
+
+func (ur *uploadRepository) GetUploadParts(ctx context.Context,
+	tx *gorm.DB, id string) ([]entity.UploadPart, error) {
+	var parts []entity.UploadPart
+
+	if tx == nil {
+		tx = ur.txr.DB()
+	}
+
+	err := tx.WithContext(ctx).Where("upload_id = ?", id).Order("\"offset\"").Find(&parts).Error
+	if err != nil {
+		return nil, err
+	}
+	return parts, nil
+}
+
+// AdvanceUpload records part as the next chunk of upload as long as the upload
+// is still at the offset the part was written from, so that concurrent chunks
+// for the same offset are detected.
+func (ur *uploadRepository) AdvanceUpload(ctx context.Context,
+	tx *gorm.DB, upload entity.Upload, part entity.UploadPart) (entity.Upload, error) {
+	if tx == nil {
+		tx = ur.txr.DB()
+	}
+
+	res := tx.WithContext(ctx).Model(&entity.Upload{}).
+		Where(constant.DBAttrID+" = ? AND \"offset\" = ?", upload.ID, upload.Offset).
+		UpdateColumn("offset", gorm.Expr("\"offset\" + ?", part.Size))
+	if res.Error != nil {
+		return entity.Upload{}, res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		return entity.Upload{}, errs.ErrUploadOffsetMismatch
+	}
+
+	part.UploadID, part.Offset = upload.ID, upload.Offset
+	if err := tx.WithContext(ctx).Create(&part).Error; err != nil {
+		return entity.Upload{}, err
+	}
+
+	upload.Offset += part.Size
+	return upload, nil
+}
+
+// ClaimUpload takes the upload until the given time unless someone else holds
+// it, failing with ErrUploadNotFound then, so that an upload is attached once.
+func (ur *uploadRepository) ClaimUpload(ctx context.Context,
+	tx *gorm.DB, id string, now time.Time, until time.Time) error {
+	if tx == nil {
+		tx = ur.txr.DB()
+	}
+
+	res := tx.WithContext(ctx).Model(&entity.Upload{}).
+		Where(constant.DBAttrID+" = ? AND (claimed_until IS NULL OR claimed_until <= ?)", id, now).
+		UpdateColumn("claimed_until", until)
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		return errs.ErrUploadNotFound
+	}
+	return nil
+}
+
+// UnclaimUpload gives up a claim on the upload before its lease is over.
+func (ur *uploadRepository) UnclaimUpload(ctx context.Context, tx *gorm.DB, id string) error {
+	if tx == nil {
+		tx = ur.txr.DB()
+	}
+
+	return tx.WithContext(ctx).Model(&entity.Upload{}).
+		Where(constant.DBAttrID+" = ?", id).
+		UpdateColumn("claimed_until", nil).Error
+}
+
+// ClaimExpiredUploads takes up to limit uploads which expired by now and are
+// not claimed until the given time, so that concurrent sweeps never delete the
+// same upload nor one being attached.
+func (ur *uploadRepository) ClaimExpiredUploads(ctx context.Context, tx *gorm.DB,
+	now time.Time, until time.Time, limit int) ([]entity.Upload, error) {
+	var uploads []entity.Upload
+
+	if tx == nil {
+		tx = ur.txr.DB()
+	}
+
+	expired := tx.Model(&entity.Upload{}).Select(constant.DBAttrID).
+		Where("expires_at <= ? AND (claimed_until IS NULL OR claimed_until <= ?)", now, now).
+		Order("expires_at").Limit(limit).
+		Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+
+	err := tx.WithContext(ctx).Raw("UPDATE uploads SET claimed_until = ? "+
+		"WHERE "+constant.DBAttrID+" IN (?) RETURNING *", until, expired).Scan(&uploads).Error
+	if err != nil {
+		return nil, err
+	}
+	return uploads, nil
+}
+
+func (ur *uploadRepository) DeleteUploadByID(ctx context.Context, tx *gorm.DB, id string) error {
+	if tx == nil {
+		tx = ur.txr.DB()
+	}
+
+	if err := tx.WithContext(ctx).Delete(&entity.UploadPart{}, "upload_id = ?", id).Error; err != nil {
+		return err
+	}
+	return tx.WithContext(ctx).Unscoped().Delete(&entity.Upload{}, constant.DBAttrID+" = ?", id).Error
+}
@@ -4,14 +4,45 @@ import (
 	"context"
 	"errors"
 	"math"
+	"time"
 
 	"github.com/zetsux/gin-gorm-clean-starter/common/base"
+	"github.com/zetsux/gin-gorm-clean-starter/common/constant"
+	"github.com/zetsux/gin-gorm-clean-starter/common/util"
 	"github.com/zetsux/gin-gorm-clean-starter/core/entity"
 	errs "github.com/zetsux/gin-gorm-clean-starter/core/helper/errors"
 
 	"gorm.io/gorm"
 )
 
+var (
+	// userSelectableFields maps the fields that can be picked through sparse
+	// fieldsets to their database columns
+	userSelectableFields = map[string]string{
+		"id":      constant.DBAttrID,
+		"name":    "name",
+		"email":   constant.DBAttrEmail,
+		"role":    "role",
+		"picture": "picture",
+		"version": constant.DBAttrVersion,
+
+		"picture_variants": "picture",
+
+		"status":            "status",
+		"status_reason":     "status_reason",
+		"status_expires_at": "status_expires_at",
+
+		"last_login_at": "last_login_at",
+		"last_seen_at":  "last_seen_at",
+	}
+
+	// userIncludableRelations maps the related resources that can be expanded
+	// through includes to their gorm relation names
+	userIncludableRelations = map[string]string{
+		"status_history": "StatusLogs",
+	}
+)
+
 type userRepository struct {
 	txr *txRepository
 }
@@ -23,10 +54,16 @@ type UserRepository interface {
 	// functional
 	CreateNewUser(ctx context.Context, tx *gorm.DB, user entity.User) (entity.User, error)
 	GetUserByPrimaryKey(ctx context.Context, tx *gorm.DB, key string, val string) (entity.User, error)
+	GetUserByID(ctx context.Context, tx *gorm.DB, id string, req base.GetRequest) (entity.User, error)
 	GetAllUsers(ctx context.Context, tx *gorm.DB, req base.GetsRequest) ([]entity.User, int64, int64, error)
 	UpdateNameUser(ctx context.Context, tx *gorm.DB, name string, user entity.User) (entity.User, error)
 	UpdateUser(ctx context.Context, tx *gorm.DB, user entity.User) (entity.User, error)
-	DeleteUserByID(ctx context.Context, tx *gorm.DB, id string) error
+	ReplaceUser(ctx context.Context, tx *gorm.DB, user entity.User) (entity.User, error)
+	UpdateUserStatus(ctx context.Context, tx *gorm.DB, user entity.User) (entity.User, error)
+	UpdateUserLastLogin(ctx context.Context, tx *gorm.DB, id string, at time.Time) error
+	UpdateUserLastSeen(ctx context.Context, tx *gorm.DB, id string, at time.Time) error
+	UpdateUserStorageQuota(ctx context.Context, tx *gorm.DB, id string, quota *int64) error
+	DeleteUserByID(ctx context.Context, tx *gorm.DB, id string, version uint) error
 }
 
 func NewUserRepository(txr *txRepository) *userRepository {
@@ -42,7 +79,7 @@ func (ur *userRepository) CreateNewUser(ctx context.Context, tx *gorm.DB, user e
 		tx = ur.txr.DB()
 	}
 
-	if err := tx.WithContext(ctx).Debug().Create(&user).Error; err != nil {
+	if err := tx.WithContext(ctx).Create(&user).Error; err != nil {
 		return entity.User{}, err
 	}
 	return user, nil
@@ -56,7 +93,28 @@ func (ur *userRepository) GetUserByPrimaryKey(ctx context.Context,
 		tx = ur.txr.DB()
 	}
 
-	err := tx.WithContext(ctx).Debug().Where(key+" = $1", val).Take(&user).Error
+	err := tx.WithContext(ctx).Where(key+" = $1", val).Take(&user).Error
+	if err != nil && !(errors.Is(err, gorm.ErrRecordNotFound)) {
+		return user, err
+	}
+	return user, nil
+}
+
+func (ur *userRepository) GetUserByID(ctx context.Context,
+	tx *gorm.DB, id string, req base.GetRequest) (entity.User, error) {
+	var user entity.User
+
+	if tx == nil {
+		tx = ur.txr.DB()
+	}
+
+	stmt, err := applySparse(tx.WithContext(ctx), util.ParseQueryList(req.Fields),
+		util.ParseQueryList(req.Include), userSelectableFields, userIncludableRelations)
+	if err != nil {
+		return user, err
+	}
+
+	err = stmt.Where(constant.DBAttrID+" = ?", id).Take(&user).Error
 	if err != nil && !(errors.Is(err, gorm.ErrRecordNotFound)) {
 		return user, err
 	}
@@ -65,7 +123,6 @@ func (ur *userRepository) GetUserByPrimaryKey(ctx context.Context,
 
 func (ur *userRepository) GetAllUsers(ctx context.Context, tx *gorm.DB,
 	req base.GetsRequest) ([]entity.User, int64, int64, error) {
-	var err error
 	var users []entity.User
 	var total int64
 
@@ -73,7 +130,12 @@ func (ur *userRepository) GetAllUsers(ctx context.Context, tx *gorm.DB,
 		tx = ur.txr.DB()
 	}
 
-	stmt := tx.WithContext(ctx).Debug()
+	stmt, err := applySparse(tx.WithContext(ctx), util.ParseQueryList(req.Fields),
+		util.ParseQueryList(req.Include), userSelectableFields, userIncludableRelations)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
 	if req.Search != "" {
 		searchQuery := "%" + req.Search + "%"
 		err = tx.WithContext(ctx).Model(&entity.User{}).
@@ -116,34 +178,116 @@ func (ur *userRepository) UpdateNameUser(ctx context.Context,
 	userUpdate := user
 	userUpdate.Name = name
 
+	return ur.updateVersioned(ctx, tx, userUpdate, "name")
+}
+
+// UpdateUser updates the non-zero fields of user, which version must be the
+// one the update is based on so that concurrent updates are detected.
+func (ur *userRepository) UpdateUser(ctx context.Context, tx *gorm.DB, user entity.User) (entity.User, error) {
+	return ur.updateVersioned(ctx, tx, user)
+}
+
+// ReplaceUser overwrites every writable field of user including the zero and
+// nil ones, the password is only replaced when a new one is given.
+func (ur *userRepository) ReplaceUser(ctx context.Context, tx *gorm.DB, user entity.User) (entity.User, error) {
+	columns := []string{"name", constant.DBAttrEmail, "role", "picture"}
+	if user.Password != "" {
+		columns = append(columns, "password")
+	}
+
+	return ur.updateVersioned(ctx, tx, user, columns...)
+}
+
+// UpdateUserStatus overwrites the status of user along with its reason and
+// expiry, clearing them when they are nil.
+func (ur *userRepository) UpdateUserStatus(ctx context.Context, tx *gorm.DB, user entity.User) (entity.User, error) {
+	return ur.updateVersioned(ctx, tx, user, "status", "status_reason", "status_expires_at")
+}
+
+// UpdateUserLastLogin stores the login time, which also counts as activity.
+// Activity timestamps are not part of the user's version nor its update time.
+func (ur *userRepository) UpdateUserLastLogin(ctx context.Context, tx *gorm.DB, id string, at time.Time) error {
 	if tx == nil {
 		tx = ur.txr.DB()
 	}
 
-	if err := tx.WithContext(ctx).Debug().Save(&userUpdate).Error; err != nil {
-		return userUpdate, err
+	return tx.WithContext(ctx).Model(&entity.User{}).
+		Where(constant.DBAttrID+" = ?", id).
+		UpdateColumns(map[string]any{"last_login_at": at, "last_seen_at": at}).Error
+}
+
+// UpdateUserLastSeen stores the activity time unless it was already stored
+// within the throttle window, so that concurrent requests write it only once.
+func (ur *userRepository) UpdateUserLastSeen(ctx context.Context, tx *gorm.DB, id string, at time.Time) error {
+	if tx == nil {
+		tx = ur.txr.DB()
 	}
-	return userUpdate, nil
+
+	return tx.WithContext(ctx).Model(&entity.User{}).
+		Where(constant.DBAttrID+" = ?", id).
+		Where("last_seen_at IS NULL OR last_seen_at < ?", at.Add(-constant.LastSeenThrottle)).
+		UpdateColumn("last_seen_at", at).Error
 }
 
-func (ur *userRepository) UpdateUser(ctx context.Context, tx *gorm.DB, user entity.User) (entity.User, error) {
+// UpdateUserStorageQuota overrides the storage quota of the user, a nil quota
+// falls back to the quota of the user's role. The quota is an administrative
+// setting which is not part of the user's version.
+func (ur *userRepository) UpdateUserStorageQuota(ctx context.Context, tx *gorm.DB, id string, quota *int64) error {
 	if tx == nil {
 		tx = ur.txr.DB()
 	}
 
-	if err := tx.WithContext(ctx).Debug().Updates(&user).Error; err != nil {
-		return entity.User{}, err
+	return tx.WithContext(ctx).Model(&entity.User{}).
+		Where(constant.DBAttrID+" = ?", id).
+		UpdateColumn("storage_quota", quota).Error
+}
+
+// updateVersioned updates the given columns of user (or its non-zero fields
+// when no column is given) as long as the row is still on user's version.
+func (ur *userRepository) updateVersioned(ctx context.Context,
+	tx *gorm.DB, user entity.User, columns ...string) (entity.User, error) {
+	if tx == nil {
+		tx = ur.txr.DB()
+	}
+
+	expected := user.Version
+	user.Version = expected + 1
+
+	stmt := tx.WithContext(ctx).Model(&user).Where(constant.DBAttrVersion+" = ?", expected)
+	if len(columns) > 0 {
+		stmt = stmt.Select(append(columns, constant.DBAttrVersion, "updated_at"))
+	}
+
+	res := stmt.Updates(&user)
+	if res.Error != nil {
+		return entity.User{}, res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		return entity.User{}, errs.ErrVersionConflict
 	}
 	return user, nil
 }
 
-func (ur *userRepository) DeleteUserByID(ctx context.Context, tx *gorm.DB, id string) error {
+// DeleteUserByID deletes the user only if it is still on the given version,
+// a version of 0 deletes the user regardless of its version.
+func (ur *userRepository) DeleteUserByID(ctx context.Context, tx *gorm.DB, id string, version uint) error {
 	if tx == nil {
 		tx = ur.txr.DB()
 	}
 
-	if err := tx.WithContext(ctx).Debug().Delete(&entity.User{}, &id).Error; err != nil {
-		return err
+	stmt := tx.WithContext(ctx).Where(constant.DBAttrID+" = ?", id)
+	if version != 0 {
+		stmt = stmt.Where(constant.DBAttrVersion+" = ?", version)
+	}
+
+	res := stmt.Delete(&entity.User{})
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		return errs.ErrVersionConflict
 	}
 	return nil
 }
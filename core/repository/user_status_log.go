@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/zetsux/gin-gorm-clean-starter/core/entity"
+
+	"gorm.io/gorm"
+)
+
+type userStatusLogRepository struct {
+	txr *txRepository
+}
+
+type UserStatusLogRepository interface {
+	// tx
+	TxRepository() *txRepository
+
+	// functional
+	CreateUserStatusLog(ctx context.Context, tx *gorm.DB, log entity.UserStatusLog) (entity.UserStatusLog, error)
+	GetUserStatusLogs(ctx context.Context, tx *gorm.DB, userID string) ([]entity.UserStatusLog, error)
+}
+
+func NewUserStatusLogRepository(txr *txRepository) *userStatusLogRepository {
+	return &userStatusLogRepository{txr: txr}
+}
+
+func (uslr *userStatusLogRepository) TxRepository() *txRepository {
+	return uslr.txr
+}
+
+func (uslr *userStatusLogRepository) CreateUserStatusLog(ctx context.Context,
+	tx *gorm.DB, log entity.UserStatusLog) (entity.UserStatusLog, error) {
+	if tx == nil {
+		tx = uslr.txr.DB()
+	}
+
+	if err := tx.WithContext(ctx).Create(&log).Error; err != nil {
+		return entity.UserStatusLog{}, err
+	}
+	return log, nil
+}
+
+func (uslr *userStatusLogRepository) GetUserStatusLogs(ctx context.Context,
+	tx *gorm.DB, userID string) ([]entity.UserStatusLog, error) {
+	var logs []entity.UserStatusLog
+
+	if tx == nil {
+		tx = uslr.txr.DB()
+	}
+
+	err := tx.WithContext(ctx).Where("user_id = ?", userID).
+		Order("created_at DESC").Find(&logs).Error
+	if err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
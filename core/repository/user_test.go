@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/zetsux/gin-gorm-clean-starter/common/base"
+	"github.com/zetsux/gin-gorm-clean-starter/core/entity"
+	errs "github.com/zetsux/gin-gorm-clean-starter/core/helper/errors"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newDryRunDB returns a database which only builds its statements, every
+// update of which is appended to statements. No row is ever affected.
+func newDryRunDB(t *testing.T, statements *[]string) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.Open("host=localhost"), &gorm.Config{
+		DryRun:                 true,
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+		Logger:                 logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("opening the database: %v", err)
+	}
+
+	err = db.Callback().Update().After("gorm:update").Register("test:statements", func(db *gorm.DB) {
+		*statements = append(*statements, db.Dialector.Explain(db.Statement.SQL.String(), db.Statement.Vars...))
+	})
+	if err != nil {
+		t.Fatalf("registering the callback: %v", err)
+	}
+	return db
+}
+
+func TestUpdateUserChecksVersionAtomically(t *testing.T) {
+	var statements []string
+	ur := NewUserRepository(NewTxRepository(newDryRunDB(t, &statements)))
+
+	user := entity.User{ID: uuid.New(), Name: "updated", Model: base.Model{Version: 3}}
+	_, err := ur.UpdateUser(context.Background(), nil, user)
+
+	// nothing is affected by a dry run, just like when a concurrent update
+	// moved the row past the version first
+	if !errors.Is(err, errs.ErrVersionConflict) {
+		t.Errorf("UpdateUser returned %v when no row was updated, want %v", err, errs.ErrVersionConflict)
+	}
+
+	if len(statements) != 1 {
+		t.Fatalf("UpdateUser ran %d updates, want 1: %v", len(statements), statements)
+	}
+	for _, want := range []string{`"version"=4`, "WHERE version = 3"} {
+		if !strings.Contains(statements[0], want) {
+			t.Errorf("the update %s lacks %s", statements[0], want)
+		}
+	}
+}
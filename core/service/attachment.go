@@ -0,0 +1,377 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/zetsux/gin-gorm-clean-starter/common/constant"
+	"github.com/zetsux/gin-gorm-clean-starter/core/entity"
+	"github.com/zetsux/gin-gorm-clean-starter/core/helper/dto"
+	errs "github.com/zetsux/gin-gorm-clean-starter/core/helper/errors"
+	"github.com/zetsux/gin-gorm-clean-starter/core/repository"
+)
+
+// AttachmentAccess is what an actor may do with the attachments of an owner,
+// each access allowing what the ones below it do.
+type AttachmentAccess int
+
+const (
+	// AttachmentAccessNone hides the owner altogether
+	AttachmentAccessNone AttachmentAccess = iota
+	// AttachmentAccessView shows the public attachments of the owner
+	AttachmentAccessView
+	// AttachmentAccessContribute allows adding attachments, and editing the
+	// ones added by the actor
+	AttachmentAccessContribute
+	// AttachmentAccessManage allows anything on every attachment of the owner
+	AttachmentAccessManage
+)
+
+// AttachmentPolicy decides the access of actor to the attachments of ownerID,
+// which is none when there is no such owner.
+type AttachmentPolicy func(ctx context.Context, actor dto.AttachmentActor, ownerID string) (AttachmentAccess, error)
+
+type AttachmentService interface {
+	GetAttachments(ctx context.Context,
+		owner dto.AttachmentOwner, actor dto.AttachmentActor) ([]dto.AttachmentResponse, error)
+	GetAttachment(ctx context.Context,
+		owner dto.AttachmentOwner, id string, actor dto.AttachmentActor) (dto.AttachmentResponse, error)
+	// CreateAttachment stores the content as a file of the actor, which is
+	// appended to the attachments of the owner
+	CreateAttachment(ctx context.Context, req dto.AttachmentCreateRequest,
+		owner dto.AttachmentOwner, actor dto.AttachmentActor) (dto.AttachmentResponse, error)
+	UpdateAttachment(ctx context.Context, req dto.AttachmentUpdateRequest,
+		owner dto.AttachmentOwner, id string, actor dto.AttachmentActor) (dto.AttachmentResponse, error)
+	// ReorderAttachments moves the attachments of the owner into the order of
+	// the given IDs, which must list all of them
+	ReorderAttachments(ctx context.Context, req dto.AttachmentReorderRequest,
+		owner dto.AttachmentOwner, actor dto.AttachmentActor) ([]dto.AttachmentResponse, error)
+	DeleteAttachment(ctx context.Context, owner dto.AttachmentOwner, id string, actor dto.AttachmentActor) error
+}
+
+type attachmentService struct {
+	attachmentRepository   repository.AttachmentRepository
+	fileDeletionRepository repository.FileDeletionRepository
+	fileService            FileService
+	uploadService          UploadService
+	fileDeletionService    FileDeletionService
+	policies               map[string]AttachmentPolicy
+}
+
+// NewAttachmentService serves the attachments of the owner types which have a
+// policy, keyed by the type.
+func NewAttachmentService(attachmentR repository.AttachmentRepository,
+	fileDeletionR repository.FileDeletionRepository, fileS FileService, uploadS UploadService,
+	fileDeletionS FileDeletionService, policies map[string]AttachmentPolicy) AttachmentService {
+	return &attachmentService{
+		attachmentRepository:   attachmentR,
+		fileDeletionRepository: fileDeletionR,
+		fileService:            fileS,
+		uploadService:          uploadS,
+		fileDeletionService:    fileDeletionS,
+		policies:               policies,
+	}
+}
+
+func (as *attachmentService) toAttachmentResponse(attachment entity.Attachment) dto.AttachmentResponse {
+	filePath := attachmentPath(attachment)
+	attachmentResp := dto.AttachmentResponse{
+		ID:          attachment.ID.String(),
+		OwnerType:   attachment.OwnerType,
+		OwnerID:     attachment.OwnerID,
+		UploaderID:  attachment.UploaderID.String(),
+		Position:    attachment.Position,
+		Caption:     attachment.Caption,
+		Visibility:  attachment.Visibility,
+		URL:         as.fileService.FileURL(filePath),
+		Name:        attachment.File.OriginalName,
+		ContentType: attachment.File.ContentType,
+		Size:        attachment.File.Size,
+		Variants:    map[string]string{},
+		CreatedAt:   attachment.CreatedAt,
+		UpdatedAt:   attachment.UpdatedAt,
+	}
+	for _, variant := range attachment.File.Variants {
+		attachmentResp.Variants[variant.Name] = as.fileService.VariantURL(filePath, variant.Name)
+	}
+	return attachmentResp
+}
+
+func attachmentPath(attachment entity.Attachment) string {
+	return constant.AttachmentDir + "/" + attachment.FileID.String()
+}
+
+// authorize returns the access of the actor to the attachments of the owner,
+// failing when it is below the required one.
+func (as *attachmentService) authorize(ctx context.Context, owner dto.AttachmentOwner,
+	actor dto.AttachmentActor, required AttachmentAccess) (AttachmentAccess, error) {
+	policy, ok := as.policies[owner.Type]
+	if !ok {
+		return AttachmentAccessNone, errs.ErrAttachmentOwnerUnknown
+	}
+
+	access, err := policy(ctx, actor, owner.ID)
+	if err != nil {
+		return AttachmentAccessNone, err
+	}
+
+	if access == AttachmentAccessNone {
+		return access, errs.ErrAttachmentOwnerNotFound
+	} else if access < required {
+		return access, errs.ErrAttachmentForbidden
+	}
+	return access, nil
+}
+
+// canView tells whether the attachment is visible with the given access,
+// private attachments being only visible to their uploader and the managers.
+func canView(attachment entity.Attachment, actor dto.AttachmentActor, access AttachmentAccess) bool {
+	return attachment.Visibility != constant.EnumAttachmentVisibilityPrivate || canEdit(attachment, actor, access)
+}
+
+// canEdit tells whether the attachment can be changed with the given access,
+// contributors only being able to change the ones they added.
+func canEdit(attachment entity.Attachment, actor dto.AttachmentActor, access AttachmentAccess) bool {
+	return access >= AttachmentAccessManage ||
+		(access >= AttachmentAccessContribute && attachment.UploaderID.String() == actor.ID)
+}
+
+func (as *attachmentService) GetAttachments(ctx context.Context,
+	owner dto.AttachmentOwner, actor dto.AttachmentActor) ([]dto.AttachmentResponse, error) {
+	access, err := as.authorize(ctx, owner, actor, AttachmentAccessView)
+	if err != nil {
+		return nil, err
+	}
+
+	attachments, err := as.attachmentRepository.GetAttachmentsByOwner(ctx, nil, owner.Type, owner.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	attachmentsResp := []dto.AttachmentResponse{}
+	for _, attachment := range attachments {
+		if canView(attachment, actor, access) {
+			attachmentsResp = append(attachmentsResp, as.toAttachmentResponse(attachment))
+		}
+	}
+	return attachmentsResp, nil
+}
+
+func (as *attachmentService) GetAttachment(ctx context.Context,
+	owner dto.AttachmentOwner, id string, actor dto.AttachmentActor) (dto.AttachmentResponse, error) {
+	access, err := as.authorize(ctx, owner, actor, AttachmentAccessView)
+	if err != nil {
+		return dto.AttachmentResponse{}, err
+	}
+
+	attachment, err := as.getAttachment(ctx, owner, id)
+	if err != nil {
+		return dto.AttachmentResponse{}, err
+	}
+
+	// private attachments are hidden rather than refused
+	if !canView(attachment, actor, access) {
+		return dto.AttachmentResponse{}, errs.ErrAttachmentNotFound
+	}
+	return as.toAttachmentResponse(attachment), nil
+}
+
+func (as *attachmentService) getAttachment(ctx context.Context,
+	owner dto.AttachmentOwner, id string) (entity.Attachment, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return entity.Attachment{}, errs.ErrAttachmentNotFound
+	}
+
+	attachment, err := as.attachmentRepository.GetAttachmentByID(ctx, nil, owner.Type, owner.ID, id)
+	if err != nil {
+		return entity.Attachment{}, err
+	}
+
+	if reflect.DeepEqual(attachment, entity.Attachment{}) {
+		return entity.Attachment{}, errs.ErrAttachmentNotFound
+	}
+	return attachment, nil
+}
+
+func (as *attachmentService) CreateAttachment(ctx context.Context, req dto.AttachmentCreateRequest,
+	owner dto.AttachmentOwner, actor dto.AttachmentActor) (dto.AttachmentResponse, error) {
+	if _, err := as.authorize(ctx, owner, actor, AttachmentAccessContribute); err != nil {
+		return dto.AttachmentResponse{}, err
+	}
+
+	uploaderID, err := uuid.Parse(actor.ID)
+	if err != nil {
+		return dto.AttachmentResponse{}, errs.ErrAttachmentForbidden
+	}
+
+	var file dto.FileResponse
+	if req.UploadID != "" {
+		file, err = as.uploadService.Attach(ctx, req.UploadID, actor.ID,
+			func(upload dto.FileUploadRequest) (dto.FileResponse, error) {
+				upload.Dir = constant.AttachmentDir
+				return as.fileService.Upload(ctx, upload)
+			})
+	} else {
+		file, err = as.fileService.Upload(ctx, dto.FileUploadRequest{
+			Dir:         constant.AttachmentDir,
+			OwnerID:     actor.ID,
+			Name:        req.Filename,
+			ContentType: req.ContentType,
+			Size:        -1,
+			Content:     req.Content,
+		})
+	}
+	if err != nil {
+		return dto.AttachmentResponse{}, err
+	}
+
+	attachment := entity.Attachment{
+		OwnerType:  owner.Type,
+		OwnerID:    owner.ID,
+		FileID:     uuid.MustParse(file.ID),
+		UploaderID: uploaderID,
+		Caption:    req.Caption,
+		Visibility: req.Visibility,
+	}
+	if attachment.Visibility == "" {
+		attachment.Visibility = constant.EnumAttachmentVisibilityPublic
+	}
+
+	// the owner stays locked from picking the position until the attachment
+	// is created, so that concurrent attachments never share a position
+	txr := as.attachmentRepository.TxRepository()
+	tx, err := txr.BeginTx(ctx)
+	if err != nil {
+		as.fileDeletionService.Release(ctx, file.Path)
+		return dto.AttachmentResponse{}, err
+	}
+
+	err = as.attachmentRepository.LockAttachmentOwner(ctx, tx, owner.Type, owner.ID)
+	if err == nil {
+		attachment.Position, err = as.attachmentRepository.GetNextAttachmentPosition(ctx, tx, owner.Type, owner.ID)
+	}
+	if err == nil {
+		attachment, err = as.attachmentRepository.CreateAttachment(ctx, tx, attachment)
+	}
+
+	if err := txr.CommitOrRollbackTx(ctx, tx, err); err != nil {
+		as.fileDeletionService.Release(ctx, file.Path)
+		return dto.AttachmentResponse{}, err
+	}
+
+	attachment, err = as.getAttachment(ctx, owner, attachment.ID.String())
+	if err != nil {
+		return dto.AttachmentResponse{}, err
+	}
+	return as.toAttachmentResponse(attachment), nil
+}
+
+func (as *attachmentService) UpdateAttachment(ctx context.Context, req dto.AttachmentUpdateRequest,
+	owner dto.AttachmentOwner, id string, actor dto.AttachmentActor) (dto.AttachmentResponse, error) {
+	attachment, err := as.getEditableAttachment(ctx, owner, id, actor)
+	if err != nil {
+		return dto.AttachmentResponse{}, err
+	}
+
+	if req.Caption != nil {
+		attachment.Caption = *req.Caption
+	}
+	if req.Visibility != nil {
+		attachment.Visibility = *req.Visibility
+	}
+
+	if err := as.attachmentRepository.UpdateAttachment(ctx, nil, attachment); err != nil {
+		return dto.AttachmentResponse{}, err
+	}
+	return as.toAttachmentResponse(attachment), nil
+}
+
+// getEditableAttachment finds an attachment the actor can change, those the
+// actor cannot see being reported as not found.
+func (as *attachmentService) getEditableAttachment(ctx context.Context,
+	owner dto.AttachmentOwner, id string, actor dto.AttachmentActor) (entity.Attachment, error) {
+	access, err := as.authorize(ctx, owner, actor, AttachmentAccessView)
+	if err != nil {
+		return entity.Attachment{}, err
+	}
+
+	attachment, err := as.getAttachment(ctx, owner, id)
+	if err != nil {
+		return entity.Attachment{}, err
+	}
+
+	if !canView(attachment, actor, access) {
+		return entity.Attachment{}, errs.ErrAttachmentNotFound
+	} else if !canEdit(attachment, actor, access) {
+		return entity.Attachment{}, errs.ErrAttachmentForbidden
+	}
+	return attachment, nil
+}
+
+func (as *attachmentService) ReorderAttachments(ctx context.Context, req dto.AttachmentReorderRequest,
+	owner dto.AttachmentOwner, actor dto.AttachmentActor) ([]dto.AttachmentResponse, error) {
+	if _, err := as.authorize(ctx, owner, actor, AttachmentAccessManage); err != nil {
+		return nil, err
+	}
+
+	txr := as.attachmentRepository.TxRepository()
+	tx, err := txr.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var attachments []entity.Attachment
+	err = as.attachmentRepository.LockAttachmentOwner(ctx, tx, owner.Type, owner.ID)
+	if err == nil {
+		attachments, err = as.attachmentRepository.GetAttachmentsByOwner(ctx, tx, owner.Type, owner.ID)
+	}
+	if err == nil && len(attachments) != len(req.IDs) {
+		err = errs.ErrAttachmentOrderMismatch
+	}
+
+	for i := 0; err == nil && i < len(attachments); i++ {
+		position := slices.Index(req.IDs, attachments[i].ID.String())
+		if position < 0 {
+			err = errs.ErrAttachmentOrderMismatch
+		} else if position != attachments[i].Position {
+			err = as.attachmentRepository.UpdateAttachmentPosition(ctx, tx, attachments[i].ID.String(), position)
+		}
+	}
+
+	if err := txr.CommitOrRollbackTx(ctx, tx, err); err != nil {
+		return nil, err
+	}
+	return as.GetAttachments(ctx, owner, actor)
+}
+
+func (as *attachmentService) DeleteAttachment(ctx context.Context,
+	owner dto.AttachmentOwner, id string, actor dto.AttachmentActor) error {
+	attachment, err := as.getEditableAttachment(ctx, owner, id, actor)
+	if err != nil {
+		return err
+	}
+
+	// the file is queued for deletion along with the attachment, so that it is
+	// only deleted once nothing points at it
+	txr := as.attachmentRepository.TxRepository()
+	tx, err := txr.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = as.attachmentRepository.DeleteAttachmentByID(ctx, tx, id)
+	var deletion entity.FileDeletion
+	if err == nil {
+		deletion, err = as.fileDeletionRepository.CreateFileDeletion(ctx, tx,
+			newFileDeletion(attachmentPath(attachment)))
+	}
+
+	if err := txr.CommitOrRollbackTx(ctx, tx, err); err != nil {
+		return err
+	}
+
+	processFileDeletion(ctx, as.fileDeletionService, deletion)
+	return nil
+}
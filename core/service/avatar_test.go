@@ -0,0 +1,29 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/zetsux/gin-gorm-clean-starter/core/helper/dto"
+)
+
+func TestGetAvatarIsTheSameForEveryFormOfTheID(t *testing.T) {
+	as := NewAvatarService()
+	id := uuid.New().String()
+
+	want, err := as.GetAvatar(id, dto.AvatarGetRequest{})
+	if err != nil {
+		t.Fatalf("GetAvatar: %v", err)
+	}
+
+	for _, form := range []string{strings.ToUpper(id), "{" + id + "}", "urn:uuid:" + id} {
+		got, err := as.GetAvatar(form, dto.AvatarGetRequest{})
+		if err != nil {
+			t.Fatalf("GetAvatar(%q): %v", form, err)
+		}
+		if got.ETag != want.ETag {
+			t.Errorf("GetAvatar(%q) rendered another avatar than for %q", form, id)
+		}
+	}
+}
@@ -0,0 +1,872 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/url"
+	"path"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zetsux/gin-gorm-clean-starter/common/base"
+	"github.com/zetsux/gin-gorm-clean-starter/common/constant"
+	"github.com/zetsux/gin-gorm-clean-starter/common/imaging"
+	"github.com/zetsux/gin-gorm-clean-starter/common/metrics"
+	"github.com/zetsux/gin-gorm-clean-starter/common/scanner"
+	"github.com/zetsux/gin-gorm-clean-starter/common/storage"
+	"github.com/zetsux/gin-gorm-clean-starter/core/entity"
+	"github.com/zetsux/gin-gorm-clean-starter/core/helper/dto"
+	errs "github.com/zetsux/gin-gorm-clean-starter/core/helper/errors"
+	"github.com/zetsux/gin-gorm-clean-starter/core/repository"
+)
+
+var (
+	// servedDirs lists the directories which files are served from, along with
+	// the public directories
+	servedDirs = []string{constant.PictureDir, constant.AttachmentDir}
+
+	// transformSizes lists the widths and heights images can be resized to
+	transformSizes   = []int{16, 32, 48, 64, 96, 128, 256, 512, 1024, 2048}
+	transformFits    = []string{imaging.FitCover, imaging.FitContain, imaging.FitFill}
+	transformFormats = []string{imaging.FormatJPEG, imaging.FormatPNG, imaging.FormatWebP}
+)
+
+type FileService interface {
+	// SignURL mints a URL to the file under path which stays valid for ttl,
+	// covering the disposition and variant of req when they are set
+	SignURL(path string, ttl time.Duration, req dto.FileGetRequest) (string, error)
+	// FileURL returns a plain URL for public files and a signed one otherwise
+	FileURL(path string) string
+	// VariantURL is FileURL for a variant of the file, which falls back to the
+	// file itself when it has no such variant
+	VariantURL(path string, variant string) string
+	IsPublic(path string) bool
+
+	GetFile(ctx context.Context, path string, req dto.FileGetRequest) (io.ReadCloser, storage.ObjectInfo, error)
+	// Upload stores the content along with its metadata, deduplicating content
+	// that is already stored
+	Upload(ctx context.Context, req dto.FileUploadRequest) (dto.FileResponse, error)
+	// Release deletes the file under path, and its content once unreferenced
+	Release(ctx context.Context, path string) error
+	// Delete is Release refusing files which are still referenced, e.g. by a
+	// user picture or an attachment
+	Delete(ctx context.Context, path string) error
+
+	GetAllFiles(ctx context.Context, req base.GetsRequest) ([]dto.FileResponse, base.PaginationResponse, error)
+	GetFileMetadata(ctx context.Context, path string) (dto.FileResponse, error)
+}
+
+type fileService struct {
+	fileRepository      repository.FileRepository
+	storageQuotaService StorageQuotaService
+	storage             storage.Storage
+	scanner             scanner.Scanner
+	signingKey          []byte
+	publicDirs          []string
+}
+
+// NewFileService signs the URLs of the files with signingKey, except for
+// those in the publicDirs.
+func NewFileService(fileR repository.FileRepository, storageQuotaS StorageQuotaService,
+	store storage.Storage, scan scanner.Scanner, signingKey string, publicDirs []string) FileService {
+	return &fileService{
+		fileRepository:      fileR,
+		storageQuotaService: storageQuotaS,
+		storage:             store,
+		scanner:             scan,
+		signingKey:          []byte(signingKey),
+		publicDirs:          publicDirs,
+	}
+}
+
+func (fs *fileService) toFileResponse(file entity.File) dto.FileResponse {
+	filePath := file.Dir + "/" + file.ID.String()
+	fileResp := dto.FileResponse{
+		ID:            file.ID.String(),
+		Path:          filePath,
+		URL:           fs.FileURL(filePath),
+		Dir:           file.Dir,
+		OriginalName:  file.OriginalName,
+		ContentType:   file.ContentType,
+		Size:          file.Size,
+		SHA256:        file.SHA256,
+		StorageKey:    file.StorageKey,
+		Variants:      map[string]string{},
+		ScanStatus:    file.ScanStatus,
+		ScanSignature: file.ScanSignature,
+		ScannedAt:     file.ScannedAt,
+		CreatedAt:     file.CreatedAt,
+	}
+	for _, variant := range file.Variants {
+		fileResp.Variants[variant.Name] = fs.VariantURL(filePath, variant.Name)
+	}
+	if file.OwnerID != nil {
+		fileResp.OwnerID = file.OwnerID.String()
+	}
+	return fileResp
+}
+
+func (fs *fileService) SignURL(filePath string, ttl time.Duration, req dto.FileGetRequest) (string, error) {
+	filePath, err := storage.CleanKey(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	if err := validateDisposition(req.Disposition); err != nil {
+		return "", err
+	}
+
+	req.Expires = time.Now().Add(ttl).Unix()
+	query := fileQuery(req)
+	query.Set("signature", fs.sign(filePath, req))
+
+	return constant.FileRoutePrefix + "/" + filePath + "?" + query.Encode(), nil
+}
+
+func (fs *fileService) FileURL(filePath string) string {
+	return fs.fileURL(filePath, dto.FileGetRequest{})
+}
+
+func (fs *fileService) VariantURL(filePath string, variant string) string {
+	return fs.fileURL(filePath, dto.FileGetRequest{Variant: variant})
+}
+
+func (fs *fileService) fileURL(filePath string, req dto.FileGetRequest) string {
+	if fs.IsPublic(filePath) {
+		fileURL := constant.FileRoutePrefix + "/" + filePath
+		if query := fileQuery(req); len(query) > 0 {
+			fileURL += "?" + query.Encode()
+		}
+		return fileURL
+	}
+
+	signed, err := fs.SignURL(filePath, constant.FileURLExpiry, req)
+	if err != nil {
+		return ""
+	}
+	return signed
+}
+
+func (fs *fileService) IsPublic(filePath string) bool {
+	dir, _, _ := strings.Cut(filePath, "/")
+	return slices.Contains(fs.publicDirs, dir)
+}
+
+func (fs *fileService) GetFile(ctx context.Context,
+	filePath string, req dto.FileGetRequest) (io.ReadCloser, storage.ObjectInfo, error) {
+	if err := fs.validateServedPath(filePath); err != nil {
+		return nil, storage.ObjectInfo{}, err
+	}
+
+	if !fs.IsPublic(filePath) {
+		if err := fs.verify(filePath, req); err != nil {
+			return nil, storage.ObjectInfo{}, err
+		}
+	}
+
+	if err := validateDisposition(req.Disposition); err != nil {
+		return nil, storage.ObjectInfo{}, err
+	}
+
+	if err := validateTransform(req); err != nil {
+		return nil, storage.ObjectInfo{}, err
+	}
+
+	file, err := fs.lookup(ctx, filePath)
+	if err != nil {
+		return nil, storage.ObjectInfo{}, err
+	}
+
+	if file.IsQuarantined() {
+		return nil, storage.ObjectInfo{}, errs.ErrFileQuarantined
+	}
+
+	// files stored before metadata was tracked are stored right under their path
+	storageKey, contentType, etag := filePath, "", ""
+	if !reflect.DeepEqual(file, entity.File{}) {
+		storageKey, contentType, etag = file.StorageKey, file.ContentType, strconv.Quote(file.SHA256)
+		for _, variant := range file.Variants {
+			if variant.Name == req.Variant {
+				storageKey, contentType, etag = variant.StorageKey, variant.ContentType, ""
+			}
+		}
+	}
+
+	if req.Width != 0 || req.Height != 0 || req.Format != "" {
+		content, info, err := fs.transform(ctx, storageKey, contentType, req)
+		if err != nil {
+			return nil, storage.ObjectInfo{}, err
+		}
+		info.ETag = objectETag(info)
+		return content, info, nil
+	}
+
+	content, info, err := fs.storage.Get(ctx, storageKey)
+	if err != nil {
+		return nil, storage.ObjectInfo{}, err
+	}
+
+	if contentType != "" {
+		info.ContentType = contentType
+	}
+	if etag != "" {
+		info.ETag = etag
+	} else {
+		info.ETag = objectETag(info)
+	}
+	return content, info, nil
+}
+
+// validateServedPath only allows paths made of a served directory and a plain
+// file name, files of any other directory are reported missing.
+func (fs *fileService) validateServedPath(filePath string) error {
+	cleaned, err := storage.CleanKey(filePath)
+	if err != nil || cleaned != filePath {
+		return errs.ErrInvalidFileKey
+	}
+
+	dir, name, _ := strings.Cut(filePath, "/")
+	if !isPlainFileName(name) {
+		return errs.ErrInvalidFileKey
+	}
+
+	if !slices.Contains(servedDirs, dir) && !slices.Contains(fs.publicDirs, dir) {
+		return errs.ErrFileNotFound
+	}
+	return nil
+}
+
+// transform serves the image under storageKey transformed following req, each
+// transformation is computed once then served from the cache.
+func (fs *fileService) transform(ctx context.Context,
+	storageKey string, contentType string, req dto.FileGetRequest) (io.ReadCloser, storage.ObjectInfo, error) {
+	// files stored before metadata was tracked have no content type, their
+	// content is sniffed instead
+	if contentType != "" && !imaging.IsSupported(contentType) {
+		return nil, storage.ObjectInfo{}, errs.ErrFileNotTransformable
+	}
+
+	cacheKey := transformCacheKey(storageKey, req)
+	content, info, err := fs.storage.Get(ctx, cacheKey)
+	if err == nil {
+		return content, info, nil
+	} else if !errors.Is(err, errs.ErrFileNotFound) {
+		return nil, storage.ObjectInfo{}, err
+	}
+
+	source, sourceInfo, err := fs.storage.Get(ctx, storageKey)
+	if err != nil {
+		return nil, storage.ObjectInfo{}, err
+	}
+	defer source.Close()
+
+	if sourceInfo.Size > constant.FileTransformMaxSize {
+		return nil, storage.ObjectInfo{}, errs.ErrFileNotTransformable
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(source, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, storage.ObjectInfo{}, err
+	}
+	if _, ok := imaging.Sniff(head[:n]); !ok {
+		return nil, storage.ObjectInfo{}, errs.ErrFileNotTransformable
+	}
+
+	rest, err := io.ReadAll(io.LimitReader(source, constant.FileTransformMaxSize+1-int64(n)))
+	if err != nil {
+		return nil, storage.ObjectInfo{}, err
+	}
+	data := append(head[:n], rest...)
+	if int64(len(data)) > constant.FileTransformMaxSize {
+		return nil, storage.ObjectInfo{}, errs.ErrFileNotTransformable
+	}
+
+	img, err := imaging.Decode(data, constant.PictureMaxSide, constant.PictureMaxPixels)
+	if errors.Is(err, errs.ErrImageUnsupported) {
+		return nil, storage.ObjectInfo{}, errs.ErrFileNotTransformable
+	} else if err != nil {
+		return nil, storage.ObjectInfo{}, err
+	}
+
+	format, fit := req.Format, req.Fit
+	if format == "" {
+		format = img.Format
+	}
+	if fit == "" {
+		fit = imaging.FitContain
+	}
+
+	var transformed bytes.Buffer
+	contentType, err = imaging.EncodeAs(&transformed, imaging.Resize(img, req.Width, req.Height, fit), format)
+	if err != nil {
+		return nil, storage.ObjectInfo{}, err
+	}
+
+	size := int64(transformed.Len())
+	info, err = fs.storage.Put(ctx, cacheKey, bytes.NewReader(transformed.Bytes()), size, contentType)
+	if err != nil {
+		// the transformation is still served, only caching it failed
+		slog.WarnContext(ctx, "Failed to cache transformed file", "error", err, "key", cacheKey)
+		info = storage.ObjectInfo{Key: cacheKey, Size: size, LastModified: time.Now()}
+	}
+
+	info.ContentType = contentType
+	return storage.NopCloser(bytes.NewReader(transformed.Bytes())), info, nil
+}
+
+func (fs *fileService) Upload(ctx context.Context, req dto.FileUploadRequest) (_ dto.FileResponse, err error) {
+	content, reservationID := req.Content, uuid.Nil
+	if req.OwnerID != "" {
+		content, reservationID, err = fs.reserve(ctx, req)
+		if err != nil {
+			return dto.FileResponse{}, err
+		}
+
+		// the reservation is over once the file counts against the quota by
+		// itself, a failed upload keeps the reservation it was given
+		defer func() {
+			if err == nil || req.ReservationID == "" {
+				fs.releaseStorage(ctx, reservationID)
+			}
+		}()
+	}
+
+	// the content is staged until it is scanned, so that nothing can serve it
+	// before it is known to be clean
+	fileID := uuid.New()
+	stagingKey := constant.FileStagingDir + "/" + fileID.String()
+	storageKey := req.Dir + "/" + fileID.String()
+
+	hasher := sha256.New()
+	info, err := fs.storage.Put(ctx, stagingKey, io.TeeReader(content, hasher), req.Size, req.ContentType)
+	if err != nil {
+		return dto.FileResponse{}, err
+	}
+
+	// content of an unknown size is only reserved for once its size is known
+	if req.OwnerID != "" && req.Size < 0 {
+		if err := fs.reserveStorage(ctx, req, reservationID, info.Size); err != nil {
+			fs.deleteStored(ctx, stagingKey)
+			return dto.FileResponse{}, err
+		}
+	}
+
+	file := entity.File{
+		ID:           fileID,
+		Dir:          req.Dir,
+		OriginalName: req.Name,
+		ContentType:  req.ContentType,
+		Size:         info.Size,
+		SHA256:       hex.EncodeToString(hasher.Sum(nil)),
+	}
+	if file.ContentType == "" {
+		file.ContentType = info.ContentType
+	}
+	if ownerID, err := uuid.Parse(req.OwnerID); err == nil {
+		file.OwnerID = &ownerID
+	}
+
+	if err := fs.scan(ctx, stagingKey, &file); err != nil {
+		fs.deleteStored(ctx, stagingKey)
+		return dto.FileResponse{}, err
+	}
+
+	if file.IsQuarantined() {
+		return dto.FileResponse{}, fs.quarantine(ctx, stagingKey, file)
+	}
+
+	if err := fs.moveStored(ctx, stagingKey, storageKey); err != nil {
+		fs.deleteStored(ctx, stagingKey)
+		return dto.FileResponse{}, err
+	}
+
+	blob, err := fs.fileRepository.AcquireBlob(ctx, nil, entity.FileBlob{
+		SHA256:     file.SHA256,
+		StorageKey: storageKey,
+		Size:       info.Size,
+	})
+	if err != nil {
+		fs.deleteStored(ctx, storageKey)
+		return dto.FileResponse{}, err
+	}
+
+	// the same content is already stored, so the new copy is not needed
+	if blob.StorageKey != storageKey {
+		fs.deleteStored(ctx, storageKey)
+	}
+
+	file.StorageKey = blob.StorageKey
+	file, err = fs.fileRepository.CreateFile(ctx, nil, file)
+	if err != nil {
+		if releaseErr := fs.releaseBlob(ctx, blob.SHA256); releaseErr != nil {
+			slog.ErrorContext(ctx, "Failed to release file blob", "error", releaseErr, "sha256", blob.SHA256)
+		}
+		return dto.FileResponse{}, err
+	}
+
+	for _, variantReq := range req.Variants {
+		variant, err := fs.storeVariant(ctx, file, variantReq)
+		if err != nil {
+			if releaseErr := fs.release(ctx, file, false); releaseErr != nil {
+				slog.ErrorContext(ctx, "Failed to release file", "error", releaseErr, "file_id", file.ID)
+			}
+			return dto.FileResponse{}, err
+		}
+		file.Variants = append(file.Variants, variant)
+	}
+
+	metrics.FileUploads.WithLabelValues(req.Dir).Inc()
+	metrics.FileUploadedBytes.WithLabelValues(req.Dir).Add(float64(file.Size))
+	return fs.toFileResponse(file), nil
+}
+
+// reserve reserves the storage quota of the owner for the upload before
+// anything is stored, returning the content to store along with the ID of the
+// reservation, being the one of the request when it comes with one. Content of
+// an unknown size is cut off once it goes past the remaining quota, as it can
+// only be reserved for once stored.
+func (fs *fileService) reserve(ctx context.Context, req dto.FileUploadRequest) (io.Reader, uuid.UUID, error) {
+	reservationID, err := uuid.Parse(req.ReservationID)
+	if err != nil {
+		reservationID = uuid.New()
+	}
+
+	if req.Size >= 0 {
+		if err := fs.reserveStorage(ctx, req, reservationID, req.Size); err != nil {
+			return nil, uuid.Nil, err
+		}
+		return req.Content, reservationID, nil
+	}
+
+	remaining, err := fs.storageQuotaService.RemainingStorage(ctx, req.OwnerID)
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+
+	for _, variant := range req.Variants {
+		remaining -= variant.Size
+	}
+
+	if remaining < 0 {
+		return nil, uuid.Nil, errs.ErrStorageQuotaExceeded
+	}
+	return &quotaReader{r: req.Content, remaining: remaining}, reservationID, nil
+}
+
+// reserveStorage reserves size bytes for the content of the upload along with
+// its variants, atomically with respect to the other uploads of the owner.
+func (fs *fileService) reserveStorage(ctx context.Context,
+	req dto.FileUploadRequest, reservationID uuid.UUID, size int64) error {
+	for _, variant := range req.Variants {
+		size += variant.Size
+	}
+
+	return fs.storageQuotaService.ReserveStorage(ctx, req.OwnerID, reservationID, size,
+		time.Now().Add(constant.StorageReservationExpiry))
+}
+
+// releaseStorage releases a reservation, which otherwise lasts until it expires.
+func (fs *fileService) releaseStorage(ctx context.Context, reservationID uuid.UUID) {
+	if err := fs.storageQuotaService.ReleaseStorage(ctx, reservationID); err != nil {
+		slog.ErrorContext(ctx, "Failed to release reserved storage", "error", err, "reservation_id", reservationID)
+	}
+}
+
+func (fs *fileService) Release(ctx context.Context, filePath string) error {
+	return fs.releasePath(ctx, filePath, false)
+}
+
+func (fs *fileService) Delete(ctx context.Context, filePath string) error {
+	return fs.releasePath(ctx, filePath, true)
+}
+
+func (fs *fileService) releasePath(ctx context.Context, filePath string, checkReferences bool) error {
+	file, err := fs.lookup(ctx, filePath)
+	if err != nil {
+		return err
+	}
+
+	if reflect.DeepEqual(file, entity.File{}) {
+		fs.purgeCache(ctx, filePath)
+		return fs.storage.Delete(ctx, filePath)
+	}
+	return fs.release(ctx, file, checkReferences)
+}
+
+// scan runs the scanner over the content stored under storageKey, recording
+// the outcome in file. Content which cannot be scanned is marked as failed
+// rather than failing the upload, so that it ends up quarantined.
+func (fs *fileService) scan(ctx context.Context, storageKey string, file *entity.File) error {
+	content, _, err := fs.storage.Get(ctx, storageKey)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	result, err := fs.scanner.Scan(ctx, content)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to scan uploaded file", "error", err)
+		result.Status = constant.EnumScanStatusFailed
+	}
+
+	scannedAt := time.Now()
+	file.ScanStatus, file.ScanSignature, file.ScannedAt = result.Status, result.Signature, &scannedAt
+	return nil
+}
+
+// moveStored moves the content under from to the key to, the content being
+// left under from only when the move fails.
+func (fs *fileService) moveStored(ctx context.Context, from string, to string) error {
+	content, info, err := fs.storage.Get(ctx, from)
+	if err != nil {
+		return err
+	}
+
+	_, err = fs.storage.Put(ctx, to, content, info.Size, info.ContentType)
+	content.Close()
+	if err != nil {
+		fs.deleteStored(ctx, to)
+		return err
+	}
+
+	fs.deleteStored(ctx, from)
+	return nil
+}
+
+// quarantine moves the staged content under stagingKey which did not pass the
+// scan to the quarantine, keeping its metadata for admins to look into.
+func (fs *fileService) quarantine(ctx context.Context, stagingKey string, file entity.File) error {
+	defer fs.deleteStored(ctx, stagingKey)
+
+	file.StorageKey = constant.FileQuarantineDir + "/" + file.ID.String()
+	if err := fs.moveStored(ctx, stagingKey, file.StorageKey); err != nil {
+		return err
+	}
+
+	if _, err := fs.fileRepository.CreateFile(ctx, nil, file); err != nil {
+		fs.deleteStored(ctx, file.StorageKey)
+		return err
+	}
+
+	if file.ScanSignature != "" {
+		return fmt.Errorf("%w: %s", errs.ErrFileQuarantined, file.ScanSignature)
+	}
+	return errs.ErrFileQuarantined
+}
+
+// release deletes the metadata of file along with its reference on its blob
+// at once, then the stored content nothing points at anymore. With
+// checkReferences, files still referenced are refused.
+func (fs *fileService) release(ctx context.Context, file entity.File, checkReferences bool) error {
+	txr := fs.fileRepository.TxRepository()
+	tx, err := txr.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, ref := range fileReferences {
+		if !checkReferences || ref.Dir != file.Dir || err != nil {
+			continue
+		}
+
+		var referenced bool
+		referenced, err = fs.fileRepository.IsFileReferenced(ctx, tx, file, ref.Table, ref.Column, ref.ByID)
+		if err == nil && referenced {
+			err = errs.ErrFileReferenced
+		}
+	}
+
+	if err == nil {
+		err = fs.fileRepository.DeleteFileByID(ctx, tx, file.ID.String())
+	}
+
+	var blob entity.FileBlob
+	if err == nil && !file.IsQuarantined() {
+		blob, err = fs.fileRepository.ReleaseBlob(ctx, tx, file.SHA256)
+		// content stored before blobs were tracked is not shared
+		if errors.Is(err, errs.ErrFileNotFound) {
+			blob, err = entity.FileBlob{StorageKey: file.StorageKey}, nil
+		}
+	}
+
+	if err = txr.CommitOrRollbackTx(ctx, tx, err); err != nil {
+		return err
+	}
+
+	// quarantined content is not shared with any other file
+	if file.IsQuarantined() {
+		fs.deleteStored(ctx, file.StorageKey)
+		return nil
+	}
+
+	for _, variant := range file.Variants {
+		fs.purgeCache(ctx, variant.StorageKey)
+		fs.deleteStored(ctx, variant.StorageKey)
+	}
+
+	if blob.RefCount <= 0 {
+		fs.purgeCache(ctx, blob.StorageKey)
+		return fs.storage.Delete(ctx, blob.StorageKey)
+	}
+	return nil
+}
+
+// storeVariant stores a variant of file, variants are not deduplicated as they
+// are derived from content which already is.
+func (fs *fileService) storeVariant(ctx context.Context,
+	file entity.File, req dto.FileVariantUpload) (entity.FileVariant, error) {
+	storageKey := fmt.Sprintf("%s/%s/%s", constant.FileVariantDir, file.ID, req.Name)
+	info, err := fs.storage.Put(ctx, storageKey, req.Content, req.Size, req.ContentType)
+	if err != nil {
+		return entity.FileVariant{}, err
+	}
+
+	variant, err := fs.fileRepository.CreateFileVariant(ctx, nil, entity.FileVariant{
+		FileID:      file.ID,
+		Name:        req.Name,
+		ContentType: req.ContentType,
+		Size:        info.Size,
+		StorageKey:  storageKey,
+	})
+	if err != nil {
+		fs.deleteStored(ctx, storageKey)
+		return entity.FileVariant{}, err
+	}
+	return variant, nil
+}
+
+func (fs *fileService) GetAllFiles(ctx context.Context, req base.GetsRequest) (
+	filesResp []dto.FileResponse, pageResp base.PaginationResponse, err error) {
+	if req.PerPage < 0 {
+		req.PerPage = 0
+	}
+
+	if req.Page < 0 {
+		req.Page = 0
+	}
+
+	if req.Sort != "" && req.Sort[0] == '-' {
+		req.Sort = req.Sort[1:] + " DESC"
+	}
+
+	files, lastPage, total, err := fs.fileRepository.GetAllFiles(ctx, nil, req)
+	if err != nil {
+		return []dto.FileResponse{}, base.PaginationResponse{}, err
+	}
+
+	filesResp = []dto.FileResponse{}
+	for _, file := range files {
+		filesResp = append(filesResp, fs.toFileResponse(file))
+	}
+
+	if req.PerPage == 0 {
+		return filesResp, base.PaginationResponse{}, nil
+	}
+
+	pageResp = base.PaginationResponse{
+		Page:     int64(req.Page),
+		PerPage:  int64(req.PerPage),
+		LastPage: lastPage,
+		Total:    total,
+	}
+	return filesResp, pageResp, nil
+}
+
+func (fs *fileService) GetFileMetadata(ctx context.Context, filePath string) (dto.FileResponse, error) {
+	file, err := fs.lookup(ctx, filePath)
+	if err != nil {
+		return dto.FileResponse{}, err
+	}
+
+	if reflect.DeepEqual(file, entity.File{}) {
+		return dto.FileResponse{}, errs.ErrFileNotFound
+	}
+	return fs.toFileResponse(file), nil
+}
+
+// lookup finds the metadata of the file under path (<dir>/<file id>), an empty
+// file is returned for paths without any metadata.
+func (fs *fileService) lookup(ctx context.Context, filePath string) (entity.File, error) {
+	filePath, err := storage.CleanKey(filePath)
+	if err != nil {
+		return entity.File{}, err
+	}
+
+	dir, id, _ := strings.Cut(filePath, "/")
+	if _, err := uuid.Parse(id); err != nil {
+		return entity.File{}, nil
+	}
+
+	file, err := fs.fileRepository.GetFileByID(ctx, nil, id)
+	if err != nil {
+		return entity.File{}, err
+	}
+
+	if file.Dir != dir {
+		return entity.File{}, nil
+	}
+	return file, nil
+}
+
+// releaseBlob drops a reference from the blob, deleting its stored content
+// once the last reference is gone.
+func (fs *fileService) releaseBlob(ctx context.Context, sha256 string) error {
+	blob, err := fs.fileRepository.ReleaseBlob(ctx, nil, sha256)
+	if err != nil {
+		return err
+	}
+
+	if blob.RefCount <= 0 {
+		fs.purgeCache(ctx, blob.StorageKey)
+		return fs.storage.Delete(ctx, blob.StorageKey)
+	}
+	return nil
+}
+
+// purgeCache deletes the cached transformations of the content under storageKey.
+func (fs *fileService) purgeCache(ctx context.Context, storageKey string) {
+	cached, err := fs.storage.List(ctx, constant.FileCacheDir+"/"+storageKey+"/")
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list cached transformations", "error", err, "key", storageKey)
+		return
+	}
+
+	for _, info := range cached {
+		fs.deleteStored(ctx, info.Key)
+	}
+}
+
+func (fs *fileService) deleteStored(ctx context.Context, storageKey string) {
+	if err := fs.storage.Delete(ctx, storageKey); err != nil && !errors.Is(err, errs.ErrFileNotFound) {
+		slog.ErrorContext(ctx, "Failed to delete stored file", "error", err, "key", storageKey)
+	}
+}
+
+func (fs *fileService) verify(filePath string, req dto.FileGetRequest) error {
+	if req.Signature == "" {
+		return errs.ErrFileSignatureInvalid
+	}
+
+	expected := fs.sign(filePath, req)
+	if !hmac.Equal([]byte(expected), []byte(req.Signature)) {
+		return errs.ErrFileSignatureInvalid
+	}
+
+	if time.Now().Unix() > req.Expires {
+		return errs.ErrFileURLExpired
+	}
+	return nil
+}
+
+// sign computes the signature of the URL to the file under path, covering
+// every parameter of req other than the signature itself.
+func (fs *fileService) sign(filePath string, req dto.FileGetRequest) string {
+	mac := hmac.New(sha256.New, fs.signingKey)
+	mac.Write([]byte(path.Clean(filePath) + "\n" + fileQuery(req).Encode()))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// fileQuery lists the set parameters of req other than the signature, which
+// encode in a canonical order. Transformations are left out, as they do not
+// give access to anything more than the file itself.
+func fileQuery(req dto.FileGetRequest) url.Values {
+	query := url.Values{}
+	if req.Expires != 0 {
+		query.Set("expires", strconv.FormatInt(req.Expires, 10))
+	}
+	if req.Disposition != "" {
+		query.Set("disposition", req.Disposition)
+	}
+	if req.Variant != "" {
+		query.Set("variant", req.Variant)
+	}
+	return query
+}
+
+// isPlainFileName reports whether name is made of letters, digits, dots,
+// dashes and underscores only, without being hidden.
+func isPlainFileName(name string) bool {
+	if name == "" || strings.HasPrefix(name, ".") {
+		return false
+	}
+
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("._-", r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// objectETag returns the entity tag of a stored object, falling back to a weak
+// one derived from its size and modification time.
+func objectETag(info storage.ObjectInfo) string {
+	if info.ETag != "" {
+		return info.ETag
+	}
+	return fmt.Sprintf(`W/"%x-%x"`, info.LastModified.UnixNano(), info.Size)
+}
+
+// validateTransform only allows transformations from the allow-lists, so that
+// the variety of transformations to compute and cache stays bounded.
+func validateTransform(req dto.FileGetRequest) error {
+	if req.Width != 0 && !slices.Contains(transformSizes, req.Width) {
+		return fmt.Errorf("%w: width %d", errs.ErrInvalidTransform, req.Width)
+	}
+
+	if req.Height != 0 && !slices.Contains(transformSizes, req.Height) {
+		return fmt.Errorf("%w: height %d", errs.ErrInvalidTransform, req.Height)
+	}
+
+	if req.Fit != "" && !slices.Contains(transformFits, req.Fit) {
+		return fmt.Errorf("%w: fit %s", errs.ErrInvalidTransform, req.Fit)
+	}
+
+	if req.Format != "" && !slices.Contains(transformFormats, req.Format) {
+		return fmt.Errorf("%w: format %s", errs.ErrInvalidTransform, req.Format)
+	}
+	return nil
+}
+
+// transformCacheKey is the storage key a transformation of the content under
+// storageKey is cached at.
+func transformCacheKey(storageKey string, req dto.FileGetRequest) string {
+	fit := req.Fit
+	if fit == "" {
+		fit = imaging.FitContain
+	}
+
+	cacheKey := fmt.Sprintf("%s/%s/%dx%d-%s", constant.FileCacheDir, storageKey, req.Width, req.Height, fit)
+	if req.Format != "" {
+		cacheKey += "." + req.Format
+	}
+	return cacheKey
+}
+
+// validateDisposition only allows well-formed inline and attachment values,
+// as the disposition ends up as a response header.
+func validateDisposition(disposition string) error {
+	if disposition == "" {
+		return nil
+	}
+
+	dispositionType, _, err := mime.ParseMediaType(disposition)
+	if err != nil || (dispositionType != "inline" && dispositionType != "attachment") {
+		return errs.ErrInvalidFileDisposition
+	}
+	return nil
+}
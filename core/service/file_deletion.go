@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"reflect"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zetsux/gin-gorm-clean-starter/common/constant"
+	"github.com/zetsux/gin-gorm-clean-starter/core/entity"
+	errs "github.com/zetsux/gin-gorm-clean-starter/core/helper/errors"
+	"github.com/zetsux/gin-gorm-clean-starter/core/repository"
+)
+
+type FileDeletionService interface {
+	// Process attempts a queued deletion right away, a failed deletion stays
+	// queued and is retried later by Run
+	Process(ctx context.Context, id string) error
+	// Release queues the deletion of a stored file which ended up unused and
+	// attempts it right away, so that a failed release is retried
+	Release(ctx context.Context, path string)
+	// ProcessDue attempts every queued deletion that is due, returning how
+	// many of them went through
+	ProcessDue(ctx context.Context) (int, error)
+	// Run processes the due deletions every interval until ctx is done
+	Run(ctx context.Context, interval time.Duration)
+}
+
+type fileDeletionService struct {
+	fileDeletionRepository repository.FileDeletionRepository
+	fileService            FileService
+}
+
+func NewFileDeletionService(fileDeletionR repository.FileDeletionRepository, fileS FileService) FileDeletionService {
+	return &fileDeletionService{
+		fileDeletionRepository: fileDeletionR,
+		fileService:            fileS,
+	}
+}
+
+// newFileDeletion builds the deletion of the file under path, which is left
+// to whoever queued it until its lease is over.
+func newFileDeletion(path string) entity.FileDeletion {
+	return entity.FileDeletion{
+		Path:          path,
+		NextAttemptAt: time.Now().Add(constant.FileDeletionLease),
+	}
+}
+
+// processFileDeletion attempts a file deletion queued along with a committed
+// change right away, a failed attempt is left to the deletion worker.
+func processFileDeletion(ctx context.Context, fileDeletionS FileDeletionService, deletion entity.FileDeletion) {
+	if deletion.ID == uuid.Nil {
+		return
+	}
+
+	if err := fileDeletionS.Process(ctx, deletion.ID.String()); err != nil {
+		slog.ErrorContext(ctx, "Failed to delete file", "error", err, "deletion_id", deletion.ID)
+	}
+}
+
+func (fds *fileDeletionService) Release(ctx context.Context, path string) {
+	deletion, err := fds.fileDeletionRepository.CreateFileDeletion(ctx, nil, newFileDeletion(path))
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to queue file deletion", "error", err, "path", path)
+		return
+	}
+	processFileDeletion(ctx, fds, deletion)
+}
+
+func (fds *fileDeletionService) Process(ctx context.Context, id string) error {
+	deletion, err := fds.fileDeletionRepository.GetFileDeletionByID(ctx, nil, id)
+	if err != nil {
+		return err
+	}
+
+	if reflect.DeepEqual(deletion, entity.FileDeletion{}) {
+		return nil
+	}
+	return fds.attempt(ctx, deletion)
+}
+
+func (fds *fileDeletionService) ProcessDue(ctx context.Context) (int, error) {
+	now := time.Now()
+	deletions, err := fds.fileDeletionRepository.ClaimDueFileDeletions(ctx, nil,
+		now, now.Add(constant.FileDeletionLease), constant.FileDeletionBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	done := 0
+	for _, deletion := range deletions {
+		if err := fds.attempt(ctx, deletion); err != nil {
+			slog.ErrorContext(ctx, "Failed to delete queued file", "error", err, "path", deletion.Path)
+			continue
+		}
+		done++
+	}
+	return done, nil
+}
+
+func (fds *fileDeletionService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := fds.ProcessDue(ctx); err != nil {
+				slog.ErrorContext(ctx, "Failed to process queued file deletions", "error", err)
+			}
+		}
+	}
+}
+
+// attempt releases the file of deletion, dequeuing it once the file is gone
+// and otherwise retrying it later with an exponential backoff.
+func (fds *fileDeletionService) attempt(ctx context.Context, deletion entity.FileDeletion) error {
+	err := fds.fileService.Release(ctx, deletion.Path)
+	if err == nil || errors.Is(err, errs.ErrFileNotFound) {
+		return fds.fileDeletionRepository.DeleteFileDeletionByID(ctx, nil, deletion.ID.String())
+	}
+
+	backoff := constant.FileDeletionMaxRetryDelay
+	for delay, attempt := constant.FileDeletionRetryDelay, 0; delay < backoff; attempt++ {
+		if attempt == deletion.Attempts {
+			backoff = delay
+		}
+		delay *= 2
+	}
+
+	deletion.Attempts++
+	deletion.LastError = err.Error()
+	deletion.NextAttemptAt = time.Now().Add(backoff)
+	if rescheduleErr := fds.fileDeletionRepository.RescheduleFileDeletion(ctx, nil, deletion); rescheduleErr != nil {
+		slog.ErrorContext(ctx, "Failed to reschedule file deletion", "error", rescheduleErr, "path", deletion.Path)
+	}
+	return err
+}
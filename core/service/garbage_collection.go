@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/zetsux/gin-gorm-clean-starter/common/constant"
+	"github.com/zetsux/gin-gorm-clean-starter/common/storage"
+	"github.com/zetsux/gin-gorm-clean-starter/core/helper/dto"
+	errs "github.com/zetsux/gin-gorm-clean-starter/core/helper/errors"
+	"github.com/zetsux/gin-gorm-clean-starter/core/repository"
+)
+
+// fileReference is a column holding the paths of the files stored in Dir, or
+// their IDs when ByID is set.
+type fileReference struct {
+	Dir    string
+	Table  string
+	Column string
+	ByID   bool
+}
+
+// fileReferences lists every column referencing files, a file which none of
+// them points at anymore is garbage.
+var fileReferences = []fileReference{
+	{Dir: constant.PictureDir, Table: "users", Column: "picture"},
+	{Dir: constant.AttachmentDir, Table: "attachments", Column: "file_id", ByID: true},
+}
+
+type GarbageCollectionService interface {
+	// Collect finds the files and stored objects nothing references anymore,
+	// deleting them along with the expired uploads unless req is a dry run
+	Collect(ctx context.Context, req dto.GarbageCollectionRequest) (dto.GarbageCollectionResponse, error)
+	// Run collects the garbage every interval until ctx is done
+	Run(ctx context.Context, interval time.Duration)
+}
+
+type garbageCollectionService struct {
+	fileRepository repository.FileRepository
+	fileService    FileService
+	uploadService  UploadService
+	storage        storage.Storage
+}
+
+func NewGarbageCollectionService(fileR repository.FileRepository,
+	fileS FileService, uploadS UploadService, store storage.Storage) GarbageCollectionService {
+	return &garbageCollectionService{
+		fileRepository: fileR,
+		fileService:    fileS,
+		uploadService:  uploadS,
+		storage:        store,
+	}
+}
+
+func (gcs *garbageCollectionService) Collect(ctx context.Context,
+	req dto.GarbageCollectionRequest) (dto.GarbageCollectionResponse, error) {
+	if req.MinAge < 0 {
+		return dto.GarbageCollectionResponse{}, errs.ErrInvalidGarbageMinAge
+	} else if req.MinAge == 0 {
+		req.MinAge = constant.GarbageCollectionMinAge
+	}
+
+	gcResp := dto.GarbageCollectionResponse{
+		DryRun:  req.DryRun,
+		Before:  time.Now().Add(-req.MinAge),
+		Files:   []dto.GarbageFile{},
+		Objects: []dto.GarbageObject{},
+		Failed:  []dto.GarbageFailure{},
+	}
+
+	for _, ref := range fileReferences {
+		files, err := gcs.fileRepository.GetUnreferencedFiles(ctx, nil,
+			ref.Dir, ref.Table, ref.Column, ref.ByID, gcResp.Before)
+		if err != nil {
+			return dto.GarbageCollectionResponse{}, err
+		}
+
+		for _, file := range files {
+			garbage := dto.GarbageFile{
+				Path:      file.Dir + "/" + file.ID.String(),
+				Size:      file.Size,
+				CreatedAt: file.CreatedAt,
+			}
+			for _, variant := range file.Variants {
+				garbage.Size += variant.Size
+			}
+			gcResp.Files = append(gcResp.Files, garbage)
+			gcResp.TotalSize += garbage.Size
+
+			if !req.DryRun {
+				gcs.collect(&gcResp, garbage.Path, gcs.fileService.Release(ctx, garbage.Path))
+			}
+		}
+	}
+
+	// the uploads nobody resumed are only deleted when they are next touched
+	// otherwise, which may never happen
+	if !req.DryRun {
+		expired, err := gcs.uploadService.DeleteExpiredUploads(ctx)
+		if err != nil {
+			return dto.GarbageCollectionResponse{}, err
+		}
+		gcResp.ExpiredUploads = expired
+	}
+
+	// listed after the files are released, so that their content shows up
+	// here whenever releasing them did not delete it
+	objects, err := gcs.unreferencedObjects(ctx, gcResp.Before)
+	if err != nil {
+		return dto.GarbageCollectionResponse{}, err
+	}
+
+	for _, object := range objects {
+		gcResp.Objects = append(gcResp.Objects, dto.GarbageObject{
+			Key:          object.Key,
+			Size:         object.Size,
+			LastModified: object.LastModified,
+		})
+		gcResp.TotalSize += object.Size
+
+		if !req.DryRun {
+			gcs.collect(&gcResp, object.Key, gcs.storage.Delete(ctx, object.Key))
+		}
+	}
+
+	return gcResp, nil
+}
+
+func (gcs *garbageCollectionService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			gcResp, err := gcs.Collect(ctx, dto.GarbageCollectionRequest{})
+			if err != nil {
+				slog.ErrorContext(ctx, "Failed to collect garbage files", "error", err)
+				continue
+			}
+			slog.InfoContext(ctx, "Collected garbage files", "collected", gcResp.Collected,
+				"failed", len(gcResp.Failed), "expired_uploads", gcResp.ExpiredUploads)
+		}
+	}
+}
+
+// unreferencedObjects lists the stored objects last modified before the given
+// time which are neither the content of a file, one of its variants, a chunk
+// of an upload, a picture stored before file metadata was tracked, nor a
+// cached transformation of any of these.
+func (gcs *garbageCollectionService) unreferencedObjects(ctx context.Context,
+	before time.Time) ([]storage.ObjectInfo, error) {
+	keys, err := gcs.fileRepository.GetReferencedStorageKeys(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	referenced := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		referenced[key] = true
+	}
+
+	objects, err := gcs.storage.List(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	unreferenced := []storage.ObjectInfo{}
+	for _, object := range objects {
+		if !object.LastModified.Before(before) || referenced[object.Key] {
+			continue
+		}
+
+		if cached, ok := strings.CutPrefix(object.Key, constant.FileCacheDir+"/"); ok && referenced[path.Dir(cached)] {
+			continue
+		}
+		unreferenced = append(unreferenced, object)
+	}
+	return unreferenced, nil
+}
+
+func (gcs *garbageCollectionService) collect(gcResp *dto.GarbageCollectionResponse, key string, err error) {
+	if err != nil && !errors.Is(err, errs.ErrFileNotFound) {
+		gcResp.Failed = append(gcResp.Failed, dto.GarbageFailure{Path: key, Error: err.Error()})
+		return
+	}
+	gcResp.Collected++
+}
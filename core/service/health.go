@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zetsux/gin-gorm-clean-starter/common/constant"
+	"github.com/zetsux/gin-gorm-clean-starter/common/scanner"
+	"github.com/zetsux/gin-gorm-clean-starter/common/storage"
+	"github.com/zetsux/gin-gorm-clean-starter/core/helper/dto"
+	errs "github.com/zetsux/gin-gorm-clean-starter/core/helper/errors"
+	"github.com/zetsux/gin-gorm-clean-starter/core/repository"
+)
+
+// HealthCheck checks that a dependency the app needs to serve requests is
+// available, failing when ctx is done.
+type HealthCheck func(ctx context.Context) error
+
+type HealthService interface {
+	// Register adds a check run by each readiness probe, which fails once
+	// timeout is over
+	Register(name string, timeout time.Duration, check HealthCheck)
+	// CheckLiveness reports that the process is up, whatever its dependencies
+	CheckLiveness() dto.HealthResponse
+	// CheckReadiness runs every check at once, reporting the status and
+	// latency of each component
+	CheckReadiness(ctx context.Context) (dto.HealthResponse, error)
+	// Shutdown makes the app not ready anymore, so that no new traffic is
+	// routed to it while it drains
+	Shutdown()
+}
+
+type healthCheck struct {
+	name    string
+	timeout time.Duration
+	check   HealthCheck
+}
+
+type healthService struct {
+	mu           sync.RWMutex
+	checks       []healthCheck
+	shuttingDown atomic.Bool
+}
+
+func NewHealthService() HealthService {
+	return &healthService{}
+}
+
+// NewDatabaseHealthCheck checks that the database answers a ping.
+func NewDatabaseHealthCheck(healthR repository.HealthRepository) HealthCheck {
+	return healthR.Ping
+}
+
+// NewStorageHealthCheck checks that the storage can be written to by putting
+// then deleting a small object.
+func NewStorageHealthCheck(store storage.Storage) HealthCheck {
+	return func(ctx context.Context) error {
+		if _, err := store.Put(ctx, constant.HealthCheckKey, strings.NewReader("ok"), 2, "text/plain"); err != nil {
+			return err
+		}
+		return store.Delete(ctx, constant.HealthCheckKey)
+	}
+}
+
+// NewScannerHealthCheck checks that the scanner answers a ping.
+func NewScannerHealthCheck(scan scanner.Scanner) HealthCheck {
+	return scan.Ping
+}
+
+func (hs *healthService) Register(name string, timeout time.Duration, check HealthCheck) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	hs.checks = append(hs.checks, healthCheck{name: name, timeout: timeout, check: check})
+}
+
+func (hs *healthService) CheckLiveness() dto.HealthResponse {
+	return dto.HealthResponse{Status: constant.EnumHealthStatusUp}
+}
+
+func (hs *healthService) CheckReadiness(ctx context.Context) (dto.HealthResponse, error) {
+	if hs.shuttingDown.Load() {
+		return dto.HealthResponse{Status: constant.EnumHealthStatusDown}, errs.ErrServiceShuttingDown
+	}
+
+	hs.mu.RLock()
+	checks := append([]healthCheck{}, hs.checks...)
+	hs.mu.RUnlock()
+
+	components := make([]dto.ComponentHealth, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check healthCheck) {
+			defer wg.Done()
+			components[i] = runHealthCheck(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	res := dto.HealthResponse{
+		Status:     constant.EnumHealthStatusUp,
+		Components: make(map[string]dto.ComponentHealth, len(checks)),
+	}
+	for i, check := range checks {
+		res.Components[check.name] = components[i]
+		if components[i].Status != constant.EnumHealthStatusUp {
+			res.Status = constant.EnumHealthStatusDown
+		}
+	}
+
+	// a shutdown which started during the checks wins over their outcome
+	if hs.shuttingDown.Load() {
+		res.Status = constant.EnumHealthStatusDown
+		return res, errs.ErrServiceShuttingDown
+	}
+	if res.Status != constant.EnumHealthStatusUp {
+		return res, errs.ErrServiceUnhealthy
+	}
+	return res, nil
+}
+
+func (hs *healthService) Shutdown() {
+	hs.shuttingDown.Store(true)
+}
+
+// runHealthCheck runs a single check within its timeout, a check which does
+// not return in time being reported down even if it ignores its ctx.
+func runHealthCheck(ctx context.Context, check healthCheck) dto.ComponentHealth {
+	checkCtx, cancel := context.WithTimeout(ctx, check.timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- check.check(checkCtx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-checkCtx.Done():
+		err = checkCtx.Err()
+	}
+
+	component := dto.ComponentHealth{
+		Status:    constant.EnumHealthStatusUp,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			err = errs.ErrHealthCheckTimeout
+		}
+		component.Status = constant.EnumHealthStatusDown
+		component.Error = err.Error()
+	}
+	return component
+}
@@ -2,8 +2,7 @@ package service
 
 import (
 	"fmt"
-	"log"
-	"os"
+	"log/slog"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -27,21 +26,13 @@ type jwtService struct {
 	issuer    string
 }
 
-func NewJWTService() JWTService {
+func NewJWTService(secretKey string) JWTService {
 	return &jwtService{
-		secretKey: getSecretKey(),
+		secretKey: secretKey,
 		issuer:    constant.EnumRoleAdmin,
 	}
 }
 
-func getSecretKey() string {
-	secretKey := os.Getenv("JWT_SECRET")
-	if secretKey == "" {
-		secretKey = "jwt_secret_key"
-	}
-	return secretKey
-}
-
 func (j *jwtService) GenerateToken(id string, role string) string {
 	claims := &jwtCustomClaim{
 		id,
@@ -55,7 +46,7 @@ func (j *jwtService) GenerateToken(id string, role string) string {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	t, err := token.SignedString([]byte(j.secretKey))
 	if err != nil {
-		log.Println(err)
+		slog.Error("Failed to sign token", "error", err)
 	}
 	return t
 }
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"io"
+	"math"
+	"reflect"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zetsux/gin-gorm-clean-starter/common/base"
+	"github.com/zetsux/gin-gorm-clean-starter/common/constant"
+	"github.com/zetsux/gin-gorm-clean-starter/core/entity"
+	"github.com/zetsux/gin-gorm-clean-starter/core/helper/dto"
+	errs "github.com/zetsux/gin-gorm-clean-starter/core/helper/errors"
+	"github.com/zetsux/gin-gorm-clean-starter/core/repository"
+)
+
+type StorageQuotaService interface {
+	GetStorageUsage(ctx context.Context, userID string) (dto.StorageUsageResponse, error)
+	SetStorageQuota(ctx context.Context, req dto.StorageQuotaUpdateRequest, userID string) (
+		dto.StorageUsageResponse, error)
+	// RemainingStorage returns how many more bytes the user can store, which
+	// is math.MaxInt64 for users without a limit
+	RemainingStorage(ctx context.Context, userID string) (int64, error)
+	// ReserveStorage sets size bytes of the quota of the user aside under
+	// reservationID until expiresAt, failing with ErrStorageQuotaExceeded when
+	// they do not fit. Reserving again under the same ID resizes the reservation
+	ReserveStorage(ctx context.Context, userID string, reservationID uuid.UUID, size int64,
+		expiresAt time.Time) error
+	// ReleaseStorage gives the bytes reserved under reservationID back
+	ReleaseStorage(ctx context.Context, reservationID uuid.UUID) error
+}
+
+type storageQuotaService struct {
+	userRepository repository.UserRepository
+	fileRepository repository.FileRepository
+	roleQuotas     map[string]int64
+}
+
+// NewStorageQuotaService enforces the quotas of roleQuotas, where a negative
+// quota is unlimited. Roles without a quota get the quota of regular users.
+func NewStorageQuotaService(userR repository.UserRepository,
+	fileR repository.FileRepository, roleQuotas map[string]int64) StorageQuotaService {
+	return &storageQuotaService{
+		userRepository: userR,
+		fileRepository: fileR,
+		roleQuotas:     roleQuotas,
+	}
+}
+
+func (sqs *storageQuotaService) GetStorageUsage(ctx context.Context, userID string) (dto.StorageUsageResponse, error) {
+	user, err := sqs.getUser(ctx, userID)
+	if err != nil {
+		return dto.StorageUsageResponse{}, err
+	}
+
+	files, used, err := sqs.fileRepository.GetStorageUsage(ctx, nil, userID)
+	if err != nil {
+		return dto.StorageUsageResponse{}, err
+	}
+
+	reserved, err := sqs.fileRepository.GetReservedStorage(ctx, nil, userID)
+	if err != nil {
+		return dto.StorageUsageResponse{}, err
+	}
+
+	usageResp := dto.StorageUsageResponse{
+		UserID:      user.ID.String(),
+		Files:       files,
+		Used:        used,
+		Reserved:    reserved,
+		CustomQuota: user.StorageQuota != nil,
+	}
+	if quota := sqs.quota(user); quota >= 0 {
+		remaining := quota - used - reserved
+		if remaining < 0 {
+			remaining = 0
+		}
+		usageResp.Quota, usageResp.Remaining = &quota, &remaining
+	}
+	return usageResp, nil
+}
+
+func (sqs *storageQuotaService) SetStorageQuota(ctx context.Context,
+	req dto.StorageQuotaUpdateRequest, userID string) (dto.StorageUsageResponse, error) {
+	if req.Quota != nil && *req.Quota < constant.UnlimitedStorageQuota {
+		return dto.StorageUsageResponse{}, errs.ErrInvalidStorageQuota
+	}
+
+	if _, err := sqs.getUser(ctx, userID); err != nil {
+		return dto.StorageUsageResponse{}, err
+	}
+
+	if err := sqs.userRepository.UpdateUserStorageQuota(ctx, nil, userID, req.Quota); err != nil {
+		return dto.StorageUsageResponse{}, err
+	}
+	return sqs.GetStorageUsage(ctx, userID)
+}
+
+func (sqs *storageQuotaService) RemainingStorage(ctx context.Context, userID string) (int64, error) {
+	usage, err := sqs.GetStorageUsage(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	if usage.Remaining == nil {
+		return math.MaxInt64, nil
+	}
+	return *usage.Remaining, nil
+}
+
+func (sqs *storageQuotaService) ReserveStorage(ctx context.Context, userID string,
+	reservationID uuid.UUID, size int64, expiresAt time.Time) error {
+	user, err := sqs.getUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	// nothing needs to be set aside for users without a limit
+	quota := sqs.quota(user)
+	if quota < 0 {
+		return nil
+	}
+
+	txr := sqs.fileRepository.TxRepository()
+	tx, err := txr.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = sqs.fileRepository.ReserveStorage(ctx, tx, entity.StorageReservation{
+		ID:        reservationID,
+		OwnerID:   user.ID,
+		Size:      size,
+		ExpiresAt: expiresAt,
+	}, quota)
+	return txr.CommitOrRollbackTx(ctx, tx, err)
+}
+
+func (sqs *storageQuotaService) ReleaseStorage(ctx context.Context, reservationID uuid.UUID) error {
+	return sqs.fileRepository.DeleteStorageReservationByID(ctx, nil, reservationID.String())
+}
+
+func (sqs *storageQuotaService) getUser(ctx context.Context, userID string) (entity.User, error) {
+	user, err := sqs.userRepository.GetUserByID(ctx, nil, userID, base.GetRequest{})
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	if reflect.DeepEqual(user, entity.User{}) {
+		return entity.User{}, errs.ErrUserNotFound
+	}
+	return user, nil
+}
+
+// quota returns the quota of the user, being their own one when it was
+// overridden and the one of their role otherwise.
+func (sqs *storageQuotaService) quota(user entity.User) int64 {
+	if user.StorageQuota != nil {
+		return *user.StorageQuota
+	}
+
+	if quota, ok := sqs.roleQuotas[user.Role]; ok {
+		return quota
+	}
+	return sqs.roleQuotas[constant.EnumRoleUser]
+}
+
+// quotaReader fails with ErrStorageQuotaExceeded as soon as more than the
+// remaining bytes are read, for content which size is not known upfront.
+type quotaReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (qr *quotaReader) Read(p []byte) (int, error) {
+	n, err := qr.r.Read(p)
+	qr.remaining -= int64(n)
+	if qr.remaining < 0 {
+		return n, errs.ErrStorageQuotaExceeded
+	}
+	return n, err
+}
@@ -0,0 +1,421 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zetsux/gin-gorm-clean-starter/common/constant"
+	"github.com/zetsux/gin-gorm-clean-starter/common/storage"
+	"github.com/zetsux/gin-gorm-clean-starter/core/entity"
+	"github.com/zetsux/gin-gorm-clean-starter/core/helper/dto"
+	errs "github.com/zetsux/gin-gorm-clean-starter/core/helper/errors"
+	"github.com/zetsux/gin-gorm-clean-starter/core/repository"
+)
+
+type UploadService interface {
+	MaxSize() int64
+	CreateUpload(ctx context.Context, req dto.UploadCreateRequest, ownerID string) (dto.UploadResponse, error)
+	GetUpload(ctx context.Context, id string, ownerID string) (dto.UploadResponse, error)
+	// WriteChunk appends the chunk at the given offset, keeping whatever was
+	// received before the chunk got interrupted so that it can be resumed
+	WriteChunk(ctx context.Context, req dto.UploadChunkRequest, id string, ownerID string) (dto.UploadResponse, error)
+	DeleteUpload(ctx context.Context, id string, ownerID string) error
+	// Attach hands the content of a completed upload over to store, which turns
+	// it into a file, the upload is gone once stored
+	Attach(ctx context.Context, id string, ownerID string,
+		store func(dto.FileUploadRequest) (dto.FileResponse, error)) (dto.FileResponse, error)
+	// DeleteExpiredUploads deletes the uploads which expired without being
+	// resumed, returning how many of them are gone
+	DeleteExpiredUploads(ctx context.Context) (int, error)
+}
+
+type uploadService struct {
+	uploadRepository    repository.UploadRepository
+	storageQuotaService StorageQuotaService
+	storage             storage.Storage
+	maxSize             int64
+}
+
+func NewUploadService(uploadR repository.UploadRepository,
+	storageQuotaS StorageQuotaService, store storage.Storage, maxSize int64) UploadService {
+	return &uploadService{
+		uploadRepository:    uploadR,
+		storageQuotaService: storageQuotaS,
+		storage:             store,
+		maxSize:             maxSize,
+	}
+}
+
+func toUploadResponse(upload entity.Upload) dto.UploadResponse {
+	return dto.UploadResponse{
+		ID:        upload.ID.String(),
+		Length:    upload.Length,
+		Offset:    upload.Offset,
+		Metadata:  upload.Metadata,
+		ExpiresAt: upload.ExpiresAt,
+	}
+}
+
+func (us *uploadService) MaxSize() int64 {
+	return us.maxSize
+}
+
+func (us *uploadService) CreateUpload(ctx context.Context,
+	req dto.UploadCreateRequest, ownerID string) (dto.UploadResponse, error) {
+	if req.DeferLength != "" || req.Length == nil {
+		return dto.UploadResponse{}, errs.ErrInvalidUploadLength
+	}
+
+	if *req.Length > us.maxSize {
+		return dto.UploadResponse{}, errs.ErrUploadTooLarge
+	}
+
+	owner, err := uuid.Parse(ownerID)
+	if err != nil {
+		return dto.UploadResponse{}, err
+	}
+
+	metadata, err := parseUploadMetadata(req.Metadata)
+	if err != nil {
+		return dto.UploadResponse{}, err
+	}
+
+	// the whole length is reserved upfront under the ID of the upload, so
+	// that clients do not send what could never be stored
+	uploadID, expiresAt := uuid.New(), time.Now().Add(constant.UploadExpiry)
+	err = us.storageQuotaService.ReserveStorage(ctx, ownerID, uploadID, *req.Length, expiresAt)
+	if err != nil {
+		return dto.UploadResponse{}, err
+	}
+
+	upload, err := us.uploadRepository.CreateUpload(ctx, nil, entity.Upload{
+		ID:          uploadID,
+		OwnerID:     owner,
+		Length:      *req.Length,
+		Metadata:    req.Metadata,
+		Filename:    metadata["filename"],
+		ContentType: metadata["filetype"],
+		ExpiresAt:   expiresAt,
+	})
+	if err != nil {
+		us.releaseStorage(ctx, uploadID)
+		return dto.UploadResponse{}, err
+	}
+	return toUploadResponse(upload), nil
+}
+
+func (us *uploadService) GetUpload(ctx context.Context, id string, ownerID string) (dto.UploadResponse, error) {
+	upload, err := us.getUpload(ctx, id, ownerID)
+	if err != nil {
+		return dto.UploadResponse{}, err
+	}
+	return toUploadResponse(upload), nil
+}
+
+func (us *uploadService) WriteChunk(ctx context.Context,
+	req dto.UploadChunkRequest, id string, ownerID string) (dto.UploadResponse, error) {
+	upload, err := us.getUpload(ctx, id, ownerID)
+	if err != nil {
+		return dto.UploadResponse{}, err
+	}
+
+	if req.Offset == nil || *req.Offset != upload.Offset {
+		return toUploadResponse(upload), errs.ErrUploadOffsetMismatch
+	}
+
+	if upload.IsComplete() {
+		return toUploadResponse(upload), nil
+	}
+
+	remaining := upload.Length - upload.Offset
+	if req.ContentLength > remaining {
+		return toUploadResponse(upload), errs.ErrUploadChunkTooLarge
+	}
+
+	// every attempt is stored under its own key, so that a losing concurrent
+	// attempt for the same offset never touches the chunk that was accepted
+	partKey := fmt.Sprintf("%s/%s/%s", constant.UploadChunkDir, upload.ID, uuid.NewString())
+	// a byte past the length is read, so that a chunk going past it is told
+	// apart from one ending right on it
+	content := &partialReader{r: io.LimitReader(req.Content, remaining+1)}
+	info, err := us.storage.Put(ctx, partKey, content, -1, "")
+	if err != nil {
+		return toUploadResponse(upload), err
+	}
+
+	if info.Size > remaining {
+		us.deleteParts(ctx, partKey)
+		return toUploadResponse(upload), errs.ErrUploadChunkTooLarge
+	}
+
+	if info.Size == 0 {
+		us.deleteParts(ctx, partKey)
+		return toUploadResponse(upload), content.err
+	}
+
+	txr := us.uploadRepository.TxRepository()
+	tx, err := txr.BeginTx(ctx)
+	if err != nil {
+		us.deleteParts(ctx, partKey)
+		return toUploadResponse(upload), err
+	}
+
+	advanced, err := us.uploadRepository.AdvanceUpload(ctx, tx, upload, entity.UploadPart{
+		Size:       info.Size,
+		StorageKey: partKey,
+	})
+	err = txr.CommitOrRollbackTx(ctx, tx, err)
+	if err != nil {
+		us.deleteParts(ctx, partKey)
+		return toUploadResponse(upload), err
+	}
+	return toUploadResponse(advanced), nil
+}
+
+func (us *uploadService) DeleteUpload(ctx context.Context, id string, ownerID string) error {
+	upload, err := us.getUpload(ctx, id, ownerID)
+	if err != nil && !errors.Is(err, errs.ErrUploadExpired) {
+		return err
+	}
+
+	return us.deleteUpload(ctx, upload)
+}
+
+func (us *uploadService) Attach(ctx context.Context, id string, ownerID string,
+	store func(dto.FileUploadRequest) (dto.FileResponse, error)) (dto.FileResponse, error) {
+	upload, err := us.getUpload(ctx, id, ownerID)
+	if err != nil {
+		return dto.FileResponse{}, err
+	}
+
+	if !upload.IsComplete() {
+		return dto.FileResponse{}, errs.ErrUploadIncomplete
+	}
+
+	// claimed before its content is read, so that concurrent attachments of
+	// the same upload never both store it
+	now := time.Now()
+	err = us.uploadRepository.ClaimUpload(ctx, nil, upload.ID.String(), now, now.Add(constant.UploadClaimLease))
+	if err != nil {
+		return dto.FileResponse{}, err
+	}
+
+	parts, err := us.uploadRepository.GetUploadParts(ctx, nil, upload.ID.String())
+	if err != nil {
+		us.unclaimUpload(ctx, upload)
+		return dto.FileResponse{}, err
+	}
+
+	keys := make([]string, 0, len(parts))
+	for _, part := range parts {
+		keys = append(keys, part.StorageKey)
+	}
+
+	content := &partsReader{ctx: ctx, storage: us.storage, keys: keys}
+	defer content.Close()
+
+	file, err := store(dto.FileUploadRequest{
+		OwnerID:       ownerID,
+		Name:          upload.Filename,
+		ContentType:   upload.ContentType,
+		Size:          upload.Length,
+		Content:       content,
+		ReservationID: upload.ID.String(),
+	})
+	if err != nil {
+		us.unclaimUpload(ctx, upload)
+		return dto.FileResponse{}, err
+	}
+
+	if err := us.deleteUpload(ctx, upload); err != nil {
+		slog.ErrorContext(ctx, "Failed to delete attached upload", "error", err, "upload_id", upload.ID)
+	}
+	return file, nil
+}
+
+func (us *uploadService) DeleteExpiredUploads(ctx context.Context) (int, error) {
+	deleted := 0
+	for {
+		now := time.Now()
+		uploads, err := us.uploadRepository.ClaimExpiredUploads(ctx, nil,
+			now, now.Add(constant.UploadClaimLease), constant.ExpiredUploadBatchSize)
+		if err != nil {
+			return deleted, err
+		}
+
+		// an upload which failed to be deleted stays claimed until its lease
+		// is over, so that the next batches move on to the other ones
+		for _, upload := range uploads {
+			if err := us.deleteUpload(ctx, upload); err != nil {
+				slog.ErrorContext(ctx, "Failed to delete expired upload", "error", err, "upload_id", upload.ID)
+				continue
+			}
+			deleted++
+		}
+
+		if len(uploads) < constant.ExpiredUploadBatchSize {
+			return deleted, nil
+		}
+	}
+}
+
+// getUpload finds an upload of the owner, expired uploads are deleted right
+// away and reported as such.
+func (us *uploadService) getUpload(ctx context.Context, id string, ownerID string) (entity.Upload, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return entity.Upload{}, errs.ErrUploadNotFound
+	}
+
+	upload, err := us.uploadRepository.GetUploadByID(ctx, nil, id)
+	if err != nil {
+		return entity.Upload{}, err
+	}
+
+	if reflect.DeepEqual(upload, entity.Upload{}) || upload.OwnerID.String() != ownerID {
+		return entity.Upload{}, errs.ErrUploadNotFound
+	}
+
+	if time.Now().After(upload.ExpiresAt) {
+		if err := us.deleteUpload(ctx, upload); err != nil {
+			slog.ErrorContext(ctx, "Failed to delete expired upload", "error", err, "upload_id", upload.ID)
+		}
+		return entity.Upload{}, errs.ErrUploadExpired
+	}
+	return upload, nil
+}
+
+func (us *uploadService) deleteUpload(ctx context.Context, upload entity.Upload) error {
+	if reflect.DeepEqual(upload, entity.Upload{}) {
+		return nil
+	}
+
+	parts, err := us.storage.List(ctx, constant.UploadChunkDir+"/"+upload.ID.String()+"/")
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(parts))
+	for _, part := range parts {
+		keys = append(keys, part.Key)
+	}
+	us.deleteParts(ctx, keys...)
+
+	if err := us.uploadRepository.DeleteUploadByID(ctx, nil, upload.ID.String()); err != nil {
+		return err
+	}
+	us.releaseStorage(ctx, upload.ID)
+	return nil
+}
+
+// releaseStorage releases the storage reserved for an upload, which otherwise
+// expires along with the upload.
+func (us *uploadService) releaseStorage(ctx context.Context, uploadID uuid.UUID) {
+	if err := us.storageQuotaService.ReleaseStorage(ctx, uploadID); err != nil {
+		slog.ErrorContext(ctx, "Failed to release reserved storage", "error", err, "upload_id", uploadID)
+	}
+}
+
+// unclaimUpload lets the upload be attached again after a failed attachment,
+// which otherwise has to wait for the lease to be over.
+func (us *uploadService) unclaimUpload(ctx context.Context, upload entity.Upload) {
+	if err := us.uploadRepository.UnclaimUpload(ctx, nil, upload.ID.String()); err != nil {
+		slog.ErrorContext(ctx, "Failed to unclaim upload", "error", err, "upload_id", upload.ID)
+	}
+}
+
+func (us *uploadService) deleteParts(ctx context.Context, keys ...string) {
+	for _, key := range keys {
+		if err := us.storage.Delete(ctx, key); err != nil && !errors.Is(err, errs.ErrFileNotFound) {
+			slog.ErrorContext(ctx, "Failed to delete upload chunk", "error", err, "key", key)
+		}
+	}
+}
+
+// parseUploadMetadata decodes the tus Upload-Metadata header, being a comma
+// separated list of keys each followed by an optional base64 encoded value.
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := map[string]string{}
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, errs.ErrInvalidUploadMetadata
+		}
+
+		value, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", errs.ErrInvalidUploadMetadata, key)
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
+
+// partialReader ends at the first read error instead of failing, so that the
+// bytes received before a dropped connection can still be kept.
+type partialReader struct {
+	r   io.Reader
+	err error
+}
+
+func (pr *partialReader) Read(p []byte) (int, error) {
+	n, err := pr.r.Read(p)
+	if err != nil && !errors.Is(err, io.EOF) {
+		pr.err = err
+		return n, io.EOF
+	}
+	return n, err
+}
+
+// partsReader reads the stored chunks one after another as a single stream.
+type partsReader struct {
+	ctx     context.Context
+	storage storage.Storage
+	keys    []string
+	current io.ReadCloser
+}
+
+func (pr *partsReader) Read(p []byte) (int, error) {
+	for {
+		if pr.current == nil {
+			if len(pr.keys) == 0 {
+				return 0, io.EOF
+			}
+
+			content, _, err := pr.storage.Get(pr.ctx, pr.keys[0])
+			if err != nil {
+				return 0, err
+			}
+			pr.current, pr.keys = content, pr.keys[1:]
+		}
+
+		n, err := pr.current.Read(p)
+		if errors.Is(err, io.EOF) {
+			pr.current.Close()
+			pr.current = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (pr *partsReader) Close() error {
+	if pr.current == nil {
+		return nil
+	}
+	return pr.current.Close()
+}
@@ -1,13 +1,26 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
 	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/gin-gonic/gin/binding"
 	"github.com/google/uuid"
 	"github.com/zetsux/gin-gorm-clean-starter/common/base"
 	"github.com/zetsux/gin-gorm-clean-starter/common/constant"
+	"github.com/zetsux/gin-gorm-clean-starter/common/imaging"
+	"github.com/zetsux/gin-gorm-clean-starter/common/metrics"
 	"github.com/zetsux/gin-gorm-clean-starter/common/util"
 	"github.com/zetsux/gin-gorm-clean-starter/core/entity"
 	"github.com/zetsux/gin-gorm-clean-starter/core/helper/dto"
@@ -15,40 +28,252 @@ import (
 	"github.com/zetsux/gin-gorm-clean-starter/core/repository"
 )
 
+// userStatusTransitions lists the statuses each user status can move to.
+var userStatusTransitions = map[string][]string{
+	constant.EnumStatusPending:   {constant.EnumStatusActive, constant.EnumStatusBanned},
+	constant.EnumStatusActive:    {constant.EnumStatusSuspended, constant.EnumStatusBanned},
+	constant.EnumStatusSuspended: {constant.EnumStatusActive, constant.EnumStatusBanned},
+	constant.EnumStatusBanned:    {constant.EnumStatusActive},
+}
+
+// pictureVariantSizes lists the sides in pixels of the square variants stored
+// along with every picture.
+var pictureVariantSizes = []int{64, 256, 1024}
+
 type userService struct {
-	userRepository repository.UserRepository
+	userRepository          repository.UserRepository
+	userStatusLogRepository repository.UserStatusLogRepository
+	loginEventRepository    repository.LoginEventRepository
+	fileDeletionRepository  repository.FileDeletionRepository
+	attachmentRepository    repository.AttachmentRepository
+	fileService             FileService
+	uploadService           UploadService
+	fileDeletionService     FileDeletionService
+	// maxPictureSize is the maximum size in bytes of a picture, however it is uploaded
+	maxPictureSize int64
 }
 
 type UserService interface {
-	VerifyLogin(ctx context.Context, email string, password string) bool
+	VerifyLogin(ctx context.Context, ud dto.UserLoginRequest) error
+	AuthenticateUser(ctx context.Context, id string) error
+	GetLoginEvents(ctx context.Context, userID string,
+		req base.GetsRequest) ([]dto.LoginEventResponse, base.PaginationResponse, error)
 	CreateNewUser(ctx context.Context, ud dto.UserRegisterRequest) (dto.UserResponse, error)
 	GetAllUsers(ctx context.Context, req base.GetsRequest) ([]dto.UserResponse, base.PaginationResponse, error)
 	GetUserByPrimaryKey(ctx context.Context, key string, value string) (dto.UserResponse, error)
-	UpdateSelfName(ctx context.Context, ud dto.UserNameUpdateRequest, id string) (dto.UserResponse, error)
-	UpdateUserByID(ctx context.Context, ud dto.UserUpdateRequest, id string) (dto.UserResponse, error)
-	DeleteUserByID(ctx context.Context, id string) error
-	ChangePicture(ctx context.Context, req dto.UserChangePictureRequest, userID string) (dto.UserResponse, error)
-	DeletePicture(ctx context.Context, userID string) error
+	GetUserByID(ctx context.Context, id string, req base.GetRequest) (dto.UserResponse, error)
+	UpdateSelfName(ctx context.Context, ud dto.UserNameUpdateRequest,
+		id string, version uint) (dto.UserResponse, error)
+	UpdateUserByID(ctx context.Context, ud dto.UserUpdateRequest,
+		id string, version uint) (dto.UserResponse, error)
+	PatchUserByID(ctx context.Context, patch []byte,
+		id string, version uint) (dto.UserResponse, error)
+	DeleteUserByID(ctx context.Context, id string, version uint) (dto.UserResponse, error)
+	AttachPicture(ctx context.Context, req dto.UserAttachPictureRequest,
+		userID string, version uint) (dto.UserResponse, error)
+	ChangePicture(ctx context.Context, req dto.UserChangePictureRequest,
+		userID string, version uint) (dto.UserResponse, error)
+	DeletePicture(ctx context.Context, userID string, version uint) (dto.UserResponse, error)
+	ChangeUserStatus(ctx context.Context, req dto.UserStatusUpdateRequest,
+		id string, actorID string, version uint) (dto.UserResponse, error)
+	GetUserStatusHistory(ctx context.Context, id string) ([]dto.UserStatusLogResponse, error)
+}
+
+func NewUserService(userR repository.UserRepository, userStatusLogR repository.UserStatusLogRepository,
+	loginEventR repository.LoginEventRepository, fileDeletionR repository.FileDeletionRepository,
+	attachmentR repository.AttachmentRepository, fileS FileService, uploadS UploadService,
+	fileDeletionS FileDeletionService, maxPictureSize int64) UserService {
+	return &userService{
+		userRepository:          userR,
+		userStatusLogRepository: userStatusLogR,
+		loginEventRepository:    loginEventR,
+		fileDeletionRepository:  fileDeletionR,
+		attachmentRepository:    attachmentR,
+		fileService:             fileS,
+		uploadService:           uploadS,
+		fileDeletionService:     fileDeletionS,
+		maxPictureSize:          maxPictureSize,
+	}
+}
+
+func (us *userService) toUserResponse(user entity.User) dto.UserResponse {
+	userResp := dto.UserResponse{
+		ID:          user.ID.String(),
+		Name:        user.Name,
+		Email:       user.Email,
+		Role:        user.Role,
+		Status:      user.EffectiveStatus(time.Now()),
+		LastLoginAt: user.LastLoginAt,
+		LastSeenAt:  user.LastSeenAt,
+		Version:     user.Version,
+	}
+	if user.Picture != nil && *user.Picture != "" {
+		userResp.Picture = us.fileService.FileURL(*user.Picture)
+		userResp.PictureVariants = map[string]string{}
+		for _, size := range pictureVariantSizes {
+			variant := strconv.Itoa(size)
+			userResp.PictureVariants[variant] = us.fileService.VariantURL(*user.Picture, variant)
+		}
+	} else if user.ID != uuid.Nil {
+		// users without a picture get their generated avatar instead
+		userResp.Picture = avatarURL(userResp.ID, 0)
+		userResp.PictureVariants = map[string]string{}
+		for _, size := range pictureVariantSizes {
+			userResp.PictureVariants[strconv.Itoa(size)] = avatarURL(userResp.ID, size)
+		}
+	}
+
+	// an expired status has reverted to active, so its details are left out
+	if userResp.Status == user.Status {
+		if user.StatusReason != nil {
+			userResp.StatusReason = *user.StatusReason
+		}
+		userResp.StatusExpiresAt = user.StatusExpiresAt
+	}
+
+	for _, log := range user.StatusLogs {
+		userResp.StatusHistory = append(userResp.StatusHistory, toUserStatusLogResponse(log))
+	}
+	return userResp
 }
 
-func NewUserService(userR repository.UserRepository) UserService {
-	return &userService{userRepository: userR}
+func toUserStatusLogResponse(log entity.UserStatusLog) dto.UserStatusLogResponse {
+	logResp := dto.UserStatusLogResponse{
+		ID:         log.ID.String(),
+		FromStatus: log.FromStatus,
+		ToStatus:   log.ToStatus,
+		Reason:     log.Reason,
+		ExpiresAt:  log.ExpiresAt,
+		CreatedAt:  log.CreatedAt,
+	}
+	if log.ActorID != nil {
+		logResp.ActorID = log.ActorID.String()
+	}
+	return logResp
 }
 
-func (us *userService) VerifyLogin(ctx context.Context, email string, password string) bool {
-	userCheck, err := us.userRepository.GetUserByPrimaryKey(ctx, nil, constant.DBAttrEmail, email)
+func (us *userService) VerifyLogin(ctx context.Context, ud dto.UserLoginRequest) error {
+	event := entity.LoginEvent{
+		Email:     ud.Email,
+		IP:        ud.IP,
+		UserAgent: ud.UserAgent,
+	}
+
+	userCheck, err := us.userRepository.GetUserByPrimaryKey(ctx, nil, constant.DBAttrEmail, ud.Email)
 	if err != nil {
-		return false
+		us.recordLoginEvent(ctx, event, constant.EnumLoginFailureError)
+		return err
 	}
-	passwordCheck, err := util.PasswordCompare(userCheck.Password, []byte(password))
+	if !(reflect.DeepEqual(userCheck, entity.User{})) {
+		event.UserID = &userCheck.ID
+	}
+
+	passwordCheck, err := util.PasswordCompare(userCheck.Password, []byte(ud.Password))
+	if err != nil || userCheck.Email != ud.Email || !passwordCheck {
+		us.recordLoginEvent(ctx, event, constant.EnumLoginFailureWrongCredential)
+		return errs.ErrUserWrongCredential
+	}
+
+	if !userCheck.IsActive(time.Now()) {
+		us.recordLoginEvent(ctx, event, constant.EnumLoginFailureNotActive)
+		return errs.ErrUserNotActive
+	}
+
+	us.recordLoginEvent(ctx, event, "")
+	if err := us.userRepository.UpdateUserLastLogin(ctx, nil, userCheck.ID.String(), time.Now()); err != nil {
+		slog.ErrorContext(ctx, "Failed to update last login", "error", err, "user_id", userCheck.ID)
+	}
+	return nil
+}
+
+// recordLoginEvent stores the login attempt, which is considered successful
+// when no failure reason is given. Failing to record never fails the login.
+func (us *userService) recordLoginEvent(ctx context.Context, event entity.LoginEvent, failureReason string) {
+	event.Success = failureReason == ""
+	event.FailureReason = failureReason
+
+	if event.Success {
+		metrics.Logins.WithLabelValues("success").Inc()
+	} else {
+		metrics.Logins.WithLabelValues(failureReason).Inc()
+	}
+
+	if _, err := us.loginEventRepository.CreateLoginEvent(ctx, nil, event); err != nil {
+		slog.ErrorContext(ctx, "Failed to record login event", "error", err)
+	}
+}
+
+func (us *userService) AuthenticateUser(ctx context.Context, id string) error {
+	user, err := us.userRepository.GetUserByPrimaryKey(ctx, nil, constant.DBAttrID, id)
 	if err != nil {
-		return false
+		return err
 	}
 
-	if userCheck.Email == email && passwordCheck {
-		return true
+	if reflect.DeepEqual(user, entity.User{}) {
+		return errs.ErrUserNotFound
+	}
+
+	now := time.Now()
+	if !user.IsActive(now) {
+		return errs.ErrUserNotActive
+	}
+
+	if user.LastSeenAt == nil || now.Sub(*user.LastSeenAt) >= constant.LastSeenThrottle {
+		if err := us.userRepository.UpdateUserLastSeen(ctx, nil, id, now); err != nil {
+			slog.ErrorContext(ctx, "Failed to update last seen", "error", err, "user_id", id)
+		}
+	}
+	return nil
+}
+
+func (us *userService) GetLoginEvents(ctx context.Context, userID string, req base.GetsRequest) (
+	eventsResp []dto.LoginEventResponse, pageResp base.PaginationResponse, err error) {
+	if req.PerPage < 0 {
+		req.PerPage = 0
+	}
+
+	if req.Page < 0 {
+		req.Page = 0
+	}
+
+	user, err := us.userRepository.GetUserByPrimaryKey(ctx, nil, constant.DBAttrID, userID)
+	if err != nil {
+		return []dto.LoginEventResponse{}, base.PaginationResponse{}, err
+	}
+
+	if reflect.DeepEqual(user, entity.User{}) {
+		return []dto.LoginEventResponse{}, base.PaginationResponse{}, errs.ErrUserNotFound
+	}
+
+	events, lastPage, total, err := us.loginEventRepository.GetLoginEventsByUserID(ctx, nil, userID, req)
+	if err != nil {
+		return []dto.LoginEventResponse{}, base.PaginationResponse{}, err
+	}
+
+	eventsResp = []dto.LoginEventResponse{}
+	for _, event := range events {
+		eventsResp = append(eventsResp, dto.LoginEventResponse{
+			ID:            event.ID.String(),
+			Email:         event.Email,
+			IP:            event.IP,
+			UserAgent:     event.UserAgent,
+			Success:       event.Success,
+			FailureReason: event.FailureReason,
+			CreatedAt:     event.CreatedAt,
+		})
 	}
-	return false
+
+	if req.PerPage == 0 {
+		return eventsResp, base.PaginationResponse{}, nil
+	}
+
+	pageResp = base.PaginationResponse{
+		Page:     int64(req.Page),
+		PerPage:  int64(req.PerPage),
+		LastPage: lastPage,
+		Total:    total,
+	}
+	return eventsResp, pageResp, nil
 }
 
 func (us *userService) CreateNewUser(ctx context.Context, ud dto.UserRegisterRequest) (dto.UserResponse, error) {
@@ -73,13 +298,9 @@ func (us *userService) CreateNewUser(ctx context.Context, ud dto.UserRegisterReq
 	if err != nil {
 		return dto.UserResponse{}, err
 	}
+	metrics.Registrations.Inc()
 
-	return dto.UserResponse{
-		ID:    newUser.ID.String(),
-		Name:  newUser.Name,
-		Email: newUser.Email,
-		Role:  newUser.Role,
-	}, nil
+	return us.toUserResponse(newUser), nil
 }
 
 func (us *userService) GetAllUsers(ctx context.Context, req base.GetsRequest) (
@@ -102,17 +323,7 @@ func (us *userService) GetAllUsers(ctx context.Context, req base.GetsRequest) (
 	}
 
 	for _, user := range users {
-		userResp := dto.UserResponse{
-			ID:    user.ID.String(),
-			Name:  user.Name,
-			Email: user.Email,
-			Role:  user.Role,
-		}
-		if user.Picture != nil {
-			userResp.Picture = *user.Picture
-		}
-
-		usersResp = append(usersResp, userResp)
+		usersResp = append(usersResp, us.toUserResponse(user))
 	}
 
 	if req.PerPage == 0 {
@@ -134,39 +345,47 @@ func (us *userService) GetUserByPrimaryKey(ctx context.Context, key string, val
 		return dto.UserResponse{}, err
 	}
 
-	userResp := dto.UserResponse{
-		ID:    user.ID.String(),
-		Name:  user.Name,
-		Email: user.Email,
-		Role:  user.Role,
+	return us.toUserResponse(user), nil
+}
+
+func (us *userService) GetUserByID(ctx context.Context, id string, req base.GetRequest) (dto.UserResponse, error) {
+	user, err := us.userRepository.GetUserByID(ctx, nil, id, req)
+	if err != nil {
+		return dto.UserResponse{}, err
 	}
-	if user.Picture != nil {
-		userResp.Picture = *user.Picture
+
+	if reflect.DeepEqual(user, entity.User{}) {
+		return dto.UserResponse{}, errs.ErrUserNotFound
 	}
 
-	return userResp, nil
+	return us.toUserResponse(user), nil
 }
 
 func (us *userService) UpdateSelfName(ctx context.Context,
-	ud dto.UserNameUpdateRequest, id string) (dto.UserResponse, error) {
+	ud dto.UserNameUpdateRequest, id string, version uint) (dto.UserResponse, error) {
 	user, err := us.userRepository.GetUserByPrimaryKey(ctx, nil, constant.DBAttrID, id)
 	if err != nil {
 		return dto.UserResponse{}, err
 	}
 
-	user, err = us.userRepository.UpdateNameUser(ctx, nil, ud.Name, user)
+	if reflect.DeepEqual(user, entity.User{}) {
+		return dto.UserResponse{}, errs.ErrUserNotFound
+	}
+
+	if err := checkVersion(user, version); err != nil {
+		return us.toUserResponse(user), err
+	}
+
+	edited, err := us.userRepository.UpdateNameUser(ctx, nil, ud.Name, user)
 	if err != nil {
-		return dto.UserResponse{}, err
+		return us.resolveVersionConflict(ctx, id, err)
 	}
 
-	return dto.UserResponse{
-		ID:   user.ID.String(),
-		Name: user.Name,
-	}, nil
+	return us.toUserResponse(edited), nil
 }
 
 func (us *userService) UpdateUserByID(ctx context.Context,
-	ud dto.UserUpdateRequest, id string) (dto.UserResponse, error) {
+	ud dto.UserUpdateRequest, id string, version uint) (dto.UserResponse, error) {
 	user, err := us.userRepository.GetUserByPrimaryKey(ctx, nil, constant.DBAttrID, id)
 	if err != nil {
 		return dto.UserResponse{}, err
@@ -176,67 +395,242 @@ func (us *userService) UpdateUserByID(ctx context.Context,
 		return dto.UserResponse{}, errs.ErrUserNotFound
 	}
 
-	if ud.Email != "" && ud.Email != user.Email {
-		us, err := us.userRepository.GetUserByPrimaryKey(ctx, nil, constant.DBAttrEmail, ud.Email)
+	if err := checkVersion(user, version); err != nil {
+		return us.toUserResponse(user), err
+	}
+
+	return us.replaceUser(ctx, user, ud)
+}
+
+func (us *userService) PatchUserByID(ctx context.Context,
+	patch []byte, id string, version uint) (dto.UserResponse, error) {
+	user, err := us.userRepository.GetUserByPrimaryKey(ctx, nil, constant.DBAttrID, id)
+	if err != nil {
+		return dto.UserResponse{}, err
+	}
+
+	if reflect.DeepEqual(user, entity.User{}) {
+		return dto.UserResponse{}, errs.ErrUserNotFound
+	}
+
+	if err := checkVersion(user, version); err != nil {
+		return us.toUserResponse(user), err
+	}
+
+	current, err := json.Marshal(dto.UserUpdateRequest{
+		Name:    user.Name,
+		Email:   user.Email,
+		Role:    user.Role,
+		Picture: user.Picture,
+	})
+	if err != nil {
+		return dto.UserResponse{}, err
+	}
+
+	merged, err := util.MergePatch(current, patch)
+	if err != nil {
+		return dto.UserResponse{}, err
+	}
+
+	var ud dto.UserUpdateRequest
+	decoder := json.NewDecoder(bytes.NewReader(merged))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&ud); err != nil {
+		return dto.UserResponse{}, fmt.Errorf("%w: %s", errs.ErrInvalidMergePatch, err.Error())
+	}
+
+	if err := binding.Validator.ValidateStruct(&ud); err != nil {
+		return dto.UserResponse{}, err
+	}
+
+	// the merged document is whole, a picture missing from it was removed by
+	// the patch
+	ud.PictureSet = true
+	return us.replaceUser(ctx, user, ud)
+}
+
+// replaceUser overwrites the writable fields of user with the validated
+// representation ud, where a nil picture clears the current one unless it was
+// left out.
+func (us *userService) replaceUser(ctx context.Context,
+	user entity.User, ud dto.UserUpdateRequest) (dto.UserResponse, error) {
+	if !ud.PictureSet {
+		ud.Picture = user.Picture
+	}
+
+	if ud.Email != user.Email {
+		userCheck, err := us.userRepository.GetUserByPrimaryKey(ctx, nil, constant.DBAttrEmail, ud.Email)
 		if err != nil {
 			return dto.UserResponse{}, err
 		}
 
-		if !(reflect.DeepEqual(us, entity.User{})) {
+		if !(reflect.DeepEqual(userCheck, entity.User{})) {
 			return dto.UserResponse{}, errs.ErrEmailAlreadyExists
 		}
 	}
 
+	if ud.Picture != nil && (user.Picture == nil || !isSamePicture(*ud.Picture, *user.Picture)) {
+		return dto.UserResponse{}, errs.ErrUserPictureChanged
+	}
+
 	userEdit := entity.User{
-		ID:       user.ID,
-		Name:     ud.Name,
-		Email:    ud.Email,
-		Role:     ud.Role,
-		Password: ud.Password,
+		ID:      user.ID,
+		Name:    ud.Name,
+		Email:   ud.Email,
+		Role:    ud.Role,
+		Picture: ud.Picture,
+	}
+	if ud.Password != nil {
+		userEdit.Password = *ud.Password
 	}
+	userEdit.Version = user.Version
 
-	edited, err := us.userRepository.UpdateUser(ctx, nil, userEdit)
+	txr := us.userRepository.TxRepository()
+	tx, err := txr.BeginTx(ctx)
 	if err != nil {
 		return dto.UserResponse{}, err
 	}
 
-	if edited.Name == "" {
-		edited.Name = user.Name
-	}
-	if edited.Email == "" {
-		edited.Email = user.Email
+	edited, err := us.userRepository.ReplaceUser(ctx, tx, userEdit)
+	var deletion entity.FileDeletion
+	if err == nil && ud.Picture == nil && user.Picture != nil && *user.Picture != "" {
+		deletion, err = us.fileDeletionRepository.CreateFileDeletion(ctx, tx, newFileDeletion(*user.Picture))
 	}
-	if edited.Role == "" {
-		edited.Role = user.Role
+
+	err = txr.CommitOrRollbackTx(ctx, tx, err)
+	if err != nil {
+		return us.resolveVersionConflict(ctx, user.ID.String(), err)
 	}
 
-	return dto.UserResponse{
-		ID:    edited.ID.String(),
-		Name:  edited.Name,
-		Email: edited.Email,
-		Role:  edited.Role,
-	}, nil
+	processFileDeletion(ctx, us.fileDeletionService, deletion)
+	return us.toUserResponse(edited), nil
 }
 
-func (us *userService) DeleteUserByID(ctx context.Context, id string) error {
+func (us *userService) DeleteUserByID(ctx context.Context, id string, version uint) (dto.UserResponse, error) {
 	userCheck, err := us.userRepository.GetUserByPrimaryKey(ctx, nil, constant.DBAttrID, id)
 	if err != nil {
-		return err
+		return dto.UserResponse{}, err
 	}
 
 	if reflect.DeepEqual(userCheck, entity.User{}) {
-		return errs.ErrUserNotFound
+		return dto.UserResponse{}, errs.ErrUserNotFound
 	}
 
-	err = us.userRepository.DeleteUserByID(ctx, nil, id)
+	if err := checkVersion(userCheck, version); err != nil {
+		return us.toUserResponse(userCheck), err
+	}
+
+	txr := us.userRepository.TxRepository()
+	tx, err := txr.BeginTx(ctx)
 	if err != nil {
-		return err
+		return dto.UserResponse{}, err
 	}
-	return nil
+
+	err = us.userRepository.DeleteUserByID(ctx, tx, id, userCheck.Version)
+	var filePaths []string
+	if err == nil && userCheck.Picture != nil && *userCheck.Picture != "" {
+		filePaths = append(filePaths, *userCheck.Picture)
+	}
+
+	// the attachments of the user go away along with the user
+	var attachments []entity.Attachment
+	if err == nil {
+		attachments, err = us.attachmentRepository.DeleteAttachmentsByOwner(ctx, tx, constant.EnumAttachmentOwnerUser, id)
+	}
+	for _, attachment := range attachments {
+		filePaths = append(filePaths, attachmentPath(attachment))
+	}
+
+	var deletions []entity.FileDeletion
+	for i := 0; err == nil && i < len(filePaths); i++ {
+		var deletion entity.FileDeletion
+		deletion, err = us.fileDeletionRepository.CreateFileDeletion(ctx, tx, newFileDeletion(filePaths[i]))
+		deletions = append(deletions, deletion)
+	}
+
+	err = txr.CommitOrRollbackTx(ctx, tx, err)
+	if err != nil {
+		return us.resolveVersionConflict(ctx, id, err)
+	}
+
+	for _, deletion := range deletions {
+		processFileDeletion(ctx, us.fileDeletionService, deletion)
+	}
+	return dto.UserResponse{}, nil
 }
 
 func (us *userService) ChangePicture(ctx context.Context,
-	req dto.UserChangePictureRequest, userID string) (dto.UserResponse, error) {
+	req dto.UserChangePictureRequest, userID string, version uint) (dto.UserResponse, error) {
+	return us.setPicture(ctx, userID, version, func() (dto.FileResponse, error) {
+		return us.storePicture(ctx, dto.FileUploadRequest{
+			OwnerID:     userID,
+			Name:        req.Filename,
+			ContentType: req.ContentType,
+			Size:        -1,
+			Content:     req.Picture,
+		})
+	})
+}
+
+func (us *userService) AttachPicture(ctx context.Context,
+	req dto.UserAttachPictureRequest, userID string, version uint) (dto.UserResponse, error) {
+	return us.setPicture(ctx, userID, version, func() (dto.FileResponse, error) {
+		return us.uploadService.Attach(ctx, req.UploadID, userID, func(pic dto.FileUploadRequest) (dto.FileResponse, error) {
+			return us.storePicture(ctx, pic)
+		})
+	})
+}
+
+// storePicture stores the picture re-encoded, which drops its metadata, along
+// with its fixed size variants once it is verified to be a supported image.
+func (us *userService) storePicture(ctx context.Context, req dto.FileUploadRequest) (dto.FileResponse, error) {
+	if req.Size > us.maxPictureSize {
+		return dto.FileResponse{}, errs.ErrUserPictureTooLarge
+	}
+
+	data, err := io.ReadAll(io.LimitReader(req.Content, us.maxPictureSize+1))
+	if err != nil {
+		return dto.FileResponse{}, err
+	}
+	if int64(len(data)) > us.maxPictureSize {
+		return dto.FileResponse{}, errs.ErrUserPictureTooLarge
+	}
+
+	pic, err := imaging.Decode(data, constant.PictureMaxSide, constant.PictureMaxPixels)
+	if err != nil {
+		return dto.FileResponse{}, err
+	}
+
+	var encoded bytes.Buffer
+	contentType, err := imaging.Encode(&encoded, pic.Image, pic.Format)
+	if err != nil {
+		return dto.FileResponse{}, err
+	}
+
+	req.Dir = constant.PictureDir
+	req.ContentType, req.Size, req.Content = contentType, int64(encoded.Len()), &encoded
+	for _, size := range pictureVariantSizes {
+		var variant bytes.Buffer
+		variantType, err := imaging.Encode(&variant, imaging.Thumbnail(pic.Image, size), pic.Format)
+		if err != nil {
+			return dto.FileResponse{}, err
+		}
+
+		req.Variants = append(req.Variants, dto.FileVariantUpload{
+			Name:        strconv.Itoa(size),
+			ContentType: variantType,
+			Size:        int64(variant.Len()),
+			Content:     &variant,
+		})
+	}
+
+	return us.fileService.Upload(ctx, req)
+}
+
+// setPicture replaces the picture of the user with the one stored by store,
+// which is only called once the user is known to be on the expected version.
+func (us *userService) setPicture(ctx context.Context, userID string,
+	version uint, store func() (dto.FileResponse, error)) (dto.UserResponse, error) {
 	user, err := us.userRepository.GetUserByPrimaryKey(ctx, nil, constant.DBAttrID, userID)
 	if err != nil {
 		return dto.UserResponse{}, err
@@ -246,51 +640,65 @@ func (us *userService) ChangePicture(ctx context.Context,
 		return dto.UserResponse{}, errs.ErrUserNotFound
 	}
 
-	if user.Picture != nil && *user.Picture != "" {
-		if err := util.DeleteFile(*user.Picture); err != nil {
-			return dto.UserResponse{}, err
-		}
+	if err := checkVersion(user, version); err != nil {
+		return us.toUserResponse(user), err
 	}
 
-	picID := uuid.New()
-	picPath := fmt.Sprintf("user_picture/%v", picID)
+	pic, err := store()
+	if err != nil {
+		return dto.UserResponse{}, err
+	}
 
 	userEdit := entity.User{
 		ID:      user.ID,
-		Picture: &picPath,
+		Picture: &pic.Path,
 	}
+	userEdit.Version = user.Version
 
-	if err := util.UploadFile(req.Picture, picPath); err != nil {
+	// the old picture is queued for deletion along with the update, so that it
+	// is only deleted once the user no longer points at it
+	txr := us.userRepository.TxRepository()
+	tx, err := txr.BeginTx(ctx)
+	if err != nil {
+		us.fileDeletionService.Release(ctx, pic.Path)
 		return dto.UserResponse{}, err
 	}
 
-	userUpdate, err := us.userRepository.UpdateUser(ctx, nil, userEdit)
+	userUpdate, err := us.userRepository.UpdateUser(ctx, tx, userEdit)
+	var deletion entity.FileDeletion
+	if err == nil && user.Picture != nil && *user.Picture != "" {
+		deletion, err = us.fileDeletionRepository.CreateFileDeletion(ctx, tx, newFileDeletion(*user.Picture))
+	}
+
+	err = txr.CommitOrRollbackTx(ctx, tx, err)
 	if err != nil {
-		return dto.UserResponse{}, err
+		us.fileDeletionService.Release(ctx, pic.Path)
+		return us.resolveVersionConflict(ctx, userID, err)
 	}
 
-	return dto.UserResponse{
-		ID:      userUpdate.ID.String(),
-		Picture: *userUpdate.Picture,
-	}, nil
+	processFileDeletion(ctx, us.fileDeletionService, deletion)
+
+	user.Picture = userUpdate.Picture
+	user.Version = userUpdate.Version
+	return us.toUserResponse(user), nil
 }
 
-func (us *userService) DeletePicture(ctx context.Context, userID string) error {
+func (us *userService) DeletePicture(ctx context.Context, userID string, version uint) (dto.UserResponse, error) {
 	user, err := us.userRepository.GetUserByPrimaryKey(ctx, nil, constant.DBAttrID, userID)
 	if err != nil {
-		return err
+		return dto.UserResponse{}, err
 	}
 
 	if reflect.DeepEqual(user, entity.User{}) {
-		return errs.ErrUserNotFound
+		return dto.UserResponse{}, errs.ErrUserNotFound
 	}
 
-	if user.Picture == nil || *user.Picture == "" {
-		return errs.ErrUserNoPicture
+	if err := checkVersion(user, version); err != nil {
+		return us.toUserResponse(user), err
 	}
 
-	if err := util.DeleteFile(*user.Picture); err != nil {
-		return err
+	if user.Picture == nil || *user.Picture == "" {
+		return dto.UserResponse{}, errs.ErrUserNoPicture
 	}
 
 	emptyString := ""
@@ -298,11 +706,199 @@ func (us *userService) DeletePicture(ctx context.Context, userID string) error {
 		ID:      user.ID,
 		Picture: &emptyString,
 	}
+	userEdit.Version = user.Version
 
-	_, err = us.userRepository.UpdateUser(ctx, nil, userEdit)
+	txr := us.userRepository.TxRepository()
+	tx, err := txr.BeginTx(ctx)
 	if err != nil {
-		return err
+		return dto.UserResponse{}, err
+	}
+
+	_, err = us.userRepository.UpdateUser(ctx, tx, userEdit)
+	var deletion entity.FileDeletion
+	if err == nil {
+		deletion, err = us.fileDeletionRepository.CreateFileDeletion(ctx, tx, newFileDeletion(*user.Picture))
+	}
+
+	err = txr.CommitOrRollbackTx(ctx, tx, err)
+	if err != nil {
+		return us.resolveVersionConflict(ctx, userID, err)
+	}
+
+	processFileDeletion(ctx, us.fileDeletionService, deletion)
+	return dto.UserResponse{}, nil
+}
+
+func (us *userService) ChangeUserStatus(ctx context.Context, req dto.UserStatusUpdateRequest,
+	id string, actorID string, version uint) (dto.UserResponse, error) {
+	if id == actorID {
+		return dto.UserResponse{}, errs.ErrUserStatusSelfChange
+	}
+
+	user, err := us.userRepository.GetUserByPrimaryKey(ctx, nil, constant.DBAttrID, id)
+	if err != nil {
+		return dto.UserResponse{}, err
+	}
+
+	if reflect.DeepEqual(user, entity.User{}) {
+		return dto.UserResponse{}, errs.ErrUserNotFound
+	}
+
+	if err := checkVersion(user, version); err != nil {
+		return us.toUserResponse(user), err
+	}
+
+	fromStatus, err := checkStatusChange(user, req, time.Now())
+	if err != nil {
+		return dto.UserResponse{}, err
+	}
+
+	userEdit := entity.User{
+		ID:              user.ID,
+		Status:          req.Status,
+		StatusExpiresAt: req.ExpiresAt,
+	}
+	if req.Reason != "" {
+		userEdit.StatusReason = &req.Reason
+	}
+	userEdit.Version = user.Version
+
+	statusLog := entity.UserStatusLog{
+		UserID:     user.ID,
+		FromStatus: fromStatus,
+		ToStatus:   req.Status,
+		Reason:     req.Reason,
+		ExpiresAt:  req.ExpiresAt,
+	}
+	if actor, err := uuid.Parse(actorID); err == nil {
+		statusLog.ActorID = &actor
+	}
+
+	txr := us.userRepository.TxRepository()
+	tx, err := txr.BeginTx(ctx)
+	if err != nil {
+		return dto.UserResponse{}, err
+	}
+
+	edited, err := us.userRepository.UpdateUserStatus(ctx, tx, userEdit)
+	if err == nil {
+		_, err = us.userStatusLogRepository.CreateUserStatusLog(ctx, tx, statusLog)
+	}
+
+	err = txr.CommitOrRollbackTx(ctx, tx, err)
+	if err != nil {
+		return us.resolveVersionConflict(ctx, id, err)
 	}
 
+	user.Status = edited.Status
+	user.StatusReason = edited.StatusReason
+	user.StatusExpiresAt = edited.StatusExpiresAt
+	user.Version = edited.Version
+	return us.toUserResponse(user), nil
+}
+
+func (us *userService) GetUserStatusHistory(ctx context.Context, id string) ([]dto.UserStatusLogResponse, error) {
+	user, err := us.userRepository.GetUserByPrimaryKey(ctx, nil, constant.DBAttrID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if reflect.DeepEqual(user, entity.User{}) {
+		return nil, errs.ErrUserNotFound
+	}
+
+	logs, err := us.userStatusLogRepository.GetUserStatusLogs(ctx, nil, id)
+	if err != nil {
+		return nil, err
+	}
+
+	logsResp := []dto.UserStatusLogResponse{}
+	for _, log := range logs {
+		logsResp = append(logsResp, toUserStatusLogResponse(log))
+	}
+	return logsResp, nil
+}
+
+// NewUserAttachmentPolicy lets users manage their own attachments and admins
+// manage everyone's, while the other users can only see them.
+func NewUserAttachmentPolicy(userR repository.UserRepository) AttachmentPolicy {
+	return func(ctx context.Context, actor dto.AttachmentActor, ownerID string) (AttachmentAccess, error) {
+		if _, err := uuid.Parse(ownerID); err != nil {
+			return AttachmentAccessNone, nil
+		}
+
+		user, err := userR.GetUserByPrimaryKey(ctx, nil, constant.DBAttrID, ownerID)
+		if err != nil {
+			return AttachmentAccessNone, err
+		}
+
+		if reflect.DeepEqual(user, entity.User{}) {
+			return AttachmentAccessNone, nil
+		} else if actor.ID == ownerID || actor.Role == constant.EnumRoleAdmin {
+			return AttachmentAccessManage, nil
+		}
+		return AttachmentAccessView, nil
+	}
+}
+
+// isSamePicture tells whether the picture value sent back by a client, being
+// either the storage key or the file URL from a response, points to key.
+func isSamePicture(val string, key string) bool {
+	if val == key {
+		return true
+	}
+
+	picURL, err := url.Parse(val)
+	if err != nil {
+		return false
+	}
+	return strings.TrimPrefix(picURL.Path, constant.FileRoutePrefix+"/") == key
+}
+
+// checkStatusChange returns the status the user moves from when req is a valid
+// change of it at the given time.
+func checkStatusChange(user entity.User, req dto.UserStatusUpdateRequest, now time.Time) (string, error) {
+	fromStatus := user.EffectiveStatus(now)
+	if !slices.Contains(userStatusTransitions[fromStatus], req.Status) {
+		return "", fmt.Errorf("%w: %s to %s", errs.ErrUserStatusTransition, fromStatus, req.Status)
+	}
+
+	restrictive := req.Status == constant.EnumStatusSuspended || req.Status == constant.EnumStatusBanned
+	if restrictive && req.Reason == "" {
+		return "", errs.ErrUserStatusReasonRequired
+	}
+
+	// only restrictive statuses can expire, after which the user is active again
+	if req.ExpiresAt != nil && (!restrictive || !req.ExpiresAt.After(now)) {
+		return "", errs.ErrUserStatusExpiryInvalid
+	}
+	return fromStatus, nil
+}
+
+// checkVersion fails when an expected version is given (through If-Match)
+// and the user has already moved past it.
+func checkVersion(user entity.User, version uint) error {
+	if version != 0 && user.Version != version {
+		return errs.ErrPreconditionFailed
+	}
 	return nil
 }
+
+// resolveVersionConflict returns the current representation of the user along
+// with the version error when a versioned write lost against a concurrent one.
+func (us *userService) resolveVersionConflict(ctx context.Context, id string, err error) (dto.UserResponse, error) {
+	if !errors.Is(err, errs.ErrVersionConflict) {
+		return dto.UserResponse{}, err
+	}
+
+	current, getErr := us.userRepository.GetUserByPrimaryKey(ctx, nil, constant.DBAttrID, id)
+	if getErr != nil {
+		return dto.UserResponse{}, getErr
+	}
+
+	if reflect.DeepEqual(current, entity.User{}) {
+		return dto.UserResponse{}, errs.ErrUserNotFound
+	}
+
+	return us.toUserResponse(current), err
+}
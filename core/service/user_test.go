@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zetsux/gin-gorm-clean-starter/common/base"
+	"github.com/zetsux/gin-gorm-clean-starter/common/constant"
+	"github.com/zetsux/gin-gorm-clean-starter/common/util"
+	"github.com/zetsux/gin-gorm-clean-starter/core/entity"
+	"github.com/zetsux/gin-gorm-clean-starter/core/helper/dto"
+	errs "github.com/zetsux/gin-gorm-clean-starter/core/helper/errors"
+	"github.com/zetsux/gin-gorm-clean-starter/core/repository"
+
+	"gorm.io/gorm"
+)
+
+// fakeUserRepository serves the user it holds, the methods a test does not
+// need are left to the embedded interface and panic when called.
+type fakeUserRepository struct {
+	repository.UserRepository
+	user entity.User
+	err  error
+}
+
+func (fur *fakeUserRepository) GetUserByPrimaryKey(_ context.Context,
+	_ *gorm.DB, _ string, _ string) (entity.User, error) {
+	return fur.user, fur.err
+}
+
+func (fur *fakeUserRepository) UpdateUserLastLogin(_ context.Context, _ *gorm.DB, _ string, _ time.Time) error {
+	return nil
+}
+
+// fakeLoginEventRepository keeps the login events it is given.
+type fakeLoginEventRepository struct {
+	repository.LoginEventRepository
+	events []entity.LoginEvent
+}
+
+func (fler *fakeLoginEventRepository) CreateLoginEvent(_ context.Context,
+	_ *gorm.DB, event entity.LoginEvent) (entity.LoginEvent, error) {
+	fler.events = append(fler.events, event)
+	return event, nil
+}
+
+func TestCheckVersion(t *testing.T) {
+	user := entity.User{Model: base.Model{Version: 3}}
+
+	for _, tt := range []struct {
+		name    string
+		version uint
+		err     error
+	}{
+		{"no If-Match", 0, nil},
+		{"current version", 3, nil},
+		{"stale version", 2, errs.ErrPreconditionFailed},
+		{"future version", 4, errs.ErrPreconditionFailed},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkVersion(user, tt.version); !errors.Is(err, tt.err) {
+				t.Errorf("checkVersion returned %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestResolveVersionConflict(t *testing.T) {
+	current := entity.User{ID: uuid.New(), Name: "current", Model: base.Model{Version: 5}}
+	getErr := errors.New("connection lost")
+
+	for _, tt := range []struct {
+		name    string
+		repo    fakeUserRepository
+		err     error
+		wantErr error
+		current bool
+	}{
+		{"lost version race", fakeUserRepository{user: current}, errs.ErrVersionConflict, errs.ErrVersionConflict, true},
+		{"other error", fakeUserRepository{user: current}, errs.ErrEmailAlreadyExists, errs.ErrEmailAlreadyExists, false},
+		{"user gone", fakeUserRepository{}, errs.ErrVersionConflict, errs.ErrUserNotFound, false},
+		{"lookup failure", fakeUserRepository{err: getErr}, errs.ErrVersionConflict, getErr, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			us := &userService{userRepository: &tt.repo}
+
+			userResp, err := us.resolveVersionConflict(context.Background(), current.ID.String(), tt.err)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("resolveVersionConflict returned %v, want %v", err, tt.wantErr)
+			}
+
+			gotCurrent := userResp.ID == current.ID.String() && userResp.Version == current.Version
+			if gotCurrent != tt.current {
+				t.Errorf("resolveVersionConflict returned %+v, want the current user: %v", userResp, tt.current)
+			}
+		})
+	}
+}
+
+func TestCheckStatusChange(t *testing.T) {
+	now := time.Now()
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+	const (
+		pending   = constant.EnumStatusPending
+		active    = constant.EnumStatusActive
+		suspended = constant.EnumStatusSuspended
+		banned    = constant.EnumStatusBanned
+	)
+
+	for _, tt := range []struct {
+		name string
+		user entity.User
+		req  dto.UserStatusUpdateRequest
+		from string
+		err  error
+	}{
+		{"pending to active", entity.User{Status: pending},
+			dto.UserStatusUpdateRequest{Status: active}, pending, nil},
+		{"pending to banned", entity.User{Status: pending},
+			dto.UserStatusUpdateRequest{Status: banned, Reason: "spam"}, pending, nil},
+		{"pending to suspended", entity.User{Status: pending},
+			dto.UserStatusUpdateRequest{Status: suspended, Reason: "abuse"}, "", errs.ErrUserStatusTransition},
+		{"active to suspended", entity.User{Status: active},
+			dto.UserStatusUpdateRequest{Status: suspended, Reason: "abuse"}, active, nil},
+		{"active to banned", entity.User{Status: active},
+			dto.UserStatusUpdateRequest{Status: banned, Reason: "fraud"}, active, nil},
+		{"active to active", entity.User{Status: active},
+			dto.UserStatusUpdateRequest{Status: active}, "", errs.ErrUserStatusTransition},
+		{"active to pending", entity.User{Status: active},
+			dto.UserStatusUpdateRequest{Status: pending}, "", errs.ErrUserStatusTransition},
+		{"suspended to active", entity.User{Status: suspended},
+			dto.UserStatusUpdateRequest{Status: active}, suspended, nil},
+		{"suspended to banned", entity.User{Status: suspended},
+			dto.UserStatusUpdateRequest{Status: banned, Reason: "repeated abuse"}, suspended, nil},
+		{"banned to active", entity.User{Status: banned},
+			dto.UserStatusUpdateRequest{Status: active}, banned, nil},
+		{"banned to suspended", entity.User{Status: banned},
+			dto.UserStatusUpdateRequest{Status: suspended, Reason: "appeal"}, "", errs.ErrUserStatusTransition},
+		{"no status yet", entity.User{},
+			dto.UserStatusUpdateRequest{Status: suspended, Reason: "abuse"}, active, nil},
+		{"expired suspension to suspended", entity.User{Status: suspended, StatusExpiresAt: &past},
+			dto.UserStatusUpdateRequest{Status: suspended, Reason: "abuse"}, active, nil},
+		{"expired suspension to active", entity.User{Status: suspended, StatusExpiresAt: &past},
+			dto.UserStatusUpdateRequest{Status: active}, "", errs.ErrUserStatusTransition},
+		{"suspended without reason", entity.User{Status: active},
+			dto.UserStatusUpdateRequest{Status: suspended}, "", errs.ErrUserStatusReasonRequired},
+		{"banned without reason", entity.User{Status: active},
+			dto.UserStatusUpdateRequest{Status: banned}, "", errs.ErrUserStatusReasonRequired},
+		{"suspended until later", entity.User{Status: active},
+			dto.UserStatusUpdateRequest{Status: suspended, Reason: "abuse", ExpiresAt: &future}, active, nil},
+		{"suspended until earlier", entity.User{Status: active},
+			dto.UserStatusUpdateRequest{Status: suspended, Reason: "abuse", ExpiresAt: &past},
+			"", errs.ErrUserStatusExpiryInvalid},
+		{"active until later", entity.User{Status: banned},
+			dto.UserStatusUpdateRequest{Status: active, ExpiresAt: &future}, "", errs.ErrUserStatusExpiryInvalid},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			from, err := checkStatusChange(tt.user, tt.req, now)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("checkStatusChange returned %v, want %v", err, tt.err)
+			}
+			if from != tt.from {
+				t.Errorf("checkStatusChange moved from %q, want %q", from, tt.from)
+			}
+		})
+	}
+}
+
+func TestVerifyLoginRecordsEveryAttempt(t *testing.T) {
+	password, err := util.PasswordHash("secret")
+	if err != nil {
+		t.Fatalf("PasswordHash: %v", err)
+	}
+	active := entity.User{ID: uuid.New(), Email: "active@mail.test", Password: password,
+		Status: constant.EnumStatusActive}
+	banned := entity.User{ID: uuid.New(), Email: "banned@mail.test", Password: password,
+		Status: constant.EnumStatusBanned}
+	lookupErr := errors.New("connection lost")
+
+	for _, tt := range []struct {
+		name     string
+		repo     fakeUserRepository
+		login    dto.UserLoginRequest
+		err      error
+		reason   string
+		identity bool
+	}{
+		{"success", fakeUserRepository{user: active},
+			dto.UserLoginRequest{Email: active.Email, Password: "secret"}, nil, "", true},
+		{"wrong password", fakeUserRepository{user: active},
+			dto.UserLoginRequest{Email: active.Email, Password: "guess"},
+			errs.ErrUserWrongCredential, constant.EnumLoginFailureWrongCredential, true},
+		{"unknown email", fakeUserRepository{},
+			dto.UserLoginRequest{Email: "unknown@mail.test", Password: "secret"},
+			errs.ErrUserWrongCredential, constant.EnumLoginFailureWrongCredential, false},
+		{"not active", fakeUserRepository{user: banned},
+			dto.UserLoginRequest{Email: banned.Email, Password: "secret"},
+			errs.ErrUserNotActive, constant.EnumLoginFailureNotActive, true},
+		{"lookup failure", fakeUserRepository{err: lookupErr},
+			dto.UserLoginRequest{Email: active.Email, Password: "secret"},
+			lookupErr, constant.EnumLoginFailureError, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			loginEventR := &fakeLoginEventRepository{}
+			us := &userService{userRepository: &tt.repo, loginEventRepository: loginEventR}
+
+			if err := us.VerifyLogin(context.Background(), tt.login); !errors.Is(err, tt.err) {
+				t.Errorf("VerifyLogin returned %v, want %v", err, tt.err)
+			}
+
+			if len(loginEventR.events) != 1 {
+				t.Fatalf("VerifyLogin recorded %d login events, want 1", len(loginEventR.events))
+			}
+			event := loginEventR.events[0]
+			if event.Email != tt.login.Email || event.Success != (tt.err == nil) || event.FailureReason != tt.reason {
+				t.Errorf("VerifyLogin recorded %+v, want a failure reason of %q", event, tt.reason)
+			}
+			if (event.UserID != nil) != tt.identity {
+				t.Errorf("VerifyLogin recorded the user %v, want it recorded: %v", event.UserID, tt.identity)
+			}
+		})
+	}
+}
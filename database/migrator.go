@@ -1,7 +1,7 @@
 package database
 
 import (
-	"fmt"
+	"log/slog"
 
 	"github.com/zetsux/gin-gorm-clean-starter/core/entity"
 	"github.com/zetsux/gin-gorm-clean-starter/database/seeder"
@@ -11,10 +11,20 @@ import (
 func DBMigrate(db *gorm.DB) {
 	err := db.AutoMigrate(
 		entity.User{},
+		entity.UserStatusLog{},
+		entity.LoginEvent{},
+		entity.FileBlob{},
+		entity.File{},
+		entity.FileVariant{},
+		entity.StorageReservation{},
+		entity.Upload{},
+		entity.UploadPart{},
+		entity.FileDeletion{},
+		entity.Attachment{},
 	)
 
 	if err != nil {
-		fmt.Println(err)
+		slog.Error("Failed to migrate the database", "error", err)
 		panic(err)
 	}
 
@@ -1,13 +1,29 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/zetsux/gin-gorm-clean-starter/api/v1/controller"
 	"github.com/zetsux/gin-gorm-clean-starter/api/v1/router"
+	"github.com/zetsux/gin-gorm-clean-starter/common/constant"
+	"github.com/zetsux/gin-gorm-clean-starter/common/metrics"
 	"github.com/zetsux/gin-gorm-clean-starter/common/middleware"
+	"github.com/zetsux/gin-gorm-clean-starter/common/scanner"
+	"github.com/zetsux/gin-gorm-clean-starter/common/storage"
 	"github.com/zetsux/gin-gorm-clean-starter/config"
+	"github.com/zetsux/gin-gorm-clean-starter/core/helper/dto"
 	"github.com/zetsux/gin-gorm-clean-starter/core/repository"
 	"github.com/zetsux/gin-gorm-clean-starter/core/service"
 
@@ -15,39 +31,232 @@ import (
 )
 
 func main() {
+	os.Exit(run())
+}
+
+// run runs the app until it is done, returning its exit code once every
+// deferred cleanup has run, which os.Exit would skip.
+func run() int {
+	cfg, args, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid configuration:")
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	config.LoggerSetup(cfg.Log)
+
 	var (
-		db = config.DBSetup()
+		db    = config.DBSetup(cfg.DB)
+		store = config.StorageSetup(cfg.Storage)
+		scan  = config.ScannerSetup(cfg.Scanner)
 
-		txR   = repository.NewTxRepository(db)
-		userR = repository.NewUserRepository(txR)
+		txR            = repository.NewTxRepository(db)
+		userR          = repository.NewUserRepository(txR)
+		userStatusLogR = repository.NewUserStatusLogRepository(txR)
+		loginEventR    = repository.NewLoginEventRepository(txR)
+		fileR          = repository.NewFileRepository(txR)
+		uploadR        = repository.NewUploadRepository(txR)
+		fileDeletionR  = repository.NewFileDeletionRepository(txR)
+		attachmentR    = repository.NewAttachmentRepository(txR)
+		healthR        = repository.NewHealthRepository(txR)
 
-		jwtS  = service.NewJWTService()
-		userS = service.NewUserService(userR)
+		jwtS          = service.NewJWTService(cfg.JWT.Secret)
+		storageQuotaS = service.NewStorageQuotaService(userR, fileR, map[string]int64{
+			constant.EnumRoleUser:  cfg.StorageQuota.User,
+			constant.EnumRoleAdmin: cfg.StorageQuota.Admin,
+		})
+		fileS = service.NewFileService(fileR, storageQuotaS, store, scan,
+			cfg.File.SigningSecret, cfg.File.PublicDirs)
+		uploadS            = service.NewUploadService(uploadR, storageQuotaS, store, cfg.Upload.MaxResumableSize)
+		fileDeletionS      = service.NewFileDeletionService(fileDeletionR, fileS)
+		garbageCollectionS = service.NewGarbageCollectionService(fileR, fileS, uploadS, store)
+		userS              = service.NewUserService(userR, userStatusLogR, loginEventR, fileDeletionR,
+			attachmentR, fileS, uploadS, fileDeletionS, cfg.Upload.MaxPictureSize)
+		avatarS     = service.NewAvatarService()
+		healthS     = service.NewHealthService()
+		attachmentS = service.NewAttachmentService(attachmentR, fileDeletionR, fileS, uploadS, fileDeletionS,
+			map[string]service.AttachmentPolicy{
+				constant.EnumAttachmentOwnerUser: service.NewUserAttachmentPolicy(userR),
+			})
 
-		fileC = controller.NewFileController()
-		userC = controller.NewUserController(userS, jwtS)
+		avatarC         = controller.NewAvatarController(avatarS)
+		healthC         = controller.NewHealthController(healthS)
+		metricsC        = controller.NewMetricsController(metrics.Handler())
+		fileC           = controller.NewFileController(fileS, garbageCollectionS)
+		uploadC         = controller.NewUploadController(uploadS)
+		userC           = controller.NewUserController(userS, jwtS, storageQuotaS)
+		userAttachmentC = controller.NewAttachmentController(attachmentS, constant.EnumAttachmentOwnerUser, "user_id")
 	)
 
+	// the pool is closed last, once the server and the workers are done with it
 	defer config.DBClose(db)
 
+	// Stopping on SIGINT and SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Running the garbage collection command instead of the server
+	if len(args) > 0 && args[0] == "gc" {
+		if err := collectGarbage(ctx, garbageCollectionS, args[1:]); err != nil {
+			slog.Error("Garbage collection failed", "error", err)
+			return 1
+		}
+		return 0
+	}
+
+	// Re-encrypting stored files with the current key instead of running the server
+	if len(args) > 0 && args[0] == "rotate-keys" {
+		if err := rotateStorageKeys(ctx, store); err != nil {
+			slog.Error("Key rotation failed", "error", err)
+			return 1
+		}
+		return 0
+	}
+
+	// Running the background workers until the server is shut down
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	runWorker := func(run func(ctx context.Context)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			run(workersCtx)
+		}()
+	}
+
+	// Retrying queued file deletions in the background
+	runWorker(func(ctx context.Context) {
+		fileDeletionS.Run(ctx, constant.FileDeletionInterval)
+	})
+
+	// Collecting garbage files in the background when an interval is set
+	if cfg.File.GCInterval > 0 {
+		runWorker(func(ctx context.Context) {
+			garbageCollectionS.Run(ctx, cfg.File.GCInterval)
+		})
+	}
+
+	// Checking the dependencies on readiness probes
+	healthS.Register("database", constant.HealthCheckTimeout, service.NewDatabaseHealthCheck(healthR))
+	healthS.Register("storage", constant.HealthCheckTimeout, service.NewStorageHealthCheck(store))
+	if cfg.Scanner.Driver != scanner.DriverNone {
+		healthS.Register("scanner", constant.HealthCheckTimeout, service.NewScannerHealthCheck(scan))
+	}
+
 	// Setting Up Server
-	server := gin.Default()
-	server.Use(
+	if cfg.IsProduction() {
+		gin.SetMode(gin.ReleaseMode)
+	}
+	engine := gin.New()
+	// the request context is what *gin.Context falls back to, so that the
+	// request ID reaches the services and repositories
+	engine.ContextWithFallback = true
+	engine.Use(
+		middleware.RequestID(),
+		middleware.Logger("/healthz", "/readyz", "/metrics"),
+		// before the recovery, so that the requests which panicked are
+		// counted with the 500 they are answered with
+		middleware.Metrics(),
+		middleware.Recovery(),
 		middleware.CORSMiddleware(),
 	)
 
 	// Setting Up Routes
-	router.FileRouter(server, fileC)
-	router.UserRouter(server, userC, jwtS)
+	router.HealthRouter(engine, healthC)
+	router.MetricsRouter(engine, metricsC, cfg.Metrics.Token)
+	router.AvatarRouter(engine, avatarC)
+	router.FileRouter(engine, fileC, jwtS, userS)
+	router.UploadRouter(engine, uploadC, jwtS, userS)
+	router.UserRouter(engine, userC, userAttachmentC, jwtS, userS, cfg.Upload)
+
+	// Running in localhost:8080 by default until a shutdown signal
+	server := config.ServerSetup(cfg, engine)
+	code := 0
+	listener, err := net.Listen("tcp", server.Addr)
+	if err == nil {
+		err = serve(ctx, server, listener, cfg.Server, healthS)
+	}
+	if err != nil {
+		slog.Error("Server failed", "error", err)
+		code = 1
+	}
+
+	// the workers are only stopped once no request is left to queue work
+	stopWorkers()
+	workers.Wait()
+	return code
+}
+
+// serve runs the server on listener until ctx is done, then fails readiness
+// and keeps serving for the shutdown delay before shutting down by draining
+// the in-flight requests, which are cut off once the shutdown timeout is over.
+func serve(ctx context.Context, server *http.Server, listener net.Listener,
+	cfg config.ServerConfig, healthS service.HealthService) error {
+	slog.Info("Serving HTTP", "addr", listener.Addr().String())
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("Shutting down server")
+	healthS.Shutdown()
+	select {
+	case err := <-serveErr:
+		return err
+	case <-time.After(cfg.ShutdownDelay):
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
 
-	// Running in localhost:8080
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		server.Close()
+		return err
 	}
-	err := server.Run(":" + port)
+	return nil
+}
+
+// collectGarbage runs a single garbage collection following the command line
+// args, only reporting what would be deleted unless -delete is given.
+func collectGarbage(ctx context.Context, garbageCollectionS service.GarbageCollectionService, args []string) error {
+	flags := flag.NewFlagSet("gc", flag.ContinueOnError)
+	minAge := flags.Duration("min-age", constant.GarbageCollectionMinAge,
+		"only collect files older than this")
+	del := flags.Bool("delete", false, "delete the garbage instead of only reporting it")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	garbage, err := garbageCollectionS.Collect(ctx, dto.GarbageCollectionRequest{
+		MinAge: *minAge,
+		DryRun: !*del,
+	})
 	if err != nil {
-		fmt.Println("Server failed to start: ", err)
-		return
+		return err
 	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(garbage)
+}
+
+// rotateStorageKeys re-encrypts the stored files which are not encrypted with
+// the current master key yet.
+func rotateStorageKeys(ctx context.Context, store storage.Storage) error {
+	rotator, ok := store.(storage.KeyRotator)
+	if !ok {
+		return errors.New("storage encryption is not enabled")
+	}
+
+	rotated, err := rotator.RotateKeys(ctx)
+	slog.InfoContext(ctx, "Rotated stored files", "rotated", rotated)
+	return err
 }
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/zetsux/gin-gorm-clean-starter/config"
+	errs "github.com/zetsux/gin-gorm-clean-starter/core/helper/errors"
+	"github.com/zetsux/gin-gorm-clean-starter/core/service"
+)
+
+// startServe runs serve over a test listener with a handler which answers
+// once it has slept for the given time, returning the URL of the server, a
+// channel closed once a request reached the handler and the outcome of serve.
+func startServe(ctx context.Context, t *testing.T, cfg config.ServerConfig,
+	healthS service.HealthService, sleep time.Duration) (string, <-chan struct{}, <-chan error) {
+	t.Helper()
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(sleep)
+		io.WriteString(w, "done")
+	})
+
+	// the listener of an unstarted test server is only used by serve
+	ts := httptest.NewUnstartedServer(handler)
+	url := "http://" + ts.Listener.Addr().String()
+
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(ctx, &http.Server{Handler: handler}, ts.Listener, cfg, healthS)
+	}()
+	return url, started, served
+}
+
+func TestServeDrainsInFlightRequests(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	healthS := service.NewHealthService()
+	url, started, served := startServe(ctx, t, config.ServerConfig{
+		ShutdownDelay:   10 * time.Millisecond,
+		ShutdownTimeout: 5 * time.Second,
+	}, healthS, 300*time.Millisecond)
+
+	type response struct {
+		body string
+		err  error
+	}
+	responded := make(chan response, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			responded <- response{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		responded <- response{body: string(body), err: err}
+	}()
+
+	<-started
+	cancel()
+
+	res := <-responded
+	if res.err != nil || res.body != "done" {
+		t.Errorf("the in-flight request got %q, %v, want the whole response", res.body, res.err)
+	}
+
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("serve returned %v, want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve did not return once the requests were drained")
+	}
+
+	if _, err := healthS.CheckReadiness(context.Background()); !errors.Is(err, errs.ErrServiceShuttingDown) {
+		t.Errorf("readiness returned %v after the shutdown, want %v", err, errs.ErrServiceShuttingDown)
+	}
+}
+
+func TestServeCutsOffRequestsPastTheShutdownTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	url, started, served := startServe(ctx, t, config.ServerConfig{
+		ShutdownTimeout: 100 * time.Millisecond,
+	}, service.NewHealthService(), 2*time.Second)
+
+	responded := make(chan error, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err == nil {
+			_, err = io.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+		responded <- err
+	}()
+
+	<-started
+	cancel()
+
+	select {
+	case err := <-served:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("serve returned %v, want %v", err, context.DeadlineExceeded)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("serve did not return once the shutdown timeout was over")
+	}
+
+	select {
+	case err := <-responded:
+		if err == nil {
+			t.Errorf("the request past the shutdown timeout got a response, want its connection cut")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the connection of the request past the shutdown timeout was not cut")
+	}
+}